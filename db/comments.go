@@ -7,30 +7,6 @@ import (
 	"github.com/tinotenda-alfaneti/homelabsite/models"
 )
 
-// CreateCommentsTable initializes the comments table in the database
-func CreateCommentsTable(database *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS comments (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		post_id TEXT NOT NULL,
-		parent_id INTEGER DEFAULT NULL,
-		author_name TEXT NOT NULL,
-		author_email TEXT NOT NULL,
-		content TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		approved BOOLEAN DEFAULT 0,
-		FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
-		FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE
-	);
-	
-	CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments(post_id);
-	CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments(parent_id);
-	CREATE INDEX IF NOT EXISTS idx_comments_approved ON comments(approved);
-	`
-	_, err := database.Exec(query)
-	return err
-}
-
 // SaveComment inserts a new comment into the database
 func SaveComment(database *sql.DB, comment *models.Comment) error {
 	tx, err := database.Begin()
@@ -5,53 +5,23 @@ import (
 	"testing"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/tinotenda-alfaneti/homelabsite/models"
 )
 
+// setupCommentsTestDB returns an in-memory database migrated like a real one,
+// with a single post to comment on
 func setupCommentsTestDB(t *testing.T) *sql.DB {
-	db, err := sql.Open("sqlite3", ":memory:")
+	db, err := New(":memory:")
 	if err != nil {
-		t.Fatalf("Failed to open test database: %v", err)
+		t.Fatalf("Failed to create test database: %v", err)
 	}
 
-	// Enable foreign key constraints in SQLite
-	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
-		t.Fatalf("Failed to enable foreign keys: %v", err)
-	}
-
-	// Create posts table
-	postsSchema := `
-	CREATE TABLE IF NOT EXISTS posts (
-		id TEXT PRIMARY KEY,
-		title TEXT NOT NULL,
-		date DATETIME NOT NULL,
-		category TEXT NOT NULL,
-		summary TEXT NOT NULL,
-		content TEXT NOT NULL,
-		tags TEXT NOT NULL
-	);`
-
-	if _, err := db.Exec(postsSchema); err != nil {
-		t.Fatalf("Failed to create posts table: %v", err)
-	}
-
-	// Create comments table
-	if err := CreateCommentsTable(db); err != nil {
-		t.Fatalf("Failed to create comments table: %v", err)
-	}
-
-	// Insert test post
-	_, err = db.Exec(`
-		INSERT INTO posts (id, title, date, category, summary, content, tags)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, "test-post", "Test Post", time.Now(), "Test", "A test post", "Test content", "test")
-
-	if err != nil {
+	post := &models.Post{ID: "test-post", Title: "Test Post", Date: time.Now(), Category: "Test", Summary: "A test post", Content: "Test content", Tags: []string{"test"}}
+	if err := db.SavePost(post); err != nil {
 		t.Fatalf("Failed to insert test post: %v", err)
 	}
 
-	return db
+	return db.GetConn()
 }
 
 func TestSaveComment(t *testing.T) {
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	// Import SQLite driver for database/sql registration
@@ -15,10 +16,29 @@ import (
 
 type DB struct {
 	conn *sql.DB
+	// fts is set when the posts_fts full-text index is available
+	fts bool
 }
 
-// New creates a new database connection
+// New creates a new database connection and applies pending migrations
 func New(dbPath string) (*DB, error) {
+	db, err := Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize schema
+	if err := db.initSchema(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initializing schema: %w", err)
+	}
+
+	return db, nil
+}
+
+// Open creates a new database connection without touching the schema.
+// Used by tooling that inspects migrations before applying them.
+func Open(dbPath string) (*DB, error) {
 	// Ensure directory exists
 	dir := filepath.Dir(dbPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -40,14 +60,7 @@ func New(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("pinging database: %w", err)
 	}
 
-	db := &DB{conn: conn}
-
-	// Initialize schema
-	if err := db.initSchema(); err != nil {
-		return nil, fmt.Errorf("initializing schema: %w", err)
-	}
-
-	return db, nil
+	return &DB{conn: conn}, nil
 }
 
 // Close closes the database connection
@@ -60,62 +73,69 @@ func (db *DB) GetConn() *sql.DB {
 	return db.conn
 }
 
-// initSchema creates the database schema if it doesn't exist
+// initSchema enables SQLite pragmas and brings the schema up to date
+// by applying any pending embedded migrations
 func (db *DB) initSchema() error {
 	// Enable foreign key constraints
 	if _, err := db.conn.Exec("PRAGMA foreign_keys = ON"); err != nil {
 		return fmt.Errorf("enabling foreign keys: %w", err)
 	}
 
-	schema := `
-	CREATE TABLE IF NOT EXISTS posts (
-		id TEXT PRIMARY KEY,
-		title TEXT NOT NULL,
-		date DATETIME NOT NULL,
-		category TEXT NOT NULL,
-		summary TEXT NOT NULL,
-		content TEXT NOT NULL,
-		tags TEXT NOT NULL,
-		views INTEGER DEFAULT 0,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_posts_date ON posts(date DESC);
-	CREATE INDEX IF NOT EXISTS idx_posts_category ON posts(category);
-	CREATE INDEX IF NOT EXISTS idx_posts_views ON posts(views DESC);
-
-	CREATE TABLE IF NOT EXISTS services (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		description TEXT NOT NULL,
-		url TEXT NOT NULL,
-		tech TEXT NOT NULL,
-		status TEXT NOT NULL,
-		icon TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_services_status ON services(status);
-	`
+	if _, err := db.Migrate(); err != nil {
+		return fmt.Errorf("running migrations: %w", err)
+	}
 
-	if _, err := db.conn.Exec(schema); err != nil {
-		return err
+	if err := db.initSearchIndex(); err != nil {
+		return fmt.Errorf("building search index: %w", err)
 	}
 
-	// Create comments table
-	if err := CreateCommentsTable(db.conn); err != nil {
-		return fmt.Errorf("creating comments table: %w", err)
+	if err := db.RefreshRelatedPosts(); err != nil {
+		return fmt.Errorf("scoring related posts: %w", err)
 	}
 
 	return nil
 }
 
-// GetAllPosts retrieves all posts from the database
-func (db *DB) GetAllPosts() ([]models.Post, error) {
-	query := `SELECT id, title, date, category, summary, content, tags, COALESCE(views, 0) FROM posts ORDER BY date DESC`
-	rows, err := db.conn.Query(query)
+// postColumns is the column list read by scanPost
+const postColumns = `id, title, date, category, summary, content, tags, COALESCE(views, 0), status, publish_at, series, series_order, updated_at`
+
+// publishedFilter restricts a query to posts visible on the public site:
+// published or scheduled, with a publish time that has passed.
+// It takes the current UTC time as its only parameter.
+const publishedFilter = `status IN ('published', 'scheduled') AND (publish_at IS NULL OR publish_at <= ?)`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPost reads a row selected with postColumns
+func scanPost(row rowScanner) (models.Post, error) {
+	var p models.Post
+	var tags string
+	var publishAt, updatedAt sql.NullTime
+	if err := row.Scan(&p.ID, &p.Title, &p.Date, &p.Category, &p.Summary, &p.Content, &tags, &p.Views, &p.Status, &publishAt,
+		&p.Series, &p.SeriesOrder, &updatedAt); err != nil {
+		return p, err
+	}
+	// Parse tags from comma-separated string
+	if tags != "" {
+		p.Tags = parseTagsFromString(tags)
+	}
+	if publishAt.Valid {
+		t := publishAt.Time
+		p.PublishAt = &t
+	}
+	if updatedAt.Valid {
+		t := updatedAt.Time.UTC()
+		p.UpdatedAt = &t
+	}
+	return p, nil
+}
+
+// queryPosts runs a query selecting postColumns and scans every row
+func (db *DB) queryPosts(query string, args ...interface{}) ([]models.Post, error) {
+	rows, err := db.conn.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -123,49 +143,103 @@ func (db *DB) GetAllPosts() ([]models.Post, error) {
 
 	var posts []models.Post
 	for rows.Next() {
-		var p models.Post
-		var tags string
-		if err := rows.Scan(&p.ID, &p.Title, &p.Date, &p.Category, &p.Summary, &p.Content, &tags, &p.Views); err != nil {
+		p, err := scanPost(rows)
+		if err != nil {
 			return nil, err
 		}
-		// Parse tags from comma-separated string
-		if tags != "" {
-			p.Tags = parseTagsFromString(tags)
-		}
 		posts = append(posts, p)
 	}
 
 	return posts, rows.Err()
 }
 
-// GetPostByID retrieves a single post by ID
-func (db *DB) GetPostByID(id string) (*models.Post, error) {
-	query := `SELECT id, title, date, category, summary, content, tags, COALESCE(views, 0) FROM posts WHERE id = ?`
-	row := db.conn.QueryRow(query, id)
+// publishTime returns the value stored in publish_at: NULL or a UTC time,
+// so it compares correctly against the UTC time passed to publishedFilter
+func publishTime(post *models.Post) interface{} {
+	if post.PublishAt == nil {
+		return nil
+	}
+	return post.PublishAt.UTC()
+}
 
-	var p models.Post
-	var tags string
-	if err := row.Scan(&p.ID, &p.Title, &p.Date, &p.Category, &p.Summary, &p.Content, &tags, &p.Views); err != nil {
+// postStatus returns the status stored for a post, defaulting to published
+func postStatus(post *models.Post) string {
+	if post.Status == "" {
+		return models.PostStatusPublished
+	}
+	return post.Status
+}
+
+// GetAllPosts retrieves all posts visible on the public site
+func (db *DB) GetAllPosts() ([]models.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts WHERE ` + publishedFilter + ` ORDER BY date DESC`
+	return db.queryPosts(query, time.Now().UTC())
+}
+
+// GetPostIndex retrieves every post visible on the public site without its
+// content, newest first
+func (db *DB) GetPostIndex() ([]models.Post, error) {
+	query := `SELECT ` + postListColumns + ` FROM posts WHERE ` + publishedFilter + ` ORDER BY date DESC, id DESC`
+	return db.queryPosts(query, time.Now().UTC())
+}
+
+// GetAllPostsForAdmin retrieves every post regardless of status
+func (db *DB) GetAllPostsForAdmin() ([]models.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts ORDER BY date DESC`
+	return db.queryPosts(query)
+}
+
+// GetPostByID retrieves a single post by ID, whatever its status.
+// Callers serving the public site should check Post.IsPublished.
+func (db *DB) GetPostByID(id string) (*models.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts WHERE id = ?`
+	p, err := scanPost(db.conn.QueryRow(query, id))
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
 
-	if tags != "" {
-		p.Tags = parseTagsFromString(tags)
-	}
-
 	return &p, nil
 }
 
-// SavePost creates or updates a post
+// SavePost creates or updates a post. An empty status is saved as published.
+// Every save also records a revision; see SavePostAs.
 func (db *DB) SavePost(post *models.Post) error {
+	return db.SavePostAs(post, "")
+}
+
+// SavePostAs creates or updates a post and records the saved content as a new
+// revision attributed to author, in one transaction
+func (db *DB) SavePostAs(post *models.Post, author string) error {
+	return db.withTx(func(tx *sql.Tx) error {
+		if err := savePost(tx, post); err != nil {
+			return err
+		}
+		if _, err := insertRevision(tx, post, author, ""); err != nil {
+			return err
+		}
+		return refreshRelatedPosts(tx)
+	})
+}
+
+// savePost upserts a post within a transaction, linking it to its tags and
+// category and leaving their canonical names in post
+func savePost(tx *sql.Tx, post *models.Post) error {
 	tags := joinTags(post.Tags)
+	status := postStatus(post)
+	post.Series = strings.TrimSpace(post.Series)
+	if !models.ValidPostStatus(status) {
+		return fmt.Errorf("invalid post status %q", post.Status)
+	}
+	if status == models.PostStatusScheduled && post.PublishAt == nil {
+		return fmt.Errorf("scheduled post %s needs a publish_at time", post.ID)
+	}
 
 	query := `
-	INSERT INTO posts (id, title, date, category, summary, content, tags, views, updated_at)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	INSERT INTO posts (id, title, date, category, summary, content, tags, views, status, publish_at, series, series_order, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
 	ON CONFLICT(id) DO UPDATE SET
 		title = excluded.title,
 		date = excluded.date,
@@ -173,90 +247,63 @@ func (db *DB) SavePost(post *models.Post) error {
 		summary = excluded.summary,
 		content = excluded.content,
 		tags = excluded.tags,
+		status = excluded.status,
+		publish_at = excluded.publish_at,
+		series = excluded.series,
+		series_order = excluded.series_order,
 		updated_at = CURRENT_TIMESTAMP
 	`
 
-	_, err := db.conn.Exec(query, post.ID, post.Title, post.Date, post.Category, post.Summary, post.Content, tags, post.Views)
-	return err
-}
-
-// DeletePost deletes a post by ID
-func (db *DB) DeletePost(id string) error {
-	query := `DELETE FROM posts WHERE id = ?`
-	_, err := db.conn.Exec(query, id)
-	return err
-}
-
-// GetAllServices retrieves all services from the database
-func (db *DB) GetAllServices() ([]models.Service, error) {
-	query := `SELECT name, description, url, tech, status, icon FROM services ORDER BY name`
-	rows, err := db.conn.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var services []models.Service
-	for rows.Next() {
-		var s models.Service
-		if err := rows.Scan(&s.Name, &s.Description, &s.URL, &s.Tech, &s.Status, &s.Icon); err != nil {
-			return nil, err
-		}
-		services = append(services, s)
+	if _, err := tx.Exec(query, post.ID, post.Title, post.Date, post.Category, post.Summary, post.Content, tags, post.Views,
+		status, publishTime(post), post.Series, post.SeriesOrder); err != nil {
+		return err
 	}
-
-	return services, rows.Err()
+	return setPostTaxonomy(tx, post)
 }
 
-// SaveService creates or updates a service
-func (db *DB) SaveService(service *models.Service) error {
-	// Check if service exists by name
-	var exists bool
-	err := db.conn.QueryRow(`SELECT EXISTS(SELECT 1 FROM services WHERE name = ?)`, service.Name).Scan(&exists)
+// withTx runs fn in a transaction, committing if it returns nil
+func (db *DB) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := db.conn.Begin()
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back transaction: %v", err)
+		}
+	}()
 
-	if exists {
-		query := `
-		UPDATE services 
-		SET description = ?, url = ?, tech = ?, status = ?, icon = ?, updated_at = CURRENT_TIMESTAMP
-		WHERE name = ?
-		`
-		_, err = db.conn.Exec(query, service.Description, service.URL, service.Tech, service.Status, service.Icon, service.Name)
-	} else {
-		query := `
-		INSERT INTO services (name, description, url, tech, status, icon)
-		VALUES (?, ?, ?, ?, ?, ?)
-		`
-		_, err = db.conn.Exec(query, service.Name, service.Description, service.URL, service.Tech, service.Status, service.Icon)
+	if err := fn(tx); err != nil {
+		return err
 	}
+	return tx.Commit()
+}
 
-	return err
+// DeletePost deletes a post by ID, along with tags and a category no other post uses
+func (db *DB) DeletePost(id string) error {
+	return db.withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM posts WHERE id = ?`, id); err != nil {
+			return err
+		}
+		if err := pruneTaxonomy(tx); err != nil {
+			return err
+		}
+		return refreshRelatedPosts(tx)
+	})
 }
 
-// MigrateFromYAML imports data from YAML files into the database
+// MigrateFromYAML imports data from YAML files into the database.
+// It inserts new rows and updates ones whose YAML changed since the last import;
+// see SyncContent for the full reconciliation options.
 func (db *DB) MigrateFromYAML(posts []models.Post, services []models.Service) error {
 	log.Printf("Migrating %d posts and %d services from YAML to database", len(posts), len(services))
 
-	// Migrate posts
-	for _, post := range posts {
-		if post.Date.IsZero() {
-			post.Date = time.Now()
-		}
-		if err := db.SavePost(&post); err != nil {
-			return fmt.Errorf("migrating post %s: %w", post.ID, err)
-		}
-	}
-
-	// Migrate services
-	for _, service := range services {
-		if err := db.SaveService(&service); err != nil {
-			return fmt.Errorf("migrating service %s: %w", service.Name, err)
-		}
+	report, err := db.SyncContent(posts, services, SyncOptions{})
+	if err != nil {
+		return err
 	}
 
-	log.Printf("Migration completed successfully")
+	log.Printf("Migration completed successfully: %s", report)
 	return nil
 }
 
@@ -305,30 +352,12 @@ func (db *DB) IncrementPostViews(postID string) error {
 	return err
 }
 
-// GetPopularPosts retrieves posts ordered by view count
+// GetPopularPosts retrieves published posts ordered by view count
 func (db *DB) GetPopularPosts(limit int) ([]models.Post, error) {
-	query := `SELECT id, title, date, category, summary, content, tags, COALESCE(views, 0) 
-	          FROM posts 
-	          ORDER BY views DESC, date DESC 
+	query := `SELECT ` + postColumns + `
+	          FROM posts
+	          WHERE ` + publishedFilter + `
+	          ORDER BY views DESC, date DESC
 	          LIMIT ?`
-	rows, err := db.conn.Query(query, limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var posts []models.Post
-	for rows.Next() {
-		var p models.Post
-		var tags string
-		if err := rows.Scan(&p.ID, &p.Title, &p.Date, &p.Category, &p.Summary, &p.Content, &tags, &p.Views); err != nil {
-			return nil, err
-		}
-		if tags != "" {
-			p.Tags = parseTagsFromString(tags)
-		}
-		posts = append(posts, p)
-	}
-
-	return posts, rows.Err()
+	return db.queryPosts(query, time.Now().UTC(), limit)
 }
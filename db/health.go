@@ -0,0 +1,118 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// RecordCheck stores the result of a service health check. A failing check
+// opens an incident for the service unless one is already open, and a passing
+// check resolves the open incident.
+func (db *DB) RecordCheck(result models.CheckResult) error {
+	return db.withTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			INSERT INTO service_checks (service_id, checked_at, up, latency_ms, status_code, error)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, result.ServiceID, result.CheckedAt.UTC(), result.Up, result.LatencyMS, result.StatusCode, result.Error)
+		if err != nil {
+			return err
+		}
+		return updateIncidents(tx, result)
+	})
+}
+
+// PruneChecks deletes check results older than before and returns how many were removed
+func (db *DB) PruneChecks(before time.Time) (int64, error) {
+	result, err := db.conn.Exec(`DELETE FROM service_checks WHERE checked_at < ?`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetServiceHealth summarises each service's latest check and its uptime
+// since the given time. Services that have never been checked are absent.
+func (db *DB) GetServiceHealth(since time.Time) (map[int64]models.ServiceHealth, error) {
+	health := make(map[int64]models.ServiceHealth)
+
+	rows, err := db.conn.Query(`
+		SELECT c.service_id, c.checked_at, c.up, c.latency_ms, c.error
+		FROM service_checks c
+		WHERE c.id = (
+			SELECT id FROM service_checks
+			WHERE service_id = c.service_id
+			ORDER BY checked_at DESC, id DESC
+			LIMIT 1
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var checkedAt time.Time
+		var up bool
+		var h models.ServiceHealth
+		if err := rows.Scan(&id, &checkedAt, &up, &h.LatencyMS, &h.Error); err != nil {
+			return nil, err
+		}
+		h.CheckedAt = &checkedAt
+		h.State = models.HealthDown
+		if up {
+			h.State = models.HealthUp
+		}
+		health[id] = h
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.conn.Query(`
+		SELECT service_id, COUNT(*), SUM(up)
+		FROM service_checks
+		WHERE checked_at >= ?
+		GROUP BY service_id
+	`, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var checks, up int
+		if err := rows.Scan(&id, &checks, &up); err != nil {
+			return nil, err
+		}
+		h := health[id]
+		h.Checks = checks
+		if checks > 0 {
+			h.Uptime = float64(up) * 100 / float64(checks)
+		}
+		health[id] = h
+	}
+
+	return health, rows.Err()
+}
+
+// AttachHealth fills in Health on each service from GetServiceHealth.
+// Services without any checks get the unknown state.
+func (db *DB) AttachHealth(services []models.Service, since time.Time) error {
+	health, err := db.GetServiceHealth(since)
+	if err != nil {
+		return err
+	}
+
+	for i := range services {
+		h, ok := health[services[i].ID]
+		if !ok {
+			h = models.ServiceHealth{State: models.HealthUnknown}
+		}
+		services[i].Health = &h
+	}
+	return nil
+}
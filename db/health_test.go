@@ -0,0 +1,109 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestServiceHealth(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	jenkins := &models.Service{Name: "Jenkins", Status: "internal"}
+	gitea := &models.Service{Name: "Gitea", Status: "public"}
+	idle := &models.Service{Name: "Idle", Status: "public"}
+	for _, s := range []*models.Service{jenkins, gitea, idle} {
+		if err := db.CreateService(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	checks := []models.CheckResult{
+		{ServiceID: jenkins.ID, CheckedAt: now.Add(-48 * time.Hour), Up: false},
+		{ServiceID: jenkins.ID, CheckedAt: now.Add(-3 * time.Minute), Up: true, LatencyMS: 40},
+		{ServiceID: jenkins.ID, CheckedAt: now.Add(-2 * time.Minute), Up: false, Error: "connection refused"},
+		{ServiceID: jenkins.ID, CheckedAt: now.Add(-time.Minute), Up: true, LatencyMS: 25},
+		{ServiceID: gitea.ID, CheckedAt: now.Add(-time.Minute), Up: false, Error: "unexpected status 502"},
+	}
+	for _, c := range checks {
+		if err := db.RecordCheck(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	services, err := db.GetAllServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AttachHealth(services, now.Add(-24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]*models.ServiceHealth)
+	for _, s := range services {
+		byName[s.Name] = s.Health
+	}
+
+	j := byName["Jenkins"]
+	if j.State != models.HealthUp || j.LatencyMS != 25 || j.Checks != 3 {
+		t.Errorf("Unexpected Jenkins health: %+v", j)
+	}
+	if j.Uptime < 66 || j.Uptime > 67 {
+		t.Errorf("Expected 2 of 3 checks up in the window, got %.2f%%", j.Uptime)
+	}
+	if g := byName["Gitea"]; g.State != models.HealthDown || g.Error != "unexpected status 502" || g.Uptime != 0 {
+		t.Errorf("Unexpected Gitea health: %+v", g)
+	}
+	if i := byName["Idle"]; i.State != models.HealthUnknown || i.CheckedAt != nil {
+		t.Errorf("Unexpected health for an unchecked service: %+v", i)
+	}
+
+	pruned, err := db.PruneChecks(now.Add(-24 * time.Hour))
+	if err != nil || pruned != 1 {
+		t.Errorf("Expected 1 check pruned, got %d, %v", pruned, err)
+	}
+
+	// Deleting a service drops its history
+	if err := db.DeleteService(gitea.ID); err != nil {
+		t.Fatal(err)
+	}
+	health, err := db.GetServiceHealth(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := health[gitea.ID]; ok {
+		t.Error("Expected checks of a deleted service to be removed")
+	}
+}
+
+func TestServiceCheckConfigRoundTrip(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	check := &models.ServiceCheck{Type: models.CheckTypeTCP, Target: "db.lan:5432", Timeout: "3s"}
+	_, err := db.SyncContent(nil, []models.Service{{Name: "Postgres", Status: "internal", Check: check}}, SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	services, err := db.GetAllServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := services[0].Check
+	if got == nil || got.Type != models.CheckTypeTCP || got.Target != "db.lan:5432" || got.Timeout != "3s" {
+		t.Errorf("Unexpected check after sync: %+v", got)
+	}
+
+	// Changing only the check counts as a YAML edit
+	changed := models.Service{Name: "Postgres", Status: "internal", Check: &models.ServiceCheck{Type: models.CheckTypeNone}}
+	report, err := db.SyncContent(nil, []models.Service{changed}, SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Services.Updated) != 1 {
+		t.Errorf("Expected the check change to update the row, got %s", report)
+	}
+}
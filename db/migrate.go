@@ -0,0 +1,309 @@
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// Migration is a single numbered schema change with its up and down SQL
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// AppliedMigration is a row from the schema_migrations table
+type AppliedMigration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+// LoadMigrations reads the embedded migration files and returns them ordered by version.
+// Files are named NNNN_description.up.sql and NNNN_description.down.sql.
+func LoadMigrations() ([]Migration, error) {
+	return loadMigrations(migrationsFS, "migrations")
+}
+
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		version, name, direction, err := parseMigrationFilename(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %s: %w", entry.Name(), err)
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, name)
+		}
+
+		switch direction {
+		case "up":
+			m.Up = string(body)
+		case "down":
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// parseMigrationFilename splits "0003_add_status.up.sql" into (3, "add_status", "up")
+func parseMigrationFilename(filename string) (int, string, string, error) {
+	base := strings.TrimSuffix(filename, ".sql")
+	if base == filename {
+		return 0, "", "", fmt.Errorf("migration %s: expected .sql extension", filename)
+	}
+
+	dot := strings.LastIndex(base, ".")
+	if dot == -1 {
+		return 0, "", "", fmt.Errorf("migration %s: expected .up.sql or .down.sql", filename)
+	}
+	direction := base[dot+1:]
+	if direction != "up" && direction != "down" {
+		return 0, "", "", fmt.Errorf("migration %s: unknown direction %q", filename, direction)
+	}
+	base = base[:dot]
+
+	versionStr, name, found := strings.Cut(base, "_")
+	if !found || name == "" {
+		return 0, "", "", fmt.Errorf("migration %s: expected NNNN_name prefix", filename)
+	}
+
+	version, err := strconv.Atoi(versionStr)
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("migration %s: invalid version %q", filename, versionStr)
+	}
+
+	return version, name, direction, nil
+}
+
+// ensureMigrationsTable creates the schema_migrations bookkeeping table
+func (db *DB) ensureMigrationsTable() error {
+	_, err := db.conn.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return err
+}
+
+// AppliedMigrations returns the migrations recorded in schema_migrations, oldest first
+func (db *DB) AppliedMigrations() ([]AppliedMigration, error) {
+	if err := db.ensureMigrationsTable(); err != nil {
+		return nil, fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	rows, err := db.conn.Query(`SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []AppliedMigration
+	for rows.Next() {
+		var m AppliedMigration
+		if err := rows.Scan(&m.Version, &m.Name, &m.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, m)
+	}
+
+	return applied, rows.Err()
+}
+
+// PendingMigrations returns the embedded migrations that have not been applied yet
+func (db *DB) PendingMigrations() ([]Migration, error) {
+	all, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	return db.pending(all)
+}
+
+func (db *DB) pending(all []Migration) ([]Migration, error) {
+	applied, err := db.AppliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[int]bool, len(applied))
+	for _, m := range applied {
+		done[m.Version] = true
+	}
+
+	pending := []Migration{}
+	for _, m := range all {
+		if !done[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+
+	return pending, nil
+}
+
+// Migrate applies all pending migrations in version order.
+// Each migration runs in its own transaction together with its schema_migrations row,
+// so a failure leaves the database at the last successfully applied version.
+func (db *DB) Migrate() ([]Migration, error) {
+	all, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	return db.migrate(all)
+}
+
+func (db *DB) migrate(all []Migration) ([]Migration, error) {
+	pending, err := db.pending(all)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []Migration{}
+	for _, m := range pending {
+		if err := db.applyMigration(m, false); err != nil {
+			return applied, err
+		}
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		applied = append(applied, m)
+	}
+
+	return applied, nil
+}
+
+// DryRunMigrations executes every pending migration inside a transaction that is
+// always rolled back. It returns the migrations that would be applied, or the first error.
+func (db *DB) DryRunMigrations() ([]Migration, error) {
+	pending, err := db.PendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			log.Printf("Error rolling back dry run: %v", err)
+		}
+	}()
+
+	for _, m := range pending {
+		if _, err := tx.Exec(m.Up); err != nil {
+			return nil, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+
+	return pending, nil
+}
+
+// RollbackMigration reverts the most recently applied migration using its down script.
+// It returns the reverted migration, or nil if nothing has been applied.
+func (db *DB) RollbackMigration() (*Migration, error) {
+	applied, err := db.AppliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 {
+		return nil, nil
+	}
+
+	all, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	last := applied[len(applied)-1]
+	for _, m := range all {
+		if m.Version != last.Version {
+			continue
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no down script", m.Version, m.Name)
+		}
+		if err := db.applyMigration(m, true); err != nil {
+			return nil, err
+		}
+		log.Printf("Rolled back migration %04d_%s", m.Version, m.Name)
+		return &m, nil
+	}
+
+	return nil, fmt.Errorf("applied migration %d not found in embedded migrations", last.Version)
+}
+
+// applyMigration runs a migration's up (or down) script and updates schema_migrations atomically
+func (db *DB) applyMigration(m Migration, down bool) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back migration %04d: %v", m.Version, err)
+		}
+	}()
+
+	script := m.Up
+	if down {
+		script = m.Down
+	}
+
+	if _, err := tx.Exec(script); err != nil {
+		return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+
+	if down {
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+	} else {
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.Version, m.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("recording migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,247 @@
+package db
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMigrationsAppliedOnNew(t *testing.T) {
+	dbPath := "test_migrate.db"
+	defer os.Remove(dbPath)
+
+	db, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	all, err := LoadMigrations()
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	applied, err := db.AppliedMigrations()
+	if err != nil {
+		t.Fatalf("Failed to list applied migrations: %v", err)
+	}
+	if len(applied) != len(all) {
+		t.Errorf("Expected %d applied migrations, got %d", len(all), len(applied))
+	}
+
+	pending, err := db.PendingMigrations()
+	if err != nil {
+		t.Fatalf("Failed to list pending migrations: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected no pending migrations, got %d", len(pending))
+	}
+
+	// Re-running is a no-op
+	again, err := db.Migrate()
+	if err != nil {
+		t.Fatalf("Second migrate failed: %v", err)
+	}
+	if len(again) != 0 {
+		t.Errorf("Expected second migrate to apply nothing, applied %d", len(again))
+	}
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	dbPath := "test_migrate_legacy.db"
+	defer os.Remove(dbPath)
+
+	// Simulate a database created before schema_migrations existed
+	legacy, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := legacy.conn.Exec(`
+		CREATE TABLE posts (
+			id TEXT PRIMARY KEY, title TEXT NOT NULL, date DATETIME NOT NULL,
+			category TEXT NOT NULL, summary TEXT NOT NULL, content TEXT NOT NULL,
+			tags TEXT NOT NULL, views INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP, updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		INSERT INTO posts (id, title, date, category, summary, content, tags)
+		VALUES ('legacy', 'Legacy', '2025-01-01', 'Test', 'Summary', 'Content', 'a,b');
+	`); err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+	legacy.Close()
+
+	db, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to migrate legacy database: %v", err)
+	}
+	defer db.Close()
+
+	post, err := db.GetPostByID("legacy")
+	if err != nil {
+		t.Fatalf("Failed to get post: %v", err)
+	}
+	if post == nil || post.Title != "Legacy" {
+		t.Errorf("Expected legacy post to survive migration, got %+v", post)
+	}
+}
+
+func TestDryRunMigrations(t *testing.T) {
+	dbPath := "test_migrate_dryrun.db"
+	defer os.Remove(dbPath)
+
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	pending, err := db.DryRunMigrations()
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if len(pending) == 0 {
+		t.Fatal("Expected pending migrations on an empty database")
+	}
+
+	applied, err := db.AppliedMigrations()
+	if err != nil {
+		t.Fatalf("Failed to list applied migrations: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("Dry run should not record migrations, found %d", len(applied))
+	}
+
+	var tables int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'posts'`).Scan(&tables); err != nil {
+		t.Fatalf("Failed to inspect schema: %v", err)
+	}
+	if tables != 0 {
+		t.Error("Dry run should not leave the posts table behind")
+	}
+}
+
+func TestRollbackMigration(t *testing.T) {
+	dbPath := "test_migrate_rollback.db"
+	defer os.Remove(dbPath)
+
+	db, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	before, _ := db.AppliedMigrations()
+
+	m, err := db.RollbackMigration()
+	if err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if m == nil || m.Version != before[len(before)-1].Version {
+		t.Fatalf("Expected to roll back version %d, got %+v", before[len(before)-1].Version, m)
+	}
+
+	after, _ := db.AppliedMigrations()
+	if len(after) != len(before)-1 {
+		t.Errorf("Expected %d applied migrations after rollback, got %d", len(before)-1, len(after))
+	}
+
+	// Forward again restores the schema
+	if _, err := db.Migrate(); err != nil {
+		t.Fatalf("Re-applying migrations failed: %v", err)
+	}
+}
+
+func TestMigrateStopsAtFailure(t *testing.T) {
+	dbPath := "test_migrate_failure.db"
+	defer os.Remove(dbPath)
+
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	migrations := []Migration{
+		{Version: 1, Name: "good", Up: "CREATE TABLE good (id INTEGER);"},
+		{Version: 2, Name: "bad", Up: "CREATE TABLE bad (id INTEGER); SELECT * FROM missing_table;"},
+		{Version: 3, Name: "never", Up: "CREATE TABLE never (id INTEGER);"},
+	}
+
+	applied, err := db.migrate(migrations)
+	if err == nil {
+		t.Fatal("Expected migration 2 to fail")
+	}
+	if len(applied) != 1 || applied[0].Version != 1 {
+		t.Errorf("Expected only migration 1 to be applied, got %+v", applied)
+	}
+
+	var count int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name IN ('bad', 'never')`).Scan(&count); err != nil {
+		t.Fatalf("Failed to inspect schema: %v", err)
+	}
+	if count != 0 {
+		t.Error("Failed migration should have been rolled back")
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "ordered by version",
+			files: fstest.MapFS{
+				"m/0010_later.up.sql":     {Data: []byte("SELECT 1;")},
+				"m/0002_earlier.up.sql":   {Data: []byte("SELECT 1;")},
+				"m/0002_earlier.down.sql": {Data: []byte("SELECT 1;")},
+			},
+			want: 2,
+		},
+		{
+			name:    "bad direction",
+			files:   fstest.MapFS{"m/0001_x.sideways.sql": {Data: []byte("SELECT 1;")}},
+			wantErr: true,
+		},
+		{
+			name:    "missing version",
+			files:   fstest.MapFS{"m/add_column.up.sql": {Data: []byte("SELECT 1;")}},
+			wantErr: true,
+		},
+		{
+			name:    "down without up",
+			files:   fstest.MapFS{"m/0001_x.down.sql": {Data: []byte("SELECT 1;")}},
+			wantErr: true,
+		},
+		{
+			name: "conflicting names",
+			files: fstest.MapFS{
+				"m/0001_a.up.sql": {Data: []byte("SELECT 1;")},
+				"m/0001_b.up.sql": {Data: []byte("SELECT 1;")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := loadMigrations(tt.files, "m")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadMigrations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(migrations) != tt.want {
+				t.Fatalf("Expected %d migrations, got %d", tt.want, len(migrations))
+			}
+			for i := 1; i < len(migrations); i++ {
+				if migrations[i-1].Version >= migrations[i].Version {
+					t.Errorf("Migrations not sorted: %d before %d", migrations[i-1].Version, migrations[i].Version)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_services_status;
+DROP TABLE IF EXISTS services;
+
+DROP INDEX IF EXISTS idx_posts_views;
+DROP INDEX IF EXISTS idx_posts_category;
+DROP INDEX IF EXISTS idx_posts_date;
+DROP TABLE IF EXISTS posts;
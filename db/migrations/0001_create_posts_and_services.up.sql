@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS posts (
+	id TEXT PRIMARY KEY,
+	title TEXT NOT NULL,
+	date DATETIME NOT NULL,
+	category TEXT NOT NULL,
+	summary TEXT NOT NULL,
+	content TEXT NOT NULL,
+	tags TEXT NOT NULL,
+	views INTEGER DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_posts_date ON posts(date DESC);
+CREATE INDEX IF NOT EXISTS idx_posts_category ON posts(category);
+CREATE INDEX IF NOT EXISTS idx_posts_views ON posts(views DESC);
+
+CREATE TABLE IF NOT EXISTS services (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	description TEXT NOT NULL,
+	url TEXT NOT NULL,
+	tech TEXT NOT NULL,
+	status TEXT NOT NULL,
+	icon TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_services_status ON services(status);
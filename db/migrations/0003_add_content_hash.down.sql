@@ -0,0 +1,2 @@
+ALTER TABLE services DROP COLUMN content_hash;
+ALTER TABLE posts DROP COLUMN content_hash;
@@ -0,0 +1,4 @@
+-- content_hash records the hash of the YAML source a row was last synced from.
+-- Rows created through the admin UI keep an empty hash.
+ALTER TABLE posts ADD COLUMN content_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE services ADD COLUMN content_hash TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,7 @@
+-- status is one of draft, scheduled, published or archived.
+-- Existing posts were already live, so they start out published.
+-- publish_at holds the UTC time a scheduled post goes live; NULL means immediately.
+ALTER TABLE posts ADD COLUMN status TEXT NOT NULL DEFAULT 'published';
+ALTER TABLE posts ADD COLUMN publish_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_posts_status_publish_at ON posts(status, publish_at);
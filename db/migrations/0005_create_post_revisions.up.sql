@@ -0,0 +1,25 @@
+-- post_revisions keeps an immutable snapshot of every post save.
+-- revision counts up from 1 per post. There is deliberately no foreign key
+-- to posts so the history survives a deleted post and can restore it.
+CREATE TABLE IF NOT EXISTS post_revisions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	post_id TEXT NOT NULL,
+	revision INTEGER NOT NULL,
+	author TEXT NOT NULL DEFAULT '',
+	note TEXT NOT NULL DEFAULT '',
+	title TEXT NOT NULL,
+	date DATETIME NOT NULL,
+	category TEXT NOT NULL,
+	summary TEXT NOT NULL,
+	content TEXT NOT NULL,
+	tags TEXT NOT NULL,
+	status TEXT NOT NULL,
+	publish_at DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (post_id, revision)
+);
+
+-- Existing posts start their history with their current content
+INSERT INTO post_revisions (post_id, revision, author, note, title, date, category, summary, content, tags, status, publish_at)
+SELECT id, 1, '', 'Initial revision', title, date, category, summary, content, tags, status, publish_at
+FROM posts;
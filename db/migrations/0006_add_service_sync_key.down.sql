@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_services_sync_key;
+DROP INDEX IF EXISTS idx_services_name;
+ALTER TABLE services DROP COLUMN sync_key;
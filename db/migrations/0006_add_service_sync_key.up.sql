@@ -0,0 +1,9 @@
+-- Services are addressed by their integer id, so a rename through the admin UI
+-- updates the row in place. sync_key keeps the YAML name a row was synced
+-- from, so a renamed service is still matched on the next sync instead of
+-- being inserted again. Rows created through the admin UI keep an empty key.
+ALTER TABLE services ADD COLUMN sync_key TEXT NOT NULL DEFAULT '';
+UPDATE services SET sync_key = name WHERE content_hash != '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_services_name ON services(name);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_services_sync_key ON services(sync_key) WHERE sync_key != '';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS service_checks;
+ALTER TABLE services DROP COLUMN check_config;
@@ -0,0 +1,17 @@
+-- check_config holds a service's models.ServiceCheck as JSON, or '' for the default HTTP check
+ALTER TABLE services ADD COLUMN check_config TEXT NOT NULL DEFAULT '';
+
+-- service_checks records every probe result; old rows are pruned by the prober
+CREATE TABLE IF NOT EXISTS service_checks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	service_id INTEGER NOT NULL,
+	checked_at DATETIME NOT NULL,
+	up INTEGER NOT NULL,
+	latency_ms INTEGER NOT NULL DEFAULT 0,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	error TEXT NOT NULL DEFAULT '',
+	FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_checks_service_time ON service_checks(service_id, checked_at DESC);
+CREATE INDEX IF NOT EXISTS idx_service_checks_time ON service_checks(checked_at);
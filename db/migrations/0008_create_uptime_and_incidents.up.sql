@@ -0,0 +1,35 @@
+-- service_uptime holds check counts rolled up per hour and per day.
+-- Raw service_checks rows are pruned once their hour has been rolled up.
+CREATE TABLE IF NOT EXISTS service_uptime (
+	service_id INTEGER NOT NULL,
+	bucket TEXT NOT NULL CHECK (bucket IN ('hour', 'day')),
+	period_start DATETIME NOT NULL,
+	checks INTEGER NOT NULL,
+	up INTEGER NOT NULL,
+	PRIMARY KEY (service_id, bucket, period_start),
+	FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+);
+
+-- An incident is opened when a service's check fails and resolved when it next passes
+CREATE TABLE IF NOT EXISTS incidents (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	service_id INTEGER NOT NULL,
+	started_at DATETIME NOT NULL,
+	resolved_at DATETIME,
+	cause TEXT NOT NULL DEFAULT '',
+	FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_incidents_started ON incidents(started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_incidents_open ON incidents(service_id) WHERE resolved_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS incident_notes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	incident_id INTEGER NOT NULL,
+	author TEXT NOT NULL DEFAULT '',
+	body TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	FOREIGN KEY (incident_id) REFERENCES incidents(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_incident_notes_incident ON incident_notes(incident_id);
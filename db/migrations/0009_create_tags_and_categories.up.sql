@@ -0,0 +1,66 @@
+-- Tags and categories get their own tables. Names are unique ignoring case;
+-- the first spelling seen becomes the canonical one. posts.tags and
+-- posts.category stay as a denormalized copy of the canonical names, which
+-- the search index and revisions read.
+CREATE TABLE IF NOT EXISTS categories (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL COLLATE NOCASE UNIQUE,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL COLLATE NOCASE UNIQUE,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- position keeps the order tags were listed in on the post
+CREATE TABLE IF NOT EXISTS post_tags (
+	post_id TEXT NOT NULL,
+	tag_id INTEGER NOT NULL,
+	position INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (post_id, tag_id),
+	FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE ON UPDATE CASCADE,
+	FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_tags_tag ON post_tags(tag_id);
+
+ALTER TABLE posts ADD COLUMN category_id INTEGER REFERENCES categories(id);
+
+CREATE INDEX IF NOT EXISTS idx_posts_category_id ON posts(category_id);
+
+-- Backfill categories, oldest posts first so their spelling wins
+INSERT OR IGNORE INTO categories (name)
+SELECT trim(category) FROM posts WHERE trim(category) != '' ORDER BY date, id;
+
+UPDATE posts SET category_id = (SELECT id FROM categories WHERE name = trim(posts.category));
+UPDATE posts SET category = COALESCE((SELECT name FROM categories WHERE id = posts.category_id), '');
+
+-- Split the comma-joined tags into one row per tag
+CREATE TEMP TABLE split_tags AS
+WITH RECURSIVE split(post_id, date, position, tag, rest) AS (
+	SELECT id, date, -1, '', tags || ',' FROM posts WHERE tags != ''
+	UNION ALL
+	SELECT post_id, date, position + 1,
+		trim(substr(rest, 1, instr(rest, ',') - 1)),
+		substr(rest, instr(rest, ',') + 1)
+	FROM split WHERE rest != ''
+)
+SELECT post_id, date, position, tag FROM split WHERE position >= 0 AND tag != '';
+
+INSERT OR IGNORE INTO tags (name)
+SELECT tag FROM split_tags ORDER BY date, post_id, position;
+
+INSERT OR IGNORE INTO post_tags (post_id, tag_id, position)
+SELECT s.post_id, t.id, s.position
+FROM split_tags s JOIN tags t ON t.name = s.tag
+ORDER BY s.post_id, s.position;
+
+DROP TABLE split_tags;
+
+UPDATE posts SET tags = COALESCE((
+	SELECT group_concat(t.name, ',' ORDER BY pt.position)
+	FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+	WHERE pt.post_id = posts.id
+), '');
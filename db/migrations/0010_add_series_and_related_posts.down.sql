@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS related_posts;
+ALTER TABLE post_revisions DROP COLUMN series_order;
+ALTER TABLE post_revisions DROP COLUMN series;
+DROP INDEX IF EXISTS idx_posts_series;
+ALTER TABLE posts DROP COLUMN series_order;
+ALTER TABLE posts DROP COLUMN series;
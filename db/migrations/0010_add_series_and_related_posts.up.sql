@@ -0,0 +1,20 @@
+-- series groups multi-part posts; series_order is the part number within it.
+-- Posts outside a series keep an empty name.
+ALTER TABLE posts ADD COLUMN series TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN series_order INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_posts_series ON posts(series, series_order) WHERE series != '';
+
+ALTER TABLE post_revisions ADD COLUMN series TEXT NOT NULL DEFAULT '';
+ALTER TABLE post_revisions ADD COLUMN series_order INTEGER NOT NULL DEFAULT 0;
+
+-- related_posts caches the best related posts for each post. It is derived
+-- data, recomputed whenever posts change.
+CREATE TABLE IF NOT EXISTS related_posts (
+	post_id TEXT NOT NULL,
+	related_id TEXT NOT NULL,
+	score REAL NOT NULL,
+	PRIMARY KEY (post_id, related_id),
+	FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE ON UPDATE CASCADE,
+	FOREIGN KEY (related_id) REFERENCES posts(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
@@ -0,0 +1,17 @@
+-- Admin accounts. Usernames are unique ignoring case. password_hash is empty
+-- until the user accepts their invite; invite_token_hash is the SHA-256 of the
+-- outstanding invite or reset link, never the token itself.
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL COLLATE NOCASE UNIQUE,
+	email TEXT NOT NULL DEFAULT '',
+	password_hash TEXT NOT NULL DEFAULT '',
+	role TEXT NOT NULL CHECK (role IN ('admin', 'editor', 'moderator')),
+	disabled INTEGER NOT NULL DEFAULT 0,
+	invite_token_hash TEXT,
+	invite_expires_at DATETIME,
+	created_at DATETIME NOT NULL,
+	last_login_at DATETIME
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_invite ON users(invite_token_hash) WHERE invite_token_hash IS NOT NULL;
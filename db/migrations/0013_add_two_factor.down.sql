@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS login_challenges;
+DROP INDEX IF EXISTS idx_trusted_devices_user;
+DROP TABLE IF EXISTS trusted_devices;
+DROP TABLE IF EXISTS recovery_codes;
+ALTER TABLE users DROP COLUMN totp_last_step;
+ALTER TABLE users DROP COLUMN totp_enabled;
+ALTER TABLE users DROP COLUMN totp_secret;
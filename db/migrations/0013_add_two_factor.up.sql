@@ -0,0 +1,37 @@
+-- Two-factor sign-in. totp_secret is set when a user starts enrolling and is
+-- only checked once totp_enabled; totp_last_step is the time step of the last
+-- code used, so a code can't be used twice.
+ALTER TABLE users ADD COLUMN totp_secret TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN totp_enabled INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0;
+
+-- Single-use codes for signing in without the authenticator app, stored as
+-- SHA-256 hashes
+CREATE TABLE IF NOT EXISTS recovery_codes (
+	user_id INTEGER NOT NULL,
+	code_hash TEXT NOT NULL,
+	used_at DATETIME,
+	PRIMARY KEY (user_id, code_hash),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Browsers that skip the code step. id is the SHA-256 of the cookie's token.
+CREATE TABLE IF NOT EXISTS trusted_devices (
+	id TEXT PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	created_at DATETIME NOT NULL,
+	expires_at DATETIME NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_trusted_devices_user ON trusted_devices(user_id);
+
+-- Sign-ins that passed the password step and are waiting for a code. id is
+-- the SHA-256 of the cookie's token.
+CREATE TABLE IF NOT EXISTS login_challenges (
+	id TEXT PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	expires_at DATETIME NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+	"github.com/tinotenda-alfaneti/homelabsite/search"
+)
+
+// Post listing page sizes
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ErrInvalidCursor is returned for a cursor that no listing produced
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// postListColumns is postColumns with an empty content, for listings that
+// don't show post bodies
+const postListColumns = `id, title, date, category, summary, '', tags, COALESCE(views, 0), status, publish_at, series, series_order, updated_at`
+
+// PageOptions selects one page of a post listing
+type PageOptions struct {
+	// Limit is the page size: DefaultPageSize when 0, at most MaxPageSize
+	Limit int
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string
+	// Content loads post bodies, which listings leave out unless asked for
+	Content bool
+}
+
+func (o PageOptions) limit() int {
+	switch {
+	case o.Limit <= 0:
+		return DefaultPageSize
+	case o.Limit > MaxPageSize:
+		return MaxPageSize
+	}
+	return o.Limit
+}
+
+func (o PageOptions) columns() string {
+	if o.Content {
+		return postColumns
+	}
+	return postListColumns
+}
+
+// cursor is the position of the last post on a page. Listings are ordered by
+// (date, id) descending; ranked searches are ordered by score first, so their
+// cursors carry it too. Date is the stored text, so it compares exactly.
+type cursor struct {
+	Score *float64 `json:"s,omitempty"`
+	Date  string   `json:"d"`
+	ID    string   `json:"i"`
+}
+
+func (c cursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor reads a cursor from PageOptions, returning nil for the first page
+func decodeCursor(s string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Date == "" || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// ListPosts returns one page of published posts, newest first, optionally
+// limited to a category, and the cursor for the next page
+func (db *DB) ListPosts(category string, page PageOptions) ([]models.Post, string, error) {
+	q := &search.Query{}
+	if category != "" {
+		q.Categories = []string{category}
+	}
+	return db.listPosts(q, page)
+}
+
+// ListPostsByTag returns one page of published posts with a tag, newest
+// first, and the cursor for the next page
+func (db *DB) ListPostsByTag(tag string, page PageOptions) ([]models.Post, string, error) {
+	return db.listPosts(&search.Query{Tags: []string{tag}}, page)
+}
+
+func (db *DB) listPosts(q *search.Query, page PageOptions) ([]models.Post, string, error) {
+	results, next, err := db.Search(q, page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	posts := make([]models.Post, len(results))
+	for i, r := range results {
+		posts[i] = r.Post
+	}
+	return posts, next, nil
+}
+
+// ListPostsForAdmin returns one page of posts of every status, newest first
+func (db *DB) ListPostsForAdmin(page PageOptions) ([]models.Post, string, error) {
+	after, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := `SELECT ` + page.columns() + `, CAST(date AS TEXT) FROM posts`
+	var args []interface{}
+	if after != nil {
+		query += ` WHERE (date, id) < (?, ?)`
+		args = append(args, after.Date, after.ID)
+	}
+	limit := page.limit()
+	query += ` ORDER BY date DESC, id DESC LIMIT ?`
+	args = append(args, limit+1)
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	posts := []models.Post{}
+	var last cursor
+	for rows.Next() {
+		if len(posts) == limit {
+			return posts, last.encode(), nil
+		}
+		p, err := scanPost(extraScanner{rows, []interface{}{&last.Date}})
+		if err != nil {
+			return nil, "", err
+		}
+		posts = append(posts, p)
+		last.ID = p.ID
+	}
+
+	return posts, "", rows.Err()
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+	"github.com/tinotenda-alfaneti/homelabsite/search"
+)
+
+// savePagedPosts saves p1..p5 where p3 and p4 share a date, so the id breaks the tie
+func savePagedPosts(t *testing.T, db *DB) {
+	t.Helper()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	dates := map[string]time.Time{
+		"p1": base.Add(5 * time.Hour),
+		"p2": base.Add(4 * time.Hour),
+		"p3": base.Add(3 * time.Hour),
+		"p4": base.Add(3 * time.Hour),
+		"p5": base.Add(1 * time.Hour),
+	}
+	for id, date := range dates {
+		post := &models.Post{ID: id, Title: "Post " + id, Date: date, Category: "Paging", Content: "body of " + id}
+		if err := db.SavePost(post); err != nil {
+			t.Fatal(err)
+		}
+	}
+	draft := &models.Post{ID: "p0", Title: "Draft", Date: base.Add(6 * time.Hour), Category: "Paging", Status: models.PostStatusDraft}
+	if err := db.SavePost(draft); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListPostsPages(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	savePagedPosts(t, db)
+
+	var pages [][]string
+	cursor := ""
+	for i := 0; i < 5; i++ {
+		posts, next, err := db.ListPosts("", PageOptions{Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, p := range posts {
+			ids = append(ids, p.ID)
+			if p.Content != "" {
+				t.Errorf("Expected listings without content, got %q for %s", p.Content, p.ID)
+			}
+		}
+		pages = append(pages, ids)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	want := [][]string{{"p1", "p2"}, {"p4", "p3"}, {"p5"}}
+	if fmt.Sprint(pages) != fmt.Sprint(want) {
+		t.Errorf("Expected pages %v, got %v", want, pages)
+	}
+
+	posts, _, err := db.ListPosts("paging", PageOptions{Limit: 1, Content: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || posts[0].Content != "body of p1" {
+		t.Errorf("Expected the first post with content, got %+v", posts)
+	}
+
+	if _, _, err := db.ListPosts("", PageOptions{Cursor: "not-a-cursor"}); err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestListPostsForAdminPages(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	savePagedPosts(t, db)
+
+	first, next, err := db.ListPostsForAdmin(PageOptions{Limit: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 4 || first[0].ID != "p0" || next == "" {
+		t.Fatalf("Expected the draft first and a next page, got %+v", first)
+	}
+
+	rest, next, err := db.ListPostsForAdmin(PageOptions{Limit: 4, Cursor: next})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 2 || rest[0].ID != "p3" || rest[1].ID != "p5" || next != "" {
+		t.Errorf("Expected p3 and p5 on the last page, got %+v (next %q)", rest, next)
+	}
+}
+
+func TestSearchPagesRankedResults(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	if !db.fts {
+		t.Skip("SQLite built without FTS5; run with -tags sqlite_fts5")
+	}
+	savePagedPosts(t, db)
+
+	q, _ := search.Parse("body")
+	seen := map[string]bool{}
+	cursor := ""
+	for i := 0; i < 10; i++ {
+		results, next, err := db.Search(q, PageOptions{Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			if seen[r.ID] {
+				t.Errorf("%s appeared on two pages", r.ID)
+			}
+			seen[r.ID] = true
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if len(seen) != 5 {
+		t.Errorf("Expected all 5 published posts across pages, got %v", seen)
+	}
+
+	// A listing cursor has no score, so it can't continue a ranked search
+	_, listCursor, _ := db.ListPosts("", PageOptions{Limit: 1})
+	if _, _, err := db.Search(q, PageOptions{Cursor: listCursor}); err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+	"github.com/tinotenda-alfaneti/homelabsite/related"
+)
+
+// relatedPerPost is how many related posts are cached for each post. More
+// are kept than a page shows, since some may be unpublished when it is read.
+const relatedPerPost = 10
+
+// RefreshRelatedPosts recomputes the related posts of every post. Saves,
+// deletes, syncs and merges refresh them; this is for startup.
+func (db *DB) RefreshRelatedPosts() error {
+	return db.withTx(refreshRelatedPosts)
+}
+
+// refreshRelatedPosts scores every pair of posts and replaces the cached
+// related_posts. Text similarity depends on every post's words, so a change
+// to one post can change the scores of all of them.
+func refreshRelatedPosts(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, category, tags, title || ' ' || summary || ' ' || content FROM posts`)
+	if err != nil {
+		return err
+	}
+	var docs []related.Document
+	for rows.Next() {
+		var d related.Document
+		var tags string
+		if err := rows.Scan(&d.ID, &d.Category, &tags, &d.Text); err != nil {
+			rows.Close()
+			return err
+		}
+		d.Tags = parseTagsFromString(tags)
+		docs = append(docs, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM related_posts`); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO related_posts (post_id, related_id, score) VALUES (?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for id, matches := range related.Rank(docs, relatedPerPost) {
+		for _, m := range matches {
+			if _, err := stmt.Exec(id, m.ID, m.Score); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GetRelatedPosts returns up to limit published posts related to a post, most related first
+func (db *DB) GetRelatedPosts(id string, limit int) ([]models.Post, error) {
+	query := `SELECT ` + postListColumns + `
+		FROM related_posts r
+		JOIN posts ON posts.id = r.related_id
+		WHERE r.post_id = ? AND ` + publishedFilter + `
+		ORDER BY r.score DESC, posts.id
+		LIMIT ?`
+	return db.queryPosts(query, id, time.Now().UTC(), limit)
+}
+
+// GetSeries returns the published posts of a series in order, oldest first
+// among posts with the same order
+func (db *DB) GetSeries(name string) ([]models.Post, error) {
+	if name == "" {
+		return nil, nil
+	}
+	query := `SELECT ` + postListColumns + `
+		FROM posts
+		WHERE series = ? AND ` + publishedFilter + `
+		ORDER BY series_order, date, id`
+	return db.queryPosts(query, name, time.Now().UTC())
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestRelatedPostsFollowSaves(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	past := time.Now().Add(-time.Hour)
+	posts := []*models.Post{
+		{ID: "k3s", Title: "k3s on a pi", Date: past, Category: "Kubernetes", Tags: []string{"k8s", "raspberry-pi"}, Content: "k3s cluster install"},
+		{ID: "helm", Title: "Helm charts", Date: past, Category: "Kubernetes", Tags: []string{"k8s", "helm"}, Content: "helm cluster packaging"},
+		{ID: "draft", Title: "Draft", Date: past, Category: "Kubernetes", Tags: []string{"k8s"}, Status: models.PostStatusDraft},
+		{ID: "bread", Title: "Sourdough", Date: past, Category: "Food", Content: "flour water salt"},
+	}
+	for _, p := range posts {
+		if err := db.SavePost(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	related, err := db.GetRelatedPosts("k3s", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(related) != 1 || related[0].ID != "helm" {
+		t.Errorf("Expected only the published helm post, got %v", postIDs(related))
+	}
+
+	// Moving helm out of Kubernetes is picked up on save
+	posts[1].Category, posts[1].Tags, posts[1].Content = "Food", nil, "flour water salt"
+	if err := db.SavePost(posts[1]); err != nil {
+		t.Fatal(err)
+	}
+	if related, _ := db.GetRelatedPosts("k3s", 3); len(related) != 0 {
+		t.Errorf("Expected no related posts after the edit, got %v", postIDs(related))
+	}
+	if related, _ := db.GetRelatedPosts("bread", 3); len(related) != 1 || related[0].ID != "helm" {
+		t.Errorf("Expected helm related to bread after the edit, got %v", postIDs(related))
+	}
+
+	if err := db.DeletePost("helm"); err != nil {
+		t.Fatal(err)
+	}
+	if related, _ := db.GetRelatedPosts("bread", 3); len(related) != 0 {
+		t.Errorf("Expected deleted posts to drop out, got %v", postIDs(related))
+	}
+}
+
+func TestGetSeries(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	past := time.Now().Add(-time.Hour)
+	for _, p := range []*models.Post{
+		{ID: "part-2", Title: "Part 2", Date: past.Add(-time.Hour), Series: "Cluster", SeriesOrder: 2},
+		{ID: "part-1", Title: "Part 1", Date: past, Series: " Cluster ", SeriesOrder: 1},
+		{ID: "part-3", Title: "Part 3", Date: past, Series: "Cluster", SeriesOrder: 3, Status: models.PostStatusDraft},
+		{ID: "other", Title: "Other", Date: past},
+	} {
+		if err := db.SavePost(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	series, err := db.GetSeries("Cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 2 || series[0].ID != "part-1" || series[1].ID != "part-2" {
+		t.Fatalf("Expected published parts in order, got %v", postIDs(series))
+	}
+	if series[1].SeriesOrder != 2 || series[1].Series != "Cluster" {
+		t.Errorf("Expected series fields to round-trip, got %+v", series[1])
+	}
+}
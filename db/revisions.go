@@ -0,0 +1,131 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// syncAuthor is the author recorded on revisions written by SyncContent
+const syncAuthor = "sync"
+
+// insertRevision records the post's current fields as its next revision and
+// returns the new revision number
+func insertRevision(tx *sql.Tx, post *models.Post, author, note string) (int, error) {
+	var next int
+	err := tx.QueryRow(`SELECT COALESCE(MAX(revision), 0) + 1 FROM post_revisions WHERE post_id = ?`, post.ID).Scan(&next)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO post_revisions (post_id, revision, author, note, title, date, category, summary, content, tags, status, publish_at,
+			series, series_order, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, post.ID, next, author, note, post.Title, post.Date, post.Category, post.Summary, post.Content, joinTags(post.Tags),
+		postStatus(post), publishTime(post), post.Series, post.SeriesOrder, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("recording revision: %w", err)
+	}
+	return next, nil
+}
+
+// ListRevisions returns a post's revisions, newest first. Only the metadata
+// and title are filled in; use GetRevision for the full snapshot.
+func (db *DB) ListRevisions(postID string) ([]models.PostRevision, error) {
+	rows, err := db.conn.Query(`
+		SELECT post_id, revision, author, note, created_at, title, status
+		FROM post_revisions
+		WHERE post_id = ?
+		ORDER BY revision DESC
+	`, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.PostRevision{}
+	for rows.Next() {
+		var r models.PostRevision
+		var createdAt sql.NullTime
+		if err := rows.Scan(&r.PostID, &r.Revision, &r.Author, &r.Note, &createdAt, &r.Post.Title, &r.Post.Status); err != nil {
+			return nil, err
+		}
+		r.Post.ID = r.PostID
+		r.CreatedAt = createdAt.Time
+		revisions = append(revisions, r)
+	}
+
+	return revisions, rows.Err()
+}
+
+// GetRevision retrieves a full revision snapshot, or nil if it doesn't exist
+func (db *DB) GetRevision(postID string, revision int) (*models.PostRevision, error) {
+	var r models.PostRevision
+	var tags string
+	var createdAt, publishAt sql.NullTime
+	err := db.conn.QueryRow(`
+		SELECT post_id, revision, author, note, created_at,
+			title, date, category, summary, content, tags, status, publish_at, series, series_order
+		FROM post_revisions
+		WHERE post_id = ? AND revision = ?
+	`, postID, revision).Scan(&r.PostID, &r.Revision, &r.Author, &r.Note, &createdAt,
+		&r.Post.Title, &r.Post.Date, &r.Post.Category, &r.Post.Summary, &r.Post.Content, &tags, &r.Post.Status, &publishAt,
+		&r.Post.Series, &r.Post.SeriesOrder)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	r.Post.ID = r.PostID
+	r.CreatedAt = createdAt.Time
+	if tags != "" {
+		r.Post.Tags = parseTagsFromString(tags)
+	}
+	if publishAt.Valid {
+		t := publishAt.Time
+		r.Post.PublishAt = &t
+	}
+	return &r, nil
+}
+
+// RestoreRevision saves an old revision's snapshot as the post's current
+// content. The restore is itself recorded as a new revision, so it can be
+// undone the same way. View counts, status and publish time are kept, so
+// restoring old content never publishes or unpublishes a post. Returns the restored post, or
+// nil if the revision doesn't exist.
+func (db *DB) RestoreRevision(postID string, revision int, author string) (*models.Post, error) {
+	rev, err := db.GetRevision(postID, revision)
+	if err != nil || rev == nil {
+		return nil, err
+	}
+
+	post := rev.Post
+	current, err := db.GetPostByID(postID)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil {
+		post.Views = current.Views
+		post.Status = current.Status
+		post.PublishAt = current.PublishAt
+	}
+
+	err = db.withTx(func(tx *sql.Tx) error {
+		if err := savePost(tx, &post); err != nil {
+			return err
+		}
+		if _, err := insertRevision(tx, &post, author, fmt.Sprintf("Restored from revision %d", revision)); err != nil {
+			return err
+		}
+		return refreshRelatedPosts(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestSavePostRecordsRevisions(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	post := &models.Post{ID: "rev-post", Title: "First", Date: time.Now(), Content: "one\n", Tags: []string{"a"}}
+	if err := db.SavePostAs(post, "admin"); err != nil {
+		t.Fatalf("Failed to save post: %v", err)
+	}
+	post.Title = "Second"
+	post.Content = "one\ntwo\n"
+	if err := db.SavePost(post); err != nil {
+		t.Fatalf("Failed to save post: %v", err)
+	}
+
+	revisions, err := db.ListRevisions("rev-post")
+	if err != nil {
+		t.Fatalf("Failed to list revisions: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("Expected 2 revisions, got %d", len(revisions))
+	}
+	if revisions[0].Revision != 2 || revisions[0].Post.Title != "Second" {
+		t.Errorf("Expected newest revision first, got %+v", revisions[0])
+	}
+	if revisions[1].Author != "admin" || revisions[1].CreatedAt.IsZero() {
+		t.Errorf("Unexpected first revision metadata: %+v", revisions[1])
+	}
+	if revisions[0].Post.Content != "" {
+		t.Error("Expected ListRevisions to omit content")
+	}
+
+	first, err := db.GetRevision("rev-post", 1)
+	if err != nil {
+		t.Fatalf("Failed to get revision: %v", err)
+	}
+	if first.Post.Title != "First" || first.Post.Content != "one\n" || len(first.Post.Tags) != 1 {
+		t.Errorf("Unexpected snapshot: %+v", first.Post)
+	}
+
+	missing, err := db.GetRevision("rev-post", 9)
+	if err != nil || missing != nil {
+		t.Errorf("Expected nil for a missing revision, got %+v, %v", missing, err)
+	}
+}
+
+func TestRestoreRevision(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	post := &models.Post{ID: "restore-me", Title: "Good", Date: time.Now(), Content: "good content"}
+	if err := db.SavePost(post); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.IncrementPostViews("restore-me"); err != nil {
+		t.Fatal(err)
+	}
+	post.Title = "Bad"
+	post.Content = "oops"
+	post.Status = models.PostStatusDraft
+	if err := db.SavePost(post); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := db.RestoreRevision("restore-me", 1, "admin")
+	if err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	if restored == nil || restored.Title != "Good" {
+		t.Fatalf("Unexpected restored post: %+v", restored)
+	}
+
+	current, err := db.GetPostByID("restore-me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Content != "good content" || current.Status != models.PostStatusDraft {
+		t.Errorf("Expected the content restored and the post still a draft, got %+v", current)
+	}
+	if current.Views != 1 {
+		t.Errorf("Expected views to survive a restore, got %d", current.Views)
+	}
+
+	revisions, err := db.ListRevisions("restore-me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 3 {
+		t.Fatalf("Expected the restore to add a third revision, got %d", len(revisions))
+	}
+	if revisions[0].Note != "Restored from revision 1" || revisions[0].Author != "admin" {
+		t.Errorf("Unexpected restore revision: %+v", revisions[0])
+	}
+
+	missing, err := db.RestoreRevision("restore-me", 42, "admin")
+	if err != nil || missing != nil {
+		t.Errorf("Expected nil for a missing revision, got %+v, %v", missing, err)
+	}
+}
+
+func TestSyncRecordsRevisions(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	posts := []models.Post{{ID: "synced", Title: "From YAML", Date: time.Now(), Content: "v1"}}
+	if _, err := db.SyncContent(posts, nil, SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// An unchanged sync doesn't add a revision
+	if _, err := db.SyncContent(posts, nil, SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	posts[0].Content = "v2"
+	if _, err := db.SyncContent(posts, nil, SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err := db.ListRevisions("synced")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("Expected 2 revisions, got %d", len(revisions))
+	}
+	if revisions[0].Author != syncAuthor {
+		t.Errorf("Expected author %q, got %q", syncAuthor, revisions[0].Author)
+	}
+}
+
+func TestRestoreRevisionKeepsStatus(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	post := &models.Post{ID: "live", Title: "First draft", Date: time.Now(), Content: "draft content", Status: models.PostStatusDraft}
+	if err := db.SavePost(post); err != nil {
+		t.Fatal(err)
+	}
+	publishAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	post.Title = "Live"
+	post.Status = models.PostStatusPublished
+	post.PublishAt = &publishAt
+	if err := db.SavePost(post); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.RestoreRevision("live", 1, "admin"); err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	current, err := db.GetPostByID("live")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Title != "First draft" || current.Status != models.PostStatusPublished ||
+		current.PublishAt == nil || !current.PublishAt.Equal(publishAt) {
+		t.Errorf("Expected the draft's content on a still published post, got %+v", current)
+	}
+}
@@ -1,145 +1,313 @@
 package db
 
 import (
+	"database/sql"
+	"html"
+	"html/template"
+	"log"
 	"strings"
+	"time"
 
 	"github.com/tinotenda-alfaneti/homelabsite/models"
+	"github.com/tinotenda-alfaneti/homelabsite/search"
 )
 
-// SearchPosts performs full-text search on posts
-func (db *DB) SearchPosts(query string) ([]models.Post, error) {
-	if query == "" {
-		return []models.Post{}, nil
-	}
-
-	// Search in title, content, tags, and category
-	searchQuery := `
-		SELECT id, title, date, category, summary, content, tags 
-		FROM posts 
-		WHERE 
-			title LIKE ? OR 
-			content LIKE ? OR 
-			category LIKE ? OR 
-			tags LIKE ?
-		ORDER BY date DESC
-	`
-
-	searchPattern := "%" + query + "%"
-	rows, err := db.conn.Query(searchQuery, searchPattern, searchPattern, searchPattern, searchPattern)
-	if err != nil {
-		return nil, err
+// searchIndexSchema creates the posts_fts full-text index and the triggers
+// that keep it in step with posts. post_id is stored but not indexed.
+const searchIndexSchema = `
+CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5(
+	post_id UNINDEXED, title, summary, content, tags, category,
+	tokenize = 'porter unicode61'
+);
+
+CREATE TRIGGER IF NOT EXISTS posts_fts_insert AFTER INSERT ON posts BEGIN
+	INSERT INTO posts_fts (post_id, title, summary, content, tags, category)
+	VALUES (new.id, new.title, new.summary, new.content, new.tags, new.category);
+END;
+
+CREATE TRIGGER IF NOT EXISTS posts_fts_update AFTER UPDATE OF id, title, summary, content, tags, category ON posts BEGIN
+	DELETE FROM posts_fts WHERE post_id = old.id;
+	INSERT INTO posts_fts (post_id, title, summary, content, tags, category)
+	VALUES (new.id, new.title, new.summary, new.content, new.tags, new.category);
+END;
+
+CREATE TRIGGER IF NOT EXISTS posts_fts_delete AFTER DELETE ON posts BEGIN
+	DELETE FROM posts_fts WHERE post_id = old.id;
+END;
+`
+
+// searchTriggers are dropped when FTS5 is unavailable, since writes to posts
+// would fail on triggers that reference the fts5 module
+var searchTriggers = []string{"posts_fts_insert", "posts_fts_update", "posts_fts_delete"}
+
+// BM25 weights for the posts_fts columns, in order: post_id, title, summary,
+// content, tags, category. A match in the title counts ten times one in the body.
+const searchWeights = `0.0, 10.0, 5.0, 1.0, 4.0, 3.0`
+
+// Snippet delimiters, replaced with <mark> tags once the text is escaped
+const (
+	markStart = "\x02"
+	markEnd   = "\x03"
+)
+
+// initSearchIndex sets up the full-text index when SQLite was built with FTS5
+// (go build -tags sqlite_fts5). Otherwise search falls back to LIKE matching.
+func (db *DB) initSearchIndex() error {
+	var enabled bool
+	if err := db.conn.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled); err != nil {
+		return err
+	}
+	if !enabled {
+		log.Printf("SQLite built without FTS5 (build with -tags sqlite_fts5); search uses LIKE matching")
+		for _, name := range searchTriggers {
+			if _, err := db.conn.Exec(`DROP TRIGGER IF EXISTS ` + name); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
-	defer rows.Close()
 
-	var posts []models.Post
-	for rows.Next() {
-		var p models.Post
-		var tags string
-		if err := rows.Scan(&p.ID, &p.Title, &p.Date, &p.Category, &p.Summary, &p.Content, &tags); err != nil {
-			return nil, err
+	// Rebuild on every start so posts written while the triggers were
+	// missing are indexed; it is cheap at blog scale
+	err := db.withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(searchIndexSchema); err != nil {
+			return err
 		}
-		if tags != "" {
-			p.Tags = parseTagsFromString(tags)
+		if _, err := tx.Exec(`DELETE FROM posts_fts`); err != nil {
+			return err
 		}
-		posts = append(posts, p)
+		_, err := tx.Exec(`INSERT INTO posts_fts (post_id, title, summary, content, tags, category)
+			SELECT id, title, summary, content, tags, category FROM posts`)
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
-	return posts, rows.Err()
+	db.fts = true
+	return nil
 }
 
-// SearchPostsByTag finds posts that have a specific tag
-func (db *DB) SearchPostsByTag(tag string) ([]models.Post, error) {
-	if tag == "" {
-		return []models.Post{}, nil
+// SearchPosts finds published posts matching every word in query, best match
+// first. The last word also matches as a prefix, so results update while typing.
+func (db *DB) SearchPosts(query string) ([]models.SearchResult, error) {
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return []models.SearchResult{}, nil
 	}
+	results, _, err := db.Search(&search.Query{Terms: words}, PageOptions{Limit: MaxPageSize, Content: true})
+	return results, err
+}
 
-	// Since tags are stored as comma-separated, we need to use LIKE
-	searchQuery := `
-		SELECT id, title, date, category, summary, content, tags 
-		FROM posts 
-		WHERE tags LIKE ?
-		ORDER BY date DESC
-	`
+// likeText matches one word or phrase against the post text when FTS5 is unavailable
+const likeText = `(title LIKE ? ESCAPE '\' OR summary LIKE ? ESCAPE '\' OR content LIKE ? ESCAPE '\' OR
+	category LIKE ? ESCAPE '\' OR tags LIKE ? ESCAPE '\')`
 
-	// Match tag exactly or as part of comma-separated list
-	patterns := []string{
-		tag,               // exact match
-		tag + ",%",        // at start
-		"%," + tag,        // at end
-		"%," + tag + ",%", // in middle
+// Search runs a parsed query as a single statement over published posts and
+// returns one page of results with the cursor for the next, empty on the last.
+// With words or phrases, results are ranked by relevance and carry a
+// highlighted snippet; otherwise they are newest first.
+func (db *DB) Search(q *search.Query, page PageOptions) ([]models.SearchResult, string, error) {
+	after, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return nil, "", err
 	}
+	ranked := q.HasText() && db.fts
 
-	postsMap := make(map[string]models.Post)
+	columns := page.columns() + `, CAST(date AS TEXT), 0, ''`
+	var join string
+	var where []string
+	var args []interface{}
+	order := `date DESC, posts.id DESC`
 
-	for _, pattern := range patterns {
-		rows, err := db.conn.Query(searchQuery, "%"+pattern+"%")
-		if err != nil {
-			return nil, err
+	if ranked {
+		columns = page.columns() + `, CAST(date AS TEXT), m.score, m.snippet`
+		join = `
+		JOIN (
+			SELECT post_id,
+				-bm25(posts_fts, ` + searchWeights + `) AS score,
+				snippet(posts_fts, -1, ?, ?, '…', 24) AS snippet
+			FROM posts_fts
+			WHERE posts_fts MATCH ?
+		) m ON m.post_id = posts.id`
+		args = append(args, markStart, markEnd, ftsMatch(q.Terms, q.Phrases))
+		order = `m.score DESC, date DESC, posts.id DESC`
+	} else {
+		for _, text := range append(append([]string{}, q.Terms...), q.Phrases...) {
+			where = append(where, likeText)
+			args = appendLike(args, text)
 		}
+	}
 
-		for rows.Next() {
-			var p models.Post
-			var tags string
-			if err := rows.Scan(&p.ID, &p.Title, &p.Date, &p.Category, &p.Summary, &p.Content, &tags); err != nil {
-				rows.Close()
-				return nil, err
-			}
-			if tags != "" {
-				p.Tags = parseTagsFromString(tags)
-			}
+	for _, text := range q.ExcludeTerms {
+		if db.fts {
+			where = append(where, `posts.id NOT IN (SELECT post_id FROM posts_fts WHERE posts_fts MATCH ?)`)
+			args = append(args, ftsPhrase(text))
+		} else {
+			where = append(where, `NOT `+likeText)
+			args = appendLike(args, text)
+		}
+	}
 
-			// Check if this tag actually exists in the parsed tags
-			hasTag := false
-			for _, t := range p.Tags {
-				if strings.EqualFold(t, tag) {
-					hasTag = true
-					break
-				}
-			}
+	for _, tag := range q.Tags {
+		where = append(where, tagFilter)
+		args = append(args, tag)
+	}
+	for _, tag := range q.ExcludeTags {
+		where = append(where, `NOT `+tagFilter)
+		args = append(args, tag)
+	}
 
-			if hasTag {
-				postsMap[p.ID] = p
-			}
+	if len(q.Categories) > 0 {
+		where = append(where, `category_id IN (SELECT id FROM categories WHERE name IN (?`+strings.Repeat(`, ?`, len(q.Categories)-1)+`))`)
+		for _, c := range q.Categories {
+			args = append(args, c)
 		}
-		rows.Close()
+	}
+	for _, c := range q.ExcludeCategories {
+		where = append(where, `NOT EXISTS (SELECT 1 FROM categories c WHERE c.id = posts.category_id AND c.name = ?)`)
+		args = append(args, c)
 	}
 
-	// Convert map to slice
-	posts := make([]models.Post, 0, len(postsMap))
-	for _, post := range postsMap {
-		posts = append(posts, post)
+	if q.After != nil {
+		where = append(where, `date >= ?`)
+		args = append(args, q.After.UTC())
+	}
+	if q.Before != nil {
+		where = append(where, `date < ?`)
+		args = append(args, q.Before.UTC())
 	}
 
-	return posts, nil
-}
+	// Continue after the last post of the previous page
+	if after != nil {
+		if ranked {
+			if after.Score == nil {
+				return nil, "", ErrInvalidCursor
+			}
+			where = append(where, `(m.score, date, posts.id) < (?, ?, ?)`)
+			args = append(args, *after.Score, after.Date, after.ID)
+		} else {
+			where = append(where, `(date, posts.id) < (?, ?)`)
+			args = append(args, after.Date, after.ID)
+		}
+	}
 
-// GetAllTags returns all unique tags from all posts
-func (db *DB) GetAllTags() ([]string, error) {
-	query := `SELECT DISTINCT tags FROM posts WHERE tags != ''`
-	rows, err := db.conn.Query(query)
+	where = append(where, publishedFilter)
+	args = append(args, time.Now().UTC())
+
+	// Fetch one extra row to tell whether there is a next page
+	limit := page.limit()
+	searchQuery := `SELECT ` + columns + ` FROM posts` + join + `
+		WHERE ` + strings.Join(where, ` AND `) + `
+		ORDER BY ` + order + `
+		LIMIT ?`
+	args = append(args, limit+1)
+
+	rows, err := db.conn.Query(searchQuery, args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
 
-	tagsMap := make(map[string]bool)
+	results := []models.SearchResult{}
+	var last cursor
 	for rows.Next() {
-		var tagsStr string
-		if err := rows.Scan(&tagsStr); err != nil {
-			return nil, err
+		if len(results) == limit {
+			next := last
+			if !ranked {
+				next.Score = nil
+			}
+			return results, next.encode(), nil
 		}
 
-		tags := parseTagsFromString(tagsStr)
-		for _, tag := range tags {
-			tagsMap[tag] = true
+		var r models.SearchResult
+		var snippet string
+		p, err := scanPost(extraScanner{rows, []interface{}{&last.Date, &r.Score, &snippet}})
+		if err != nil {
+			return nil, "", err
 		}
+		r.Post = p
+		r.Snippet = highlight(snippet)
+		results = append(results, r)
+
+		score := r.Score
+		last.Score, last.ID = &score, p.ID
 	}
 
-	// Convert map to slice
-	allTags := make([]string, 0, len(tagsMap))
-	for tag := range tagsMap {
-		allTags = append(allTags, tag)
+	return results, "", rows.Err()
+}
+
+// appendLike adds the five likeText arguments for text
+func appendLike(args []interface{}, text string) []interface{} {
+	pattern := "%" + escapeLike(text) + "%"
+	return append(args, pattern, pattern, pattern, pattern, pattern)
+}
+
+// escapeLike escapes LIKE wildcards so they match literally with ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// ftsMatch builds an FTS5 query requiring every word and phrase. Each is
+// quoted so punctuation can't be read as query syntax. The last word also
+// matches as a prefix; prefixes aren't stemmed, so it keeps the stemmed form too.
+func ftsMatch(terms, phrases []string) string {
+	parts := make([]string, 0, len(terms)+len(phrases))
+	for i, t := range terms {
+		if i == len(terms)-1 {
+			parts = append(parts, "("+ftsPhrase(t)+" OR "+ftsPhrase(t)+"*)")
+		} else {
+			parts = append(parts, ftsPhrase(t))
+		}
 	}
+	for _, p := range phrases {
+		parts = append(parts, ftsPhrase(p))
+	}
+	return strings.Join(parts, " AND ")
+}
 
-	return allTags, rows.Err()
+// ftsPhrase quotes text as an FTS5 string
+func ftsPhrase(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+}
+
+// highlight escapes a snippet and turns its match delimiters into <mark> tags
+func highlight(snippet string) template.HTML {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, markStart, "<mark>")
+	escaped = strings.ReplaceAll(escaped, markEnd, "</mark>")
+	//nolint:gosec // Snippet text is escaped; only the <mark> tags are added
+	return template.HTML(escaped)
+}
+
+// extraScanner scans the columns after postColumns into extra
+type extraScanner struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+func (s extraScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
+
+// SearchPostsByTag finds published posts with a tag, ignoring case, newest first
+func (db *DB) SearchPostsByTag(tag string) ([]models.Post, error) {
+	query := `SELECT ` + postColumns + `
+		FROM posts
+		WHERE ` + tagFilter + ` AND ` + publishedFilter + `
+		ORDER BY date DESC, id DESC`
+	return db.queryPosts(query, strings.TrimSpace(tag), time.Now().UTC())
+}
+
+// GetAllTags returns the canonical names of the tags on published posts, in order
+func (db *DB) GetAllTags() ([]string, error) {
+	tags, err := db.GetTagCounts()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.Name
+	}
+	return names, nil
 }
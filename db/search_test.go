@@ -2,10 +2,13 @@ package db
 
 import (
 	"os"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/tinotenda-alfaneti/homelabsite/models"
+	"github.com/tinotenda-alfaneti/homelabsite/search"
 )
 
 func TestSearchPosts(t *testing.T) {
@@ -335,3 +338,149 @@ func TestGetAllTags(t *testing.T) {
 		}
 	}
 }
+
+func TestSearchPostsRanking(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	if !db.fts {
+		t.Skip("SQLite built without FTS5; run with -tags sqlite_fts5")
+	}
+
+	past := time.Now().Add(-time.Hour)
+	posts := []*models.Post{
+		{ID: "in-body", Title: "Weekend notes", Date: time.Now(), Category: "Misc", Summary: "Odds and ends",
+			Content: "Some words about a <b>backup</b> job I wrote for the cluster."},
+		{ID: "in-title", Title: "Cluster backups with Velero", Date: past, Category: "Kubernetes", Summary: "Restic and MinIO",
+			Content: "Deploying Velero to MicroK8s."},
+		{ID: "draft", Title: "Backup draft", Date: past, Category: "Kubernetes", Summary: "Not yet",
+			Content: "backup", Status: models.PostStatusDraft},
+	}
+	for _, p := range posts {
+		if err := db.SavePost(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := db.SearchPosts("cluster backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].ID != "in-title" || results[1].ID != "in-body" {
+		t.Fatalf("Expected the title match first and no drafts, got %+v", results)
+	}
+	if results[0].Score <= results[1].Score {
+		t.Errorf("Expected a higher score for the title match: %v <= %v", results[0].Score, results[1].Score)
+	}
+	if snippet := string(results[1].Snippet); !strings.Contains(snippet, "<mark>backup</mark>") || !strings.Contains(snippet, "&lt;b&gt;") {
+		t.Errorf("Expected an escaped snippet with the match marked, got %q", snippet)
+	}
+
+	// Stemming: "deployed" matches "Deploying"
+	if results, _ := db.SearchPosts("deployed"); len(results) != 1 || results[0].ID != "in-title" {
+		t.Errorf("Expected a stemmed match, got %+v", results)
+	}
+
+	// Query syntax in user input is treated as text
+	if _, err := db.SearchPosts(`velero" OR "`); err != nil {
+		t.Errorf("Expected quotes to be escaped, got %v", err)
+	}
+
+	// The index follows edits and deletes
+	posts[0].Content = "Nothing to see"
+	if err := db.SavePost(posts[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeletePost("in-title"); err != nil {
+		t.Fatal(err)
+	}
+	if results, _ := db.SearchPosts("backup"); len(results) != 0 {
+		t.Errorf("Expected no results after edit and delete, got %+v", results)
+	}
+}
+
+func TestFTSMatch(t *testing.T) {
+	tests := []struct {
+		terms   []string
+		phrases []string
+		want    string
+	}{
+		{nil, nil, ""},
+		{[]string{"kuber"}, nil, `("kuber" OR "kuber"*)`},
+		{[]string{"helm", "charts"}, nil, `"helm" AND ("charts" OR "charts"*)`},
+		{[]string{`"hi"`, "OR"}, []string{"exact phrase"}, `"""hi""" AND ("OR" OR "OR"*) AND "exact phrase"`},
+	}
+	for _, tt := range tests {
+		if got := ftsMatch(tt.terms, tt.phrases); got != tt.want {
+			t.Errorf("ftsMatch(%q, %q) = %q, want %q", tt.terms, tt.phrases, got, tt.want)
+		}
+	}
+}
+
+func TestSearchQueryFilters(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	posts := []*models.Post{
+		{ID: "velero", Title: "Cluster backups with Velero", Date: day("2025-02-10"), Category: "Cloud Native",
+			Summary: "Restic and MinIO", Content: "Backing up MicroK8s volumes.", Tags: []string{"k8s", "backups"}},
+		{ID: "k3s", Title: "Trying k3s", Date: day("2024-11-02"), Category: "Cloud Native",
+			Summary: "A lighter cluster", Content: "Notes from a draft cluster setup.", Tags: []string{"k8s"}},
+		{ID: "postgres", Title: "Postgres backups", Date: day("2025-03-01"), Category: "Databases",
+			Summary: "pg_dump on a timer", Content: "Nightly backups to MinIO.", Tags: []string{"postgres", "backups"}},
+		{ID: "hidden", Title: "Unfinished k8s post", Date: day("2025-03-02"), Category: "Cloud Native",
+			Summary: "WIP", Content: "backups", Tags: []string{"k8s"}, Status: models.PostStatusDraft},
+	}
+	for _, p := range posts {
+		if err := db.SavePost(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{``, []string{"postgres", "velero", "k3s"}},
+		{`tag:k8s`, []string{"velero", "k3s"}},
+		{`tag:K8S tag:backups`, []string{"velero"}},
+		{`-tag:k8s`, []string{"postgres"}},
+		{`category:"cloud native" after:2025-01`, []string{"velero"}},
+		{`category:Databases category:"Cloud Native" before:2025-02-10`, []string{"k3s"}},
+		{`-category:"Cloud Native"`, []string{"postgres"}},
+		{`minio -velero`, []string{"postgres"}},
+		{`cluster -draft`, []string{"velero"}},
+		{`"nightly backups"`, []string{"postgres"}},
+		{`"backups nightly"`, []string{}},
+		{`tag:k8s after:2025-01 backups`, []string{"velero"}},
+		{`tag:100%`, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := search.Parse(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results, _, err := db.Search(q, PageOptions{})
+			if err != nil {
+				t.Fatalf("Search(%q) failed: %v", tt.query, err)
+			}
+			got := make([]string, 0, len(results))
+			for _, r := range results {
+				got = append(got, r.ID)
+			}
+			if q.HasText() {
+				// Text searches are ranked, so compare as sets
+				sort.Strings(got)
+				sort.Strings(tt.want)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
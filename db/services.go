@@ -0,0 +1,179 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+var (
+	// ErrServiceNotFound is returned when no service has the given ID
+	ErrServiceNotFound = errors.New("service not found")
+	// ErrDuplicateServiceName is returned when another service already uses the name
+	ErrDuplicateServiceName = errors.New("a service with that name already exists")
+	// ErrServiceNameRequired is returned when saving a service without a name
+	ErrServiceNameRequired = errors.New("service name is required")
+)
+
+// serviceColumns is the column list read by scanService
+const serviceColumns = `id, name, description, url, tech, status, icon, check_config`
+
+func scanService(row rowScanner) (models.Service, error) {
+	var s models.Service
+	var check string
+	if err := row.Scan(&s.ID, &s.Name, &s.Description, &s.URL, &s.Tech, &s.Status, &s.Icon, &check); err != nil {
+		return s, err
+	}
+	if check != "" {
+		s.Check = &models.ServiceCheck{}
+		if err := json.Unmarshal([]byte(check), s.Check); err != nil {
+			return s, fmt.Errorf("service %s has invalid check config: %w", s.Name, err)
+		}
+	}
+	return s, nil
+}
+
+// checkConfig returns the value stored in check_config: JSON, or ” when
+// the service uses the default check
+func checkConfig(service *models.Service) (string, error) {
+	if service.Check == nil {
+		return "", nil
+	}
+	b, err := json.Marshal(service.Check)
+	return string(b), err
+}
+
+// GetAllServices retrieves all services from the database
+func (db *DB) GetAllServices() ([]models.Service, error) {
+	rows, err := db.conn.Query(`SELECT ` + serviceColumns + ` FROM services ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var services []models.Service
+	for rows.Next() {
+		s, err := scanService(rows)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, s)
+	}
+
+	return services, rows.Err()
+}
+
+// GetServiceByID retrieves a single service, or nil if it doesn't exist
+func (db *DB) GetServiceByID(id int64) (*models.Service, error) {
+	s, err := scanService(db.conn.QueryRow(`SELECT `+serviceColumns+` FROM services WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// CreateService inserts a new service and sets its ID
+func (db *DB) CreateService(service *models.Service) error {
+	if err := validateService(service); err != nil {
+		return err
+	}
+	check, err := checkConfig(service)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.conn.Exec(`
+		INSERT INTO services (name, description, url, tech, status, icon, check_config)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, service.Name, service.Description, service.URL, service.Tech, service.Status, service.Icon, check)
+	if err != nil {
+		return serviceWriteError(err)
+	}
+
+	service.ID, err = result.LastInsertId()
+	return err
+}
+
+// UpdateService overwrites the service with service.ID. Renaming keeps the
+// same row, and a synced service stays linked to its YAML entry.
+func (db *DB) UpdateService(service *models.Service) error {
+	if err := validateService(service); err != nil {
+		return err
+	}
+	check, err := checkConfig(service)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.conn.Exec(`
+		UPDATE services
+		SET name = ?, description = ?, url = ?, tech = ?, status = ?, icon = ?, check_config = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, service.Name, service.Description, service.URL, service.Tech, service.Status, service.Icon, check, service.ID)
+	if err != nil {
+		return serviceWriteError(err)
+	}
+	return requireAffected(result)
+}
+
+// DeleteService deletes a service by ID. A service that still appears in
+// services.yaml is imported again by the next sync.
+func (db *DB) DeleteService(id int64) error {
+	result, err := db.conn.Exec(`DELETE FROM services WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// SaveService creates or updates a service matched by name.
+// New code should use CreateService and UpdateService, which key on the ID.
+func (db *DB) SaveService(service *models.Service) error {
+	var id int64
+	err := db.conn.QueryRow(`SELECT id FROM services WHERE name = ?`, service.Name).Scan(&id)
+	switch {
+	case err == sql.ErrNoRows:
+		return db.CreateService(service)
+	case err != nil:
+		return err
+	}
+
+	service.ID = id
+	return db.UpdateService(service)
+}
+
+// validateService trims the name, which is the only required field
+func validateService(service *models.Service) error {
+	service.Name = strings.TrimSpace(service.Name)
+	if service.Name == "" {
+		return ErrServiceNameRequired
+	}
+	return nil
+}
+
+// serviceWriteError maps a unique name violation to ErrDuplicateServiceName
+func serviceWriteError(err error) error {
+	if strings.Contains(err.Error(), "UNIQUE constraint failed: services.name") {
+		return ErrDuplicateServiceName
+	}
+	return err
+}
+
+func requireAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrServiceNotFound
+	}
+	return nil
+}
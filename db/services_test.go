@@ -0,0 +1,171 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestServiceCRUD(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	service := &models.Service{Name: "  Grafana ", URL: "https://grafana.example.com", Status: models.ServiceStatusInternal}
+	if err := db.CreateService(service); err != nil {
+		t.Fatalf("CreateService failed: %v", err)
+	}
+	if service.ID == 0 || service.Name != "Grafana" {
+		t.Fatalf("Expected an ID and a trimmed name, got %+v", service)
+	}
+
+	// Renaming updates the same row
+	service.Name = "Grafana Dashboards"
+	if err := db.UpdateService(service); err != nil {
+		t.Fatalf("UpdateService failed: %v", err)
+	}
+	services, err := db.GetAllServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || services[0].ID != service.ID || services[0].Name != "Grafana Dashboards" {
+		t.Fatalf("Expected one renamed service, got %+v", services)
+	}
+
+	got, err := db.GetServiceByID(service.ID)
+	if err != nil || got == nil || got.URL != service.URL {
+		t.Errorf("GetServiceByID = %+v, %v", got, err)
+	}
+
+	if err := db.DeleteService(service.ID); err != nil {
+		t.Fatalf("DeleteService failed: %v", err)
+	}
+	if got, _ := db.GetServiceByID(service.ID); got != nil {
+		t.Errorf("Expected service deleted, got %+v", got)
+	}
+}
+
+func TestServiceErrors(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	first := &models.Service{Name: "Jenkins", Status: models.ServiceStatusInternal}
+	second := &models.Service{Name: "Gitea", Status: models.ServiceStatusPublic}
+	for _, s := range []*models.Service{first, second} {
+		if err := db.CreateService(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.CreateService(&models.Service{Name: "Jenkins"}); !errors.Is(err, ErrDuplicateServiceName) {
+		t.Errorf("Expected ErrDuplicateServiceName on create, got %v", err)
+	}
+	second.Name = "Jenkins"
+	if err := db.UpdateService(second); !errors.Is(err, ErrDuplicateServiceName) {
+		t.Errorf("Expected ErrDuplicateServiceName on rename, got %v", err)
+	}
+	if err := db.CreateService(&models.Service{Name: " "}); !errors.Is(err, ErrServiceNameRequired) {
+		t.Errorf("Expected ErrServiceNameRequired, got %v", err)
+	}
+	if err := db.UpdateService(&models.Service{ID: 999, Name: "Ghost"}); !errors.Is(err, ErrServiceNotFound) {
+		t.Errorf("Expected ErrServiceNotFound on update, got %v", err)
+	}
+	if err := db.DeleteService(999); !errors.Is(err, ErrServiceNotFound) {
+		t.Errorf("Expected ErrServiceNotFound on delete, got %v", err)
+	}
+}
+
+func TestSyncKeepsRenamedService(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	_, services := syncFixtures()
+	if _, err := db.SyncContent(nil, services, SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	synced, _ := db.GetAllServices()
+	renamed := synced[0]
+	renamed.Name = "Jenkins CI"
+	if err := db.UpdateService(&renamed); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := db.SyncContent(nil, services, SyncOptions{Delete: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.HasChanges() {
+		t.Errorf("Expected the renamed service to be matched, got %s", report)
+	}
+
+	all, _ := db.GetAllServices()
+	if len(all) != 1 || all[0].Name != "Jenkins CI" {
+		t.Errorf("Expected a single renamed service, got %+v", all)
+	}
+}
+
+func TestSyncReportsServiceNameConflict(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	_, services := syncFixtures()
+	if _, err := db.SyncContent(nil, services, SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// An admin renames the synced service to the name of one YAML then adds
+	synced, _ := db.GetAllServices()
+	renamed := synced[0]
+	renamed.Name = "Grafana"
+	if err := db.UpdateService(&renamed); err != nil {
+		t.Fatal(err)
+	}
+	services = append(services, models.Service{Name: "Grafana", Status: "public"}, models.Service{Name: "Vault", Status: "internal"})
+
+	report, err := db.SyncContent(nil, services, SyncOptions{})
+	if err != nil {
+		t.Fatalf("Expected the conflict to be reported, not to fail the sync: %v", err)
+	}
+	if len(report.Services.Conflicts) != 1 || report.Services.Conflicts[0] != "Grafana" || len(report.Services.Inserted) != 1 {
+		t.Errorf("Expected Grafana skipped as a conflict and Vault inserted, got %+v", report.Services)
+	}
+
+	all, _ := db.GetAllServices()
+	if len(all) != 2 {
+		t.Errorf("Expected the renamed service and Vault, got %+v", all)
+	}
+}
+
+func TestSyncAdoptsAdminCreatedService(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if err := db.CreateService(&models.Service{Name: "Jenkins", Status: models.ServiceStatusPublic}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, services := syncFixtures()
+	report, err := db.SyncContent(nil, services, SyncOptions{})
+	if err != nil {
+		t.Fatalf("SyncContent failed: %v", err)
+	}
+	if len(report.Services.Adopted) != 1 {
+		t.Errorf("Expected the existing row to be adopted, got %s", report)
+	}
+
+	all, _ := db.GetAllServices()
+	if len(all) != 1 || all[0].Status != models.ServiceStatusPublic {
+		t.Errorf("Expected one service that keeps its admin status, got %+v", all)
+	}
+
+	// Once adopted, changes to the YAML entry apply as usual
+	services[0].Description = "Builds"
+	if report, err := db.SyncContent(nil, services, SyncOptions{}); err != nil || len(report.Services.Updated) != 1 {
+		t.Fatalf("Expected the adopted service to be updated, got %v, %v", report, err)
+	}
+	all, _ = db.GetAllServices()
+	if len(all) != 1 || all[0].Status != "internal" {
+		t.Errorf("Expected one service with the YAML status, got %+v", all)
+	}
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// The methods in this file make DB a middleware.SessionStore, so sessions
+// survive restarts and are shared by every replica using the database
+
+// sessionColumns is the column list read by scanSession
+const sessionColumns = `id, user_id, created_at, last_seen_at, expires_at, ip, user_agent`
+
+func scanSession(row rowScanner) (models.Session, error) {
+	var s models.Session
+	err := row.Scan(&s.ID, &s.UserID, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.IP, &s.UserAgent)
+	return s, err
+}
+
+// CreateSession stores a new session
+func (db *DB) CreateSession(s *models.Session) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO sessions (`+sessionColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, s.ID, s.UserID, s.CreatedAt.UTC(), s.LastSeenAt.UTC(), s.ExpiresAt.UTC(), s.IP, s.UserAgent)
+	return err
+}
+
+// GetSession retrieves a session, or nil if it doesn't exist
+func (db *DB) GetSession(id string) (*models.Session, error) {
+	s, err := scanSession(db.conn.QueryRow(`SELECT `+sessionColumns+` FROM sessions WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// TouchSession records that a session was used and extends it
+func (db *DB) TouchSession(id string, lastSeen, expires time.Time) error {
+	_, err := db.conn.Exec(`UPDATE sessions SET last_seen_at = ?, expires_at = ? WHERE id = ?`,
+		lastSeen.UTC(), expires.UTC(), id)
+	return err
+}
+
+// DeleteSession deletes a session, if it exists
+func (db *DB) DeleteSession(id string) error {
+	_, err := db.conn.Exec(`DELETE FROM sessions WHERE id = ?`, id)
+	return err
+}
+
+// DeleteUserSessions deletes every session of a user
+func (db *DB) DeleteUserSessions(userID int64) error {
+	_, err := db.conn.Exec(`DELETE FROM sessions WHERE user_id = ?`, userID)
+	return err
+}
+
+// ListSessions returns the sessions that haven't expired by now, most
+// recently seen first
+func (db *DB) ListSessions(now time.Time) ([]models.Session, error) {
+	rows, err := db.conn.Query(`
+		SELECT `+sessionColumns+` FROM sessions
+		WHERE expires_at > ?
+		ORDER BY last_seen_at DESC, id
+	`, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteExpiredSessions deletes the sessions that expired by now
+func (db *DB) DeleteExpiredSessions(now time.Time) error {
+	_, err := db.conn.Exec(`DELETE FROM sessions WHERE expires_at <= ?`, now.UTC())
+	return err
+}
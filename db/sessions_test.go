@@ -0,0 +1,68 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestSessions(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if _, err := db.BootstrapAdmin("admin", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	admin, _ := db.GetUserByUsername("admin")
+	now := time.Now().UTC().Truncate(time.Second)
+
+	for _, s := range []models.Session{
+		{ID: "old", UserID: admin.ID, CreatedAt: now, LastSeenAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour), IP: "192.0.2.1", UserAgent: "curl"},
+		{ID: "new", UserID: admin.ID, CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)},
+		{ID: "expired", UserID: admin.ID, CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(-time.Minute)},
+	} {
+		s := s
+		if err := db.CreateSession(&s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := db.GetSession("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s == nil || s.UserID != admin.ID || s.IP != "192.0.2.1" || s.UserAgent != "curl" || !s.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("Unexpected session %+v", s)
+	}
+	if missing, err := db.GetSession("missing"); missing != nil || err != nil {
+		t.Errorf("Expected nil for a missing session, got %+v %v", missing, err)
+	}
+
+	if err := db.TouchSession("old", now.Add(time.Minute), now.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := db.ListSessions(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != "old" || sessions[1].ID != "new" {
+		t.Errorf("Expected unexpired sessions most recently seen first, got %+v", sessions)
+	}
+
+	if err := db.DeleteExpiredSessions(now); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := db.GetSession("expired"); s != nil {
+		t.Error("Expected the expired session to be deleted")
+	}
+	if err := db.DeleteSession("new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteUserSessions(admin.ID); err != nil {
+		t.Fatal(err)
+	}
+	if sessions, _ := db.ListSessions(now); len(sessions) != 0 {
+		t.Errorf("Expected no sessions left, got %+v", sessions)
+	}
+}
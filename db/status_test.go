@@ -0,0 +1,156 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func savePostsWithStatus(t *testing.T, db *DB) {
+	t.Helper()
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(24 * time.Hour)
+	posts := []*models.Post{
+		{ID: "live", Title: "Live kubernetes", Date: past, Tags: []string{"k8s"}},
+		{ID: "published", Title: "Published kubernetes", Date: past, Status: models.PostStatusPublished, Tags: []string{"k8s"}},
+		{ID: "draft", Title: "Draft kubernetes", Date: past, Status: models.PostStatusDraft, Tags: []string{"k8s", "secret"}},
+		{ID: "archived", Title: "Archived kubernetes", Date: past, Status: models.PostStatusArchived, Tags: []string{"k8s"}},
+		{ID: "due", Title: "Due kubernetes", Date: past, Status: models.PostStatusScheduled, PublishAt: &past, Tags: []string{"k8s"}},
+		{ID: "later", Title: "Later kubernetes", Date: past, Status: models.PostStatusScheduled, PublishAt: &future, Tags: []string{"k8s"}},
+		{ID: "embargoed", Title: "Embargoed kubernetes", Date: past, Status: models.PostStatusPublished, PublishAt: &future, Tags: []string{"k8s"}},
+	}
+	for _, p := range posts {
+		if err := db.SavePost(p); err != nil {
+			t.Fatalf("Failed to save post %s: %v", p.ID, err)
+		}
+	}
+}
+
+func postIDs(posts []models.Post) map[string]bool {
+	ids := make(map[string]bool, len(posts))
+	for _, p := range posts {
+		ids[p.ID] = true
+	}
+	return ids
+}
+
+func TestPublicQueriesOnlyReturnPublishedPosts(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	savePostsWithStatus(t, db)
+	visible := []string{"live", "published", "due"}
+
+	all, err := db.GetAllPosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := db.SearchPosts("kubernetes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	search := make([]models.Post, 0, len(results))
+	for _, r := range results {
+		search = append(search, r.Post)
+	}
+	byTag, err := db.SearchPostsByTag("k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	popular, err := db.GetPopularPosts(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, posts := range map[string][]models.Post{
+		"GetAllPosts":      all,
+		"SearchPosts":      search,
+		"SearchPostsByTag": byTag,
+		"GetPopularPosts":  popular,
+	} {
+		ids := postIDs(posts)
+		if len(ids) != len(visible) {
+			t.Errorf("%s returned %v, want only %v", name, ids, visible)
+		}
+		for _, id := range visible {
+			if !ids[id] {
+				t.Errorf("%s is missing %s", name, id)
+			}
+		}
+	}
+
+	tags, err := db.GetAllTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range tags {
+		if tag == "secret" {
+			t.Error("GetAllTags should not include tags that only appear on drafts")
+		}
+	}
+}
+
+func TestAdminQueriesReturnEveryStatus(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	savePostsWithStatus(t, db)
+
+	all, err := db.GetAllPostsForAdmin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 7 {
+		t.Errorf("Expected all 7 posts, got %d", len(all))
+	}
+
+	draft, err := db.GetPostByID("draft")
+	if err != nil || draft == nil {
+		t.Fatalf("Expected to load the draft by ID, got %v, %v", draft, err)
+	}
+	if draft.Status != models.PostStatusDraft || draft.IsPublished(time.Now()) {
+		t.Errorf("Expected an unpublished draft, got status %q", draft.Status)
+	}
+
+	later, _ := db.GetPostByID("later")
+	if later.PublishAt == nil {
+		t.Fatal("Expected publish_at to round-trip")
+	}
+	if later.IsPublished(time.Now()) || !later.IsPublished(later.PublishAt.Add(time.Second)) {
+		t.Error("Scheduled post should become visible once publish_at passes")
+	}
+
+	live, _ := db.GetPostByID("live")
+	if live.Status != models.PostStatusPublished {
+		t.Errorf("Expected empty status to be saved as published, got %q", live.Status)
+	}
+}
+
+func TestSavePostValidatesStatus(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if err := db.SavePost(&models.Post{ID: "bad", Title: "Bad", Date: time.Now(), Status: "live"}); err == nil {
+		t.Error("Expected an error for an unknown status")
+	}
+	if err := db.SavePost(&models.Post{ID: "sched", Title: "Sched", Date: time.Now(), Status: models.PostStatusScheduled}); err == nil {
+		t.Error("Expected an error for a scheduled post without publish_at")
+	}
+}
+
+func TestSyncContentKeepsHashForPostsWithoutStatus(t *testing.T) {
+	post := models.Post{ID: "a", Title: "A", Date: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	before := PostHash(&post)
+
+	post.Status = models.PostStatusPublished
+	if PostHash(&post) != before {
+		t.Error("Explicit published status should hash like an empty status")
+	}
+
+	post.Status = models.PostStatusDraft
+	if PostHash(&post) == before {
+		t.Error("Changing the status should change the hash")
+	}
+}
@@ -0,0 +1,393 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// SyncOptions controls how SyncContent reconciles YAML content with the database
+type SyncOptions struct {
+	// Delete removes rows that were previously synced from YAML but no longer appear in it.
+	// Rows created through the admin UI are never deleted.
+	Delete bool
+	// DryRun computes the report without writing anything
+	DryRun bool
+}
+
+// SyncChanges lists the keys affected in a single table
+type SyncChanges struct {
+	Inserted []string `json:"inserted"`
+	Updated  []string `json:"updated"`
+	Deleted  []string `json:"deleted"`
+	// Adopted lists rows that existed without a content hash, imported
+	// before syncing existed or created in the admin UI. They start being
+	// tracked with the YAML hash, but their content is left as it is.
+	Adopted []string `json:"adopted"`
+	// Conflicts lists YAML entries that were skipped because their name is
+	// taken by another row, such as a service renamed in the admin UI
+	Conflicts []string `json:"conflicts"`
+	Unchanged int      `json:"unchanged"`
+}
+
+// SyncReport describes what SyncContent changed (or would change on a dry run)
+type SyncReport struct {
+	Posts    SyncChanges `json:"posts"`
+	Services SyncChanges `json:"services"`
+	DryRun   bool        `json:"dry_run"`
+}
+
+// HasChanges reports whether any row was inserted, updated or deleted
+func (r *SyncReport) HasChanges() bool {
+	return r.Posts.changed() || r.Services.changed()
+}
+
+func (c SyncChanges) changed() bool {
+	return len(c.Inserted) > 0 || len(c.Updated) > 0 || len(c.Deleted) > 0 || len(c.Adopted) > 0
+}
+
+func (c SyncChanges) summary() string {
+	summary := fmt.Sprintf("%d inserted, %d updated, %d deleted, %d adopted, %d unchanged",
+		len(c.Inserted), len(c.Updated), len(c.Deleted), len(c.Adopted), c.Unchanged)
+	if len(c.Conflicts) > 0 {
+		summary += fmt.Sprintf(", %d conflicts", len(c.Conflicts))
+	}
+	return summary
+}
+
+// String returns a one-line summary suitable for logging
+func (r *SyncReport) String() string {
+	prefix := ""
+	if r.DryRun {
+		prefix = "(dry run) "
+	}
+	return fmt.Sprintf("%sposts: %s; services: %s", prefix, r.Posts.summary(), r.Services.summary())
+}
+
+// PostHash returns a stable hash of the post fields that come from YAML.
+// Views are excluded since they only change at runtime.
+func PostHash(p *models.Post) string {
+	fields := []string{
+		p.ID,
+		p.Title,
+		p.Date.UTC().Format(time.RFC3339Nano),
+		p.Category,
+		p.Summary,
+		p.Content,
+		joinTags(p.Tags),
+	}
+	// Lifecycle fields are only hashed when set, so posts synced before they
+	// existed keep their hash and aren't rewritten
+	if status := postStatus(p); status != models.PostStatusPublished || p.PublishAt != nil {
+		publishAt := ""
+		if p.PublishAt != nil {
+			publishAt = p.PublishAt.UTC().Format(time.RFC3339Nano)
+		}
+		fields = append(fields, status, publishAt)
+	}
+	if p.Series != "" || p.SeriesOrder != 0 {
+		fields = append(fields, p.Series, strconv.Itoa(p.SeriesOrder))
+	}
+
+	h := sha256.New()
+	for _, field := range fields {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ServiceHash returns a stable hash of the service fields that come from YAML
+func ServiceHash(s *models.Service) string {
+	h := sha256.New()
+	for _, field := range []string{s.Name, s.Description, s.URL, s.Tech, s.Status, s.Icon} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	// Only hashed when set, so services synced before checks existed keep their hash
+	if check, _ := checkConfig(s); check != "" {
+		h.Write([]byte(check))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// syncedRow is the subset of an existing row needed to reconcile it
+type syncedRow struct {
+	id   int64
+	name string
+	hash string
+	date time.Time
+}
+
+// SyncContent reconciles posts (by ID) and services (by YAML name) with the database.
+//
+// A row is inserted when its key is missing and updated when the YAML hash differs
+// from the hash recorded at the last sync, so edits made through the admin UI
+// survive until the YAML entry itself changes. Existing rows without a hash are
+// adopted: they get the YAML hash but keep their content, so upgrading a
+// database doesn't overwrite what was edited before. Everything runs in one
+// transaction.
+func (db *DB) SyncContent(posts []models.Post, services []models.Service, opts SyncOptions) (*SyncReport, error) {
+	report := &SyncReport{DryRun: opts.DryRun}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back sync: %v", err)
+		}
+	}()
+
+	if err := syncPosts(tx, posts, opts, &report.Posts); err != nil {
+		return nil, fmt.Errorf("syncing posts: %w", err)
+	}
+	if err := syncServices(tx, services, opts, &report.Services); err != nil {
+		return nil, fmt.Errorf("syncing services: %w", err)
+	}
+	if report.Posts.changed() {
+		if err := refreshRelatedPosts(tx); err != nil {
+			return nil, fmt.Errorf("scoring related posts: %w", err)
+		}
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func syncPosts(tx *sql.Tx, posts []models.Post, opts SyncOptions, changes *SyncChanges) error {
+	existing := make(map[string]syncedRow)
+	rows, err := tx.Query(`SELECT id, content_hash, date FROM posts`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id string
+		var row syncedRow
+		if err := rows.Scan(&id, &row.hash, &row.date); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[id] = row
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(posts))
+	for _, post := range posts {
+		if post.ID == "" {
+			return fmt.Errorf("post %q has no id", post.Title)
+		}
+		if seen[post.ID] {
+			return fmt.Errorf("duplicate post id %q", post.ID)
+		}
+		seen[post.ID] = true
+
+		row, exists := existing[post.ID]
+		if post.Date.IsZero() {
+			// Keep the date the row was first imported with so the hash stays stable
+			if exists {
+				post.Date = row.date
+			} else {
+				post.Date = time.Now()
+			}
+		}
+		status := postStatus(&post)
+		if !models.ValidPostStatus(status) {
+			return fmt.Errorf("post %s has invalid status %q", post.ID, post.Status)
+		}
+		if status == models.PostStatusScheduled && post.PublishAt == nil {
+			return fmt.Errorf("scheduled post %s needs a publish_at time", post.ID)
+		}
+		hash := PostHash(&post)
+
+		var err error
+		switch {
+		case !exists:
+			changes.Inserted = append(changes.Inserted, post.ID)
+			_, err = tx.Exec(`
+				INSERT INTO posts (id, title, date, category, summary, content, tags, views, status, publish_at,
+					series, series_order, content_hash)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`, post.ID, post.Title, post.Date, post.Category, post.Summary, post.Content, joinTags(post.Tags), post.Views,
+				status, publishTime(&post), strings.TrimSpace(post.Series), post.SeriesOrder, hash)
+		case row.hash == "":
+			changes.Adopted = append(changes.Adopted, post.ID)
+			if _, err := tx.Exec(`UPDATE posts SET content_hash = ? WHERE id = ?`, hash, post.ID); err != nil {
+				return fmt.Errorf("post %s: %w", post.ID, err)
+			}
+			continue
+		case row.hash != hash:
+			changes.Updated = append(changes.Updated, post.ID)
+			_, err = tx.Exec(`
+				UPDATE posts
+				SET title = ?, date = ?, category = ?, summary = ?, content = ?, tags = ?,
+					status = ?, publish_at = ?, series = ?, series_order = ?, content_hash = ?,
+					updated_at = CURRENT_TIMESTAMP
+				WHERE id = ?
+			`, post.Title, post.Date, post.Category, post.Summary, post.Content, joinTags(post.Tags),
+				status, publishTime(&post), strings.TrimSpace(post.Series), post.SeriesOrder, hash, post.ID)
+		default:
+			changes.Unchanged++
+			continue
+		}
+		if err == nil {
+			err = setPostTaxonomy(tx, &post)
+		}
+		if err == nil {
+			_, err = insertRevision(tx, &post, syncAuthor, "Synced from content")
+		}
+		if err != nil {
+			return fmt.Errorf("post %s: %w", post.ID, err)
+		}
+	}
+
+	if opts.Delete {
+		for _, id := range sortedKeys(existing) {
+			if seen[id] || existing[id].hash == "" {
+				continue
+			}
+			changes.Deleted = append(changes.Deleted, id)
+			if _, err := tx.Exec(`DELETE FROM posts WHERE id = ?`, id); err != nil {
+				return fmt.Errorf("post %s: %w", id, err)
+			}
+		}
+		return pruneTaxonomy(tx)
+	}
+
+	return nil
+}
+
+func syncServices(tx *sql.Tx, services []models.Service, opts SyncOptions, changes *SyncChanges) error {
+	// Synced rows are matched by the YAML name they were imported under, which
+	// survives a rename in the admin UI. Rows created in the admin UI, or
+	// imported before syncing existed, have no sync key and are adopted when a
+	// YAML service with the same name appears.
+	existing := make(map[string]syncedRow)
+	unkeyed := make(map[string]syncedRow)
+	// names maps every service's current name to its row, to catch YAML
+	// names already taken by another row before the unique index does
+	names := make(map[string]int64)
+	rows, err := tx.Query(`SELECT id, name, sync_key, content_hash FROM services`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var key string
+		var row syncedRow
+		if err := rows.Scan(&row.id, &row.name, &key, &row.hash); err != nil {
+			rows.Close()
+			return err
+		}
+		names[row.name] = row.id
+		if key != "" {
+			existing[key] = row
+		} else {
+			unkeyed[row.name] = row
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(services))
+	for _, service := range services {
+		name := strings.TrimSpace(service.Name)
+		if name == "" {
+			return fmt.Errorf("service with url %q has no name", service.URL)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate service name %q", name)
+		}
+		seen[name] = true
+		service.Name = name
+
+		row, exists := existing[name]
+		if !exists {
+			row, exists = unkeyed[name]
+		}
+		hash := ServiceHash(&service)
+		check, err := checkConfig(&service)
+		if err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+
+		if id, taken := names[name]; taken && (!exists || id != row.id) && row.hash != hash {
+			// Another row has the name, so writing it would break the unique
+			// index; leave both rows alone until someone renames one
+			changes.Conflicts = append(changes.Conflicts, name)
+			continue
+		}
+
+		switch {
+		case !exists:
+			changes.Inserted = append(changes.Inserted, name)
+			_, err = tx.Exec(`
+				INSERT INTO services (name, description, url, tech, status, icon, check_config, content_hash, sync_key)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`, name, service.Description, service.URL, service.Tech, service.Status, service.Icon, check, hash, name)
+		case row.hash == "":
+			changes.Adopted = append(changes.Adopted, name)
+			_, err = tx.Exec(`UPDATE services SET content_hash = ?, sync_key = ? WHERE id = ?`, hash, name, row.id)
+		case row.hash != hash:
+			changes.Updated = append(changes.Updated, name)
+			_, err = tx.Exec(`
+				UPDATE services
+				SET name = ?, description = ?, url = ?, tech = ?, status = ?, icon = ?, check_config = ?,
+					content_hash = ?, sync_key = ?, updated_at = CURRENT_TIMESTAMP
+				WHERE id = ?
+			`, name, service.Description, service.URL, service.Tech, service.Status, service.Icon, check, hash, name, row.id)
+		default:
+			changes.Unchanged++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+		// The row now has the YAML name; inserted rows are recorded under
+		// id 0, which no existing row has
+		delete(names, row.name)
+		names[name] = row.id
+	}
+
+	if opts.Delete {
+		for _, key := range sortedKeys(existing) {
+			if seen[key] || existing[key].hash == "" {
+				continue
+			}
+			changes.Deleted = append(changes.Deleted, key)
+			if _, err := tx.Exec(`DELETE FROM services WHERE id = ?`, existing[key].id); err != nil {
+				return fmt.Errorf("service %s: %w", key, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func sortedKeys(m map[string]syncedRow) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,250 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func syncFixtures() ([]models.Post, []models.Service) {
+	date := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	posts := []models.Post{
+		{ID: "first", Title: "First", Date: date, Category: "Go", Summary: "One", Content: "Body one", Tags: []string{"go"}},
+		{ID: "second", Title: "Second", Date: date.AddDate(0, 0, 1), Category: "K8s", Summary: "Two", Content: "Body two", Tags: []string{"k8s"}},
+	}
+	services := []models.Service{
+		{Name: "Jenkins", Description: "CI", URL: "https://jenkins.example.com", Tech: "Java", Status: "internal"},
+	}
+	return posts, services
+}
+
+func TestSyncContentInsertsAndIsIdempotent(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	posts, services := syncFixtures()
+
+	report, err := db.SyncContent(posts, services, SyncOptions{})
+	if err != nil {
+		t.Fatalf("SyncContent failed: %v", err)
+	}
+	if len(report.Posts.Inserted) != 2 || len(report.Services.Inserted) != 1 {
+		t.Errorf("Expected 2 posts and 1 service inserted, got %s", report)
+	}
+
+	report, err = db.SyncContent(posts, services, SyncOptions{})
+	if err != nil {
+		t.Fatalf("Second SyncContent failed: %v", err)
+	}
+	if report.HasChanges() {
+		t.Errorf("Expected no changes on second sync, got %s", report)
+	}
+	if report.Posts.Unchanged != 2 || report.Services.Unchanged != 1 {
+		t.Errorf("Expected everything unchanged, got %s", report)
+	}
+}
+
+func TestSyncContentUpdatesChangedYAML(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	posts, services := syncFixtures()
+	if _, err := db.SyncContent(posts, services, SyncOptions{}); err != nil {
+		t.Fatalf("SyncContent failed: %v", err)
+	}
+	if err := db.IncrementPostViews("first"); err != nil {
+		t.Fatalf("IncrementPostViews failed: %v", err)
+	}
+
+	posts[0].Content = "Edited in git"
+	services[0].URL = "https://ci.example.com"
+
+	report, err := db.SyncContent(posts, services, SyncOptions{})
+	if err != nil {
+		t.Fatalf("SyncContent failed: %v", err)
+	}
+	if len(report.Posts.Updated) != 1 || report.Posts.Updated[0] != "first" {
+		t.Errorf("Expected post 'first' updated, got %+v", report.Posts)
+	}
+	if len(report.Services.Updated) != 1 {
+		t.Errorf("Expected service updated, got %+v", report.Services)
+	}
+
+	post, _ := db.GetPostByID("first")
+	if post.Content != "Edited in git" {
+		t.Errorf("Expected updated content, got %q", post.Content)
+	}
+	if post.Views != 1 {
+		t.Errorf("Expected views to be preserved, got %d", post.Views)
+	}
+}
+
+func TestSyncContentKeepsAdminEdits(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	posts, services := syncFixtures()
+	if _, err := db.SyncContent(posts, services, SyncOptions{}); err != nil {
+		t.Fatalf("SyncContent failed: %v", err)
+	}
+
+	// Edit through the regular save path, as the admin UI does
+	edited := posts[0]
+	edited.Title = "Edited in admin"
+	if err := db.SavePost(&edited); err != nil {
+		t.Fatalf("SavePost failed: %v", err)
+	}
+
+	report, err := db.SyncContent(posts, services, SyncOptions{})
+	if err != nil {
+		t.Fatalf("SyncContent failed: %v", err)
+	}
+	if report.HasChanges() {
+		t.Errorf("Unchanged YAML should not overwrite admin edits, got %s", report)
+	}
+
+	post, _ := db.GetPostByID("first")
+	if post.Title != "Edited in admin" {
+		t.Errorf("Expected admin title to survive, got %q", post.Title)
+	}
+}
+
+func TestSyncContentAdoptsPreUpgradeRows(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// Rows from the one-time import that predates syncing have no content
+	// hash, and may have been edited in the admin since
+	posts, services := syncFixtures()
+	if _, err := db.conn.Exec(`
+		INSERT INTO posts (id, title, date, category, summary, content, tags, content_hash)
+		VALUES ('first', 'Edited in admin', ?, 'Go', 'One', 'Admin body', 'go', '')
+	`, posts[0].Date); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.conn.Exec(`
+		INSERT INTO services (name, description, url, tech, status, icon, content_hash)
+		VALUES ('Jenkins', 'Edited CI', 'https://ci.example.com', 'Java', 'public', '', '')
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := db.SyncContent(posts, services, SyncOptions{})
+	if err != nil {
+		t.Fatalf("SyncContent failed: %v", err)
+	}
+	if len(report.Posts.Updated) != 0 || len(report.Services.Updated) != 0 ||
+		len(report.Posts.Adopted) != 1 || len(report.Services.Adopted) != 1 || len(report.Posts.Inserted) != 1 {
+		t.Errorf("Expected the existing rows to be adopted and the new post inserted, got %s", report)
+	}
+
+	post, _ := db.GetPostByID("first")
+	if post.Title != "Edited in admin" || post.Content != "Admin body" {
+		t.Errorf("Expected the admin edits to the post to survive, got %+v", post)
+	}
+	all, _ := db.GetAllServices()
+	if len(all) != 1 || all[0].Description != "Edited CI" || all[0].URL != "https://ci.example.com" {
+		t.Errorf("Expected the admin edits to the service to survive, got %+v", all)
+	}
+
+	// The next sync of the same YAML changes nothing
+	report, err = db.SyncContent(posts, services, SyncOptions{})
+	if err != nil {
+		t.Fatalf("SyncContent failed: %v", err)
+	}
+	if report.HasChanges() {
+		t.Errorf("Expected adopted rows to be unchanged, got %s", report)
+	}
+}
+
+func TestSyncContentDelete(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	posts, services := syncFixtures()
+	if _, err := db.SyncContent(posts, services, SyncOptions{}); err != nil {
+		t.Fatalf("SyncContent failed: %v", err)
+	}
+
+	// A post created in the admin UI was never synced and must not be deleted
+	adminPost := &models.Post{ID: "admin-only", Title: "Admin", Date: time.Now(), Category: "Go", Summary: "S", Content: "C"}
+	if err := db.SavePost(adminPost); err != nil {
+		t.Fatalf("SavePost failed: %v", err)
+	}
+
+	// Without Delete, removed YAML entries stay
+	report, err := db.SyncContent(posts[:1], nil, SyncOptions{})
+	if err != nil {
+		t.Fatalf("SyncContent failed: %v", err)
+	}
+	if len(report.Posts.Deleted) != 0 {
+		t.Errorf("Expected no deletes without Delete option, got %v", report.Posts.Deleted)
+	}
+
+	report, err = db.SyncContent(posts[:1], nil, SyncOptions{Delete: true})
+	if err != nil {
+		t.Fatalf("SyncContent failed: %v", err)
+	}
+	if len(report.Posts.Deleted) != 1 || report.Posts.Deleted[0] != "second" {
+		t.Errorf("Expected 'second' deleted, got %v", report.Posts.Deleted)
+	}
+	if len(report.Services.Deleted) != 1 {
+		t.Errorf("Expected service deleted, got %v", report.Services.Deleted)
+	}
+
+	if p, _ := db.GetPostByID("admin-only"); p == nil {
+		t.Error("Admin-created post should not be deleted by sync")
+	}
+}
+
+func TestSyncContentDryRun(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	posts, services := syncFixtures()
+	report, err := db.SyncContent(posts, services, SyncOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("SyncContent failed: %v", err)
+	}
+	if len(report.Posts.Inserted) != 2 {
+		t.Errorf("Expected dry run to report 2 inserts, got %s", report)
+	}
+
+	all, _ := db.GetAllPosts()
+	if len(all) != 0 {
+		t.Errorf("Dry run should not write posts, found %d", len(all))
+	}
+}
+
+func TestSyncContentRejectsDuplicates(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	posts, _ := syncFixtures()
+	posts[1].ID = posts[0].ID
+
+	if _, err := db.SyncContent(posts, nil, SyncOptions{}); err == nil {
+		t.Error("Expected error for duplicate post IDs")
+	}
+
+	all, _ := db.GetAllPosts()
+	if len(all) != 0 {
+		t.Errorf("Failed sync should roll back, found %d posts", len(all))
+	}
+}
+
+func TestPostHashOnlyIncludesSetSeries(t *testing.T) {
+	post := models.Post{ID: "a", Title: "A", Date: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	before := PostHash(&post)
+
+	post.Series = "Cluster"
+	if PostHash(&post) == before {
+		t.Error("Setting a series should change the hash")
+	}
+	withSeries := PostHash(&post)
+	post.SeriesOrder = 2
+	if PostHash(&post) == withSeries {
+		t.Error("Changing the part should change the hash")
+	}
+}
@@ -0,0 +1,332 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+var (
+	// ErrTagNotFound is returned when no tag has the given ID
+	ErrTagNotFound = errors.New("tag not found")
+	// ErrTagExists is returned when renaming a tag to the name of another one
+	ErrTagExists = errors.New("a tag with that name already exists")
+	// ErrCategoryNotFound is returned when no category has the given ID
+	ErrCategoryNotFound = errors.New("category not found")
+	// ErrCategoryExists is returned when renaming a category to the name of another one
+	ErrCategoryExists = errors.New("a category with that name already exists")
+	// ErrInvalidName is returned for an empty tag or category name, or a tag
+	// name with a comma, which posts.tags uses as its separator
+	ErrInvalidName = errors.New("name must not be empty or contain commas")
+	// ErrMergeIntoSelf is returned when merging a tag or category into itself
+	ErrMergeIntoSelf = errors.New("can't merge into itself")
+)
+
+// tagFilter matches posts with the tag named ?, ignoring case
+const tagFilter = `EXISTS (
+	SELECT 1 FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+	WHERE pt.post_id = posts.id AND t.name = ?)`
+
+// postTagNames is a post's canonical tags joined in their listed order
+const postTagNames = `COALESCE((
+	SELECT group_concat(t.name, ',' ORDER BY pt.position)
+	FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+	WHERE pt.post_id = posts.id
+), '')`
+
+// taxonomy holds what differs between tags and categories for the shared
+// rename and merge code
+type taxonomy struct {
+	table    string
+	notFound error
+	exists   error
+	// counts selects the ID, name and number of posts of each term, for a
+	// WHERE clause and GROUP BY to be added
+	counts string
+	// refresh rewrites the denormalized names on the posts using term ?
+	refresh string
+	// merge moves the posts of the term with the first ID to the second
+	merge string
+}
+
+var tagTaxonomy = taxonomy{
+	table:    "tags",
+	notFound: ErrTagNotFound,
+	exists:   ErrTagExists,
+	counts: `SELECT tags.id, tags.name, COUNT(posts.id)
+		FROM tags
+		LEFT JOIN post_tags pt ON pt.tag_id = tags.id
+		LEFT JOIN posts ON posts.id = pt.post_id`,
+	refresh: `UPDATE posts SET tags = ` + postTagNames + `
+		WHERE id IN (SELECT post_id FROM post_tags WHERE tag_id = ?)`,
+	merge: `INSERT OR IGNORE INTO post_tags (post_id, tag_id, position)
+		SELECT post_id, ?2, position FROM post_tags WHERE tag_id = ?1`,
+}
+
+var categoryTaxonomy = taxonomy{
+	table:    "categories",
+	notFound: ErrCategoryNotFound,
+	exists:   ErrCategoryExists,
+	counts: `SELECT categories.id, categories.name, COUNT(posts.id)
+		FROM categories
+		LEFT JOIN posts ON posts.category_id = categories.id`,
+	refresh: `UPDATE posts SET category = (SELECT name FROM categories WHERE id = ?1)
+		WHERE category_id = ?1`,
+	merge: `UPDATE posts SET category_id = ?2 WHERE category_id = ?1`,
+}
+
+// GetTagCounts returns the tags of published posts with how many published
+// posts have each, ordered by name
+func (db *DB) GetTagCounts() ([]models.Tag, error) {
+	return db.termCounts(tagTaxonomy, `WHERE `+publishedFilter, time.Now().UTC())
+}
+
+// GetTagCountsForAdmin returns every tag with its number of posts of any status
+func (db *DB) GetTagCountsForAdmin() ([]models.Tag, error) {
+	return db.termCounts(tagTaxonomy, "")
+}
+
+// GetTag returns the tag with the given name, ignoring case, counting only
+// published posts. It returns nil if no published post has the tag.
+func (db *DB) GetTag(name string) (*models.Tag, error) {
+	tags, err := db.termCounts(tagTaxonomy, `WHERE tags.name = ? AND `+publishedFilter, name, time.Now().UTC())
+	if err != nil || len(tags) == 0 {
+		return nil, err
+	}
+	return &tags[0], nil
+}
+
+// GetCategoryCounts returns the categories of published posts with how many
+// published posts are in each, ordered by name
+func (db *DB) GetCategoryCounts() ([]models.Category, error) {
+	terms, err := db.termCounts(categoryTaxonomy, `WHERE `+publishedFilter, time.Now().UTC())
+	return categories(terms), err
+}
+
+// GetCategoryCountsForAdmin returns every category with its number of posts of any status
+func (db *DB) GetCategoryCountsForAdmin() ([]models.Category, error) {
+	terms, err := db.termCounts(categoryTaxonomy, "")
+	return categories(terms), err
+}
+
+// GetCategory returns the category with the given name, ignoring case,
+// counting only published posts. It returns nil if no published post is in it.
+func (db *DB) GetCategory(name string) (*models.Category, error) {
+	terms, err := db.termCounts(categoryTaxonomy, `WHERE categories.name = ? AND `+publishedFilter, name, time.Now().UTC())
+	if err != nil || len(terms) == 0 {
+		return nil, err
+	}
+	return &categories(terms)[0], nil
+}
+
+// RenameTag renames a tag on every post that has it. Changing only the case
+// is allowed; taking the name of another tag returns ErrTagExists, since that
+// is a merge.
+func (db *DB) RenameTag(id int64, name string) (*models.Tag, error) {
+	if strings.Contains(name, ",") {
+		return nil, ErrInvalidName
+	}
+	return db.renameTerm(tagTaxonomy, id, name)
+}
+
+// MergeTags moves every post tagged src to dst and deletes src
+func (db *DB) MergeTags(src, dst int64) (*models.Tag, error) {
+	return db.mergeTerms(tagTaxonomy, src, dst)
+}
+
+// RenameCategory renames a category on every post in it. Changing only the
+// case is allowed; taking the name of another category returns ErrCategoryExists.
+func (db *DB) RenameCategory(id int64, name string) (*models.Category, error) {
+	term, err := db.renameTerm(categoryTaxonomy, id, name)
+	if err != nil {
+		return nil, err
+	}
+	category := models.Category(*term)
+	return &category, nil
+}
+
+// MergeCategories moves every post in src to dst and deletes src
+func (db *DB) MergeCategories(src, dst int64) (*models.Category, error) {
+	term, err := db.mergeTerms(categoryTaxonomy, src, dst)
+	if err != nil {
+		return nil, err
+	}
+	category := models.Category(*term)
+	return &category, nil
+}
+
+func (db *DB) termCounts(t taxonomy, where string, args ...interface{}) ([]models.Tag, error) {
+	query := t.counts + ` ` + where + ` GROUP BY 1 ORDER BY 2`
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	terms := []models.Tag{}
+	for rows.Next() {
+		var term models.Tag
+		if err := rows.Scan(&term.ID, &term.Name, &term.Count); err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	return terms, rows.Err()
+}
+
+// termByID returns a tag or category with its number of posts of any status
+func termByID(tx *sql.Tx, t taxonomy, id int64) (*models.Tag, error) {
+	var term models.Tag
+	err := tx.QueryRow(t.counts+` WHERE `+t.table+`.id = ? GROUP BY 1`, id).Scan(&term.ID, &term.Name, &term.Count)
+	if err == sql.ErrNoRows {
+		return nil, t.notFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &term, nil
+}
+
+func (db *DB) renameTerm(t taxonomy, id int64, name string) (*models.Tag, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidName
+	}
+
+	var term *models.Tag
+	err := db.withTx(func(tx *sql.Tx) error {
+		var other int64
+		err := tx.QueryRow(`SELECT id FROM `+t.table+` WHERE name = ?`, name).Scan(&other)
+		if err == nil && other != id {
+			return t.exists
+		}
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		res, err := tx.Exec(`UPDATE `+t.table+` SET name = ? WHERE id = ?`, name, id)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return t.notFound
+		}
+		if _, err := tx.Exec(t.refresh, id); err != nil {
+			return err
+		}
+		term, err = termByID(tx, t, id)
+		return err
+	})
+	return term, err
+}
+
+func (db *DB) mergeTerms(t taxonomy, src, dst int64) (*models.Tag, error) {
+	if src == dst {
+		return nil, ErrMergeIntoSelf
+	}
+
+	var term *models.Tag
+	err := db.withTx(func(tx *sql.Tx) error {
+		for _, id := range []int64{src, dst} {
+			if _, err := termByID(tx, t, id); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(t.merge, src, dst); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM `+t.table+` WHERE id = ?`, src); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(t.refresh, dst); err != nil {
+			return err
+		}
+		if err := refreshRelatedPosts(tx); err != nil {
+			return err
+		}
+		var err error
+		term, err = termByID(tx, t, dst)
+		return err
+	})
+	return term, err
+}
+
+func categories(terms []models.Tag) []models.Category {
+	if terms == nil {
+		return nil
+	}
+	result := make([]models.Category, len(terms))
+	for i, t := range terms {
+		result[i] = models.Category(t)
+	}
+	return result
+}
+
+// termID returns the ID and canonical name of the tag or category called
+// name, creating it if it doesn't exist
+func termID(tx *sql.Tx, table, name string) (int64, string, error) {
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO `+table+` (name) VALUES (?)`, name); err != nil {
+		return 0, "", err
+	}
+	var id int64
+	var canonical string
+	err := tx.QueryRow(`SELECT id, name FROM `+table+` WHERE name = ?`, name).Scan(&id, &canonical)
+	return id, canonical, err
+}
+
+// setPostTaxonomy links a saved post to its category and tags, creating any
+// that are new, and stores their canonical names on the post row and in post.
+// Blank and repeated tags are dropped.
+func setPostTaxonomy(tx *sql.Tx, post *models.Post) error {
+	var categoryID sql.NullInt64
+	post.Category = strings.TrimSpace(post.Category)
+	if post.Category != "" {
+		id, name, err := termID(tx, categoryTaxonomy.table, post.Category)
+		if err != nil {
+			return err
+		}
+		categoryID = sql.NullInt64{Int64: id, Valid: true}
+		post.Category = name
+	}
+
+	if _, err := tx.Exec(`DELETE FROM post_tags WHERE post_id = ?`, post.ID); err != nil {
+		return err
+	}
+	var tags []string
+	for i, tag := range post.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		id, name, err := termID(tx, tagTaxonomy.table, tag)
+		if err != nil {
+			return err
+		}
+		res, err := tx.Exec(`INSERT OR IGNORE INTO post_tags (post_id, tag_id, position) VALUES (?, ?, ?)`, post.ID, id, i)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			tags = append(tags, name)
+		}
+	}
+	post.Tags = tags
+
+	if _, err := tx.Exec(`UPDATE posts SET category = ?, category_id = ?, tags = ? WHERE id = ?`,
+		post.Category, categoryID, joinTags(tags), post.ID); err != nil {
+		return err
+	}
+	return pruneTaxonomy(tx)
+}
+
+// pruneTaxonomy deletes tags and categories no post uses any more
+func pruneTaxonomy(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM post_tags);
+		DELETE FROM categories WHERE id NOT IN (SELECT category_id FROM posts WHERE category_id IS NOT NULL);
+	`)
+	return err
+}
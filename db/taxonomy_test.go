@@ -0,0 +1,249 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestTagsAndCategoriesMigrationBackfills(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// Roll back to the schema before tags and categories had tables
+	for {
+		m, err := db.RollbackMigration()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Version == 9 {
+			break
+		}
+	}
+
+	// Rows as the comma-joined columns held them before the migration
+	rows := []struct{ id, category, tags string }{
+		{"old", "DevOps", "Go, k8s,,helm"},
+		{"new", "devops ", "K8S,go,go"},
+		{"plain", "", ""},
+	}
+	for i, r := range rows {
+		date := time.Date(2025, 1, 1+i, 0, 0, 0, 0, time.UTC)
+		if _, err := db.conn.Exec(`INSERT INTO posts (id, title, date, category, summary, content, tags) VALUES (?, ?, ?, ?, '', '', ?)`,
+			r.id, r.id, date, r.category, r.tags); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	want := map[string]struct {
+		category string
+		tags     []string
+	}{
+		"old":   {"DevOps", []string{"Go", "k8s", "helm"}},
+		"new":   {"DevOps", []string{"k8s", "Go"}},
+		"plain": {"", nil},
+	}
+	for id, w := range want {
+		post, err := db.GetPostByID(id)
+		if err != nil || post == nil {
+			t.Fatalf("GetPostByID(%s) = %v, %v", id, post, err)
+		}
+		if post.Category != w.category {
+			t.Errorf("%s: category = %q, want %q", id, post.Category, w.category)
+		}
+		if len(post.Tags) != len(w.tags) || (len(w.tags) > 0 && !reflect.DeepEqual(post.Tags, w.tags)) {
+			t.Errorf("%s: tags = %q, want %q", id, post.Tags, w.tags)
+		}
+	}
+
+	tags, err := db.GetTagCounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTags := []models.Tag{{Name: "Go", Count: 2}, {Name: "helm", Count: 1}, {Name: "k8s", Count: 2}}
+	if len(tags) != len(wantTags) {
+		t.Fatalf("GetTagCounts = %+v, want %+v", tags, wantTags)
+	}
+	for i, w := range wantTags {
+		if tags[i].Name != w.Name || tags[i].Count != w.Count {
+			t.Errorf("tag %d = %+v, want %+v", i, tags[i], w)
+		}
+	}
+}
+
+func TestSavePostUsesCanonicalNames(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now().Add(-time.Hour)
+	first := &models.Post{ID: "first", Title: "First", Date: now, Category: "Kubernetes", Tags: []string{"K8s", "Helm"}}
+	second := &models.Post{ID: "second", Title: "Second", Date: now, Category: " kubernetes", Tags: []string{"k8s", "HELM", "helm", " "}}
+	for _, p := range []*models.Post{first, second} {
+		if err := db.SavePost(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if second.Category != "Kubernetes" || !reflect.DeepEqual(second.Tags, []string{"K8s", "Helm"}) {
+		t.Errorf("Expected canonical names on save, got %q %q", second.Category, second.Tags)
+	}
+	saved, _ := db.GetPostByID("second")
+	if saved.Category != "Kubernetes" || !reflect.DeepEqual(saved.Tags, []string{"K8s", "Helm"}) {
+		t.Errorf("Expected canonical names stored, got %q %q", saved.Category, saved.Tags)
+	}
+
+	posts, err := db.SearchPostsByTag("k8S")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 2 || posts[0].ID != "second" {
+		t.Errorf("Expected both posts newest first for any case, got %v", postIDs(posts))
+	}
+
+	categories, err := db.GetCategoryCounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(categories) != 1 || categories[0].Count != 2 {
+		t.Errorf("Expected one category with 2 posts, got %+v", categories)
+	}
+
+	// Dropping the last use of a tag removes it
+	first.Tags = []string{"K8s"}
+	second.Tags = nil
+	for _, p := range []*models.Post{first, second} {
+		if err := db.SavePost(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.DeletePost("first"); err != nil {
+		t.Fatal(err)
+	}
+	tags, _ := db.GetTagCountsForAdmin()
+	if len(tags) != 0 {
+		t.Errorf("Expected unused tags to be pruned, got %+v", tags)
+	}
+	if tag, _ := db.GetTag("k8s"); tag != nil {
+		t.Errorf("Expected no tag, got %+v", tag)
+	}
+}
+
+func TestRenameAndMergeTags(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now().Add(-time.Hour)
+	posts := []*models.Post{
+		{ID: "a", Title: "A", Date: now, Tags: []string{"kubernetes", "go"}},
+		{ID: "b", Title: "B", Date: now, Tags: []string{"k8s"}},
+		{ID: "c", Title: "C", Date: now, Tags: []string{"k8s", "kubernetes"}},
+	}
+	for _, p := range posts {
+		if err := db.SavePost(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	k8s, _ := db.GetTag("k8s")
+	kube, _ := db.GetTag("kubernetes")
+	golang, _ := db.GetTag("go")
+
+	tests := []struct {
+		name    string
+		id      int64
+		newName string
+		wantErr error
+	}{
+		{"case only", golang.ID, "Go", nil},
+		{"taken by another tag", golang.ID, "K8S", ErrTagExists},
+		{"empty", golang.ID, "  ", ErrInvalidName},
+		{"comma", golang.ID, "go,lang", ErrInvalidName},
+		{"unknown", 999, "rust", ErrTagNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := db.RenameTag(tt.id, tt.newName)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("RenameTag = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	if a, _ := db.GetPostByID("a"); !reflect.DeepEqual(a.Tags, []string{"kubernetes", "Go"}) {
+		t.Errorf("Expected the rename on the post, got %q", a.Tags)
+	}
+
+	merged, err := db.MergeTags(k8s.ID, kube.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Name != "kubernetes" || merged.Count != 3 {
+		t.Errorf("Expected kubernetes on 3 posts, got %+v", merged)
+	}
+	for id, want := range map[string][]string{"b": {"kubernetes"}, "c": {"kubernetes"}} {
+		if p, _ := db.GetPostByID(id); !reflect.DeepEqual(p.Tags, want) {
+			t.Errorf("%s: tags = %q, want %q", id, p.Tags, want)
+		}
+	}
+	if tag, _ := db.GetTag("k8s"); tag != nil {
+		t.Error("Expected the merged tag to be gone")
+	}
+
+	if _, err := db.MergeTags(kube.ID, kube.ID); !errors.Is(err, ErrMergeIntoSelf) {
+		t.Errorf("Expected ErrMergeIntoSelf, got %v", err)
+	}
+	if _, err := db.MergeTags(k8s.ID, kube.ID); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("Expected ErrTagNotFound, got %v", err)
+	}
+}
+
+func TestRenameAndMergeCategories(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now().Add(-time.Hour)
+	for _, p := range []*models.Post{
+		{ID: "a", Title: "A", Date: now, Category: "K8s"},
+		{ID: "b", Title: "B", Date: now, Category: "Kubernetes"},
+	} {
+		if err := db.SavePost(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	k8s, _ := db.GetCategory("k8s")
+	kube, _ := db.GetCategory("kubernetes")
+
+	if _, err := db.RenameCategory(k8s.ID, "KUBERNETES"); !errors.Is(err, ErrCategoryExists) {
+		t.Errorf("Expected ErrCategoryExists, got %v", err)
+	}
+	renamed, err := db.RenameCategory(kube.ID, "Cloud Native")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renamed.Name != "Cloud Native" || renamed.Count != 1 {
+		t.Errorf("Unexpected renamed category %+v", renamed)
+	}
+
+	merged, err := db.MergeCategories(k8s.ID, kube.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Count != 2 {
+		t.Errorf("Expected 2 posts after the merge, got %+v", merged)
+	}
+	posts, _, err := db.ListPosts("cloud native", PageOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 2 || posts[0].Category != "Cloud Native" {
+		t.Errorf("Expected both posts in Cloud Native, got %+v", posts)
+	}
+	if _, err := db.MergeCategories(999, kube.ID); !errors.Is(err, ErrCategoryNotFound) {
+		t.Errorf("Expected ErrCategoryNotFound, got %v", err)
+	}
+}
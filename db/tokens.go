@@ -0,0 +1,154 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+var (
+	// ErrTokenNotFound is returned when no API token has the given ID
+	ErrTokenNotFound = errors.New("API token not found")
+	// ErrInvalidTokenName is returned for a token without a name
+	ErrInvalidTokenName = errors.New("token name must not be empty")
+	// ErrInvalidScope is returned for a token without scopes or with an
+	// unknown one
+	ErrInvalidScope = errors.New("scopes must be one or more of posts:write, comments:moderate and services:write")
+)
+
+// tokenColumns is the column list read by scanToken
+const tokenColumns = `t.id, t.user_id, u.username, t.name, t.prefix, t.scopes, t.created_at, t.expires_at, t.last_used_at`
+
+// tokenTables joins tokens to their users for the username
+const tokenTables = `api_tokens t JOIN users u ON u.id = t.user_id`
+
+func scanToken(row rowScanner) (models.APIToken, error) {
+	var t models.APIToken
+	var scopes string
+	var lastUsed sql.NullTime
+	if err := row.Scan(&t.ID, &t.UserID, &t.Username, &t.Name, &t.Prefix, &scopes, &t.CreatedAt, &t.ExpiresAt, &lastUsed); err != nil {
+		return t, err
+	}
+	t.Scopes = []models.Scope{}
+	for _, s := range strings.Fields(scopes) {
+		t.Scopes = append(t.Scopes, models.Scope(s))
+	}
+	if lastUsed.Valid {
+		t.LastUsedAt = &lastUsed.Time
+	}
+	return t, nil
+}
+
+// CreateAPIToken stores a token for t.UserID whose secret hashes to
+// tokenHash, and sets its ID and creation time. Duplicate scopes are dropped.
+func (db *DB) CreateAPIToken(t *models.APIToken, tokenHash string) error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return ErrInvalidTokenName
+	}
+	scopes := []models.Scope{}
+	seen := map[models.Scope]bool{}
+	for _, s := range t.Scopes {
+		if !s.Valid() {
+			return ErrInvalidScope
+		}
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		return ErrInvalidScope
+	}
+	joined := make([]string, len(scopes))
+	for i, s := range scopes {
+		joined[i] = string(s)
+	}
+
+	t.Scopes = scopes
+	t.CreatedAt = time.Now().UTC()
+	t.ExpiresAt = t.ExpiresAt.UTC()
+	result, err := db.conn.Exec(`
+		INSERT INTO api_tokens (user_id, name, token_hash, prefix, scopes, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, t.UserID, t.Name, tokenHash, t.Prefix, strings.Join(joined, " "), t.CreatedAt, t.ExpiresAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "FOREIGN KEY constraint failed") {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	t.ID, err = result.LastInsertId()
+	return err
+}
+
+// ListAPITokens returns the tokens of a user, or of every user when userID
+// is 0, newest first. Expired tokens are included until they're deleted.
+func (db *DB) ListAPITokens(userID int64) ([]models.APIToken, error) {
+	query := `SELECT ` + tokenColumns + ` FROM ` + tokenTables
+	args := []interface{}{}
+	if userID != 0 {
+		query += ` WHERE t.user_id = ?`
+		args = append(args, userID)
+	}
+	rows, err := db.conn.Query(query+` ORDER BY t.created_at DESC, t.id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// GetAPIToken retrieves a token by ID, or nil if it doesn't exist
+func (db *DB) GetAPIToken(id int64) (*models.APIToken, error) {
+	return db.getToken(`SELECT `+tokenColumns+` FROM `+tokenTables+` WHERE t.id = ?`, id)
+}
+
+// GetAPITokenByHash retrieves the token whose secret hashes to tokenHash, or
+// nil if there is none. It doesn't check the expiry.
+func (db *DB) GetAPITokenByHash(tokenHash string) (*models.APIToken, error) {
+	return db.getToken(`SELECT `+tokenColumns+` FROM `+tokenTables+` WHERE t.token_hash = ?`, tokenHash)
+}
+
+func (db *DB) getToken(query string, args ...interface{}) (*models.APIToken, error) {
+	t, err := scanToken(db.conn.QueryRow(query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// TouchAPIToken records that a token was used
+func (db *DB) TouchAPIToken(id int64, now time.Time) error {
+	_, err := db.conn.Exec(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, now.UTC(), id)
+	return err
+}
+
+// DeleteAPIToken revokes a token
+func (db *DB) DeleteAPIToken(id int64) error {
+	result, err := db.conn.Exec(`DELETE FROM api_tokens WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
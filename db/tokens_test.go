@@ -0,0 +1,101 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestAPITokens(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if _, err := db.BootstrapAdmin("admin", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	admin, _ := db.GetUserByUsername("admin")
+	editor := &models.User{Username: "jane", Role: models.RoleEditor}
+	if err := db.InviteUser(editor, "invite", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+
+	invalid := []struct {
+		name  string
+		token models.APIToken
+		want  error
+	}{
+		{"no name", models.APIToken{UserID: admin.ID, Name: " ", Scopes: []models.Scope{models.ScopePostsWrite}}, ErrInvalidTokenName},
+		{"no scopes", models.APIToken{UserID: admin.ID, Name: "ci"}, ErrInvalidScope},
+		{"unknown scope", models.APIToken{UserID: admin.ID, Name: "ci", Scopes: []models.Scope{"posts:read"}}, ErrInvalidScope},
+		{"unknown user", models.APIToken{UserID: 999, Name: "ci", Scopes: []models.Scope{models.ScopePostsWrite}}, ErrUserNotFound},
+	}
+	for _, tt := range invalid {
+		tok := tt.token
+		tok.ExpiresAt = expires
+		if err := db.CreateAPIToken(&tok, "hash-"+tt.name); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	jenkins := &models.APIToken{
+		UserID:    admin.ID,
+		Name:      " jenkins ",
+		Prefix:    "hl_abc",
+		Scopes:    []models.Scope{models.ScopePostsWrite, models.ScopeServicesWrite, models.ScopePostsWrite},
+		ExpiresAt: expires,
+	}
+	if err := db.CreateAPIToken(jenkins, "jenkins-hash"); err != nil {
+		t.Fatal(err)
+	}
+	if jenkins.ID == 0 || jenkins.Name != "jenkins" || len(jenkins.Scopes) != 2 {
+		t.Errorf("Expected a trimmed name and scopes without duplicates, got %+v", jenkins)
+	}
+	script := &models.APIToken{UserID: editor.ID, Name: "script", Scopes: []models.Scope{models.ScopePostsWrite}, ExpiresAt: expires}
+	if err := db.CreateAPIToken(script, "script-hash"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := db.GetAPITokenByHash("jenkins-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.ID != jenkins.ID || got.Username != "admin" || got.Prefix != "hl_abc" || !got.ExpiresAt.Equal(expires) ||
+		!got.HasScope(models.ScopeServicesWrite) || got.HasScope(models.ScopeCommentsModerate) || got.LastUsedAt != nil {
+		t.Errorf("Unexpected token %+v", got)
+	}
+	if missing, err := db.GetAPITokenByHash("nope"); missing != nil || err != nil {
+		t.Errorf("Expected nil for an unknown hash, got %+v %v", missing, err)
+	}
+
+	if err := db.TouchAPIToken(jenkins.ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.GetAPIToken(jenkins.ID); got == nil || got.LastUsedAt == nil {
+		t.Errorf("Expected the last use to be recorded, got %+v", got)
+	}
+
+	all, err := db.ListAPITokens(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].ID != script.ID {
+		t.Errorf("Expected every token newest first, got %+v", all)
+	}
+	mine, _ := db.ListAPITokens(editor.ID)
+	if len(mine) != 1 || mine[0].ID != script.ID || mine[0].Username != "jane" {
+		t.Errorf("Expected only the editor's token, got %+v", mine)
+	}
+
+	if err := db.DeleteAPIToken(jenkins.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteAPIToken(jenkins.ID); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("Expected ErrTokenNotFound, got %v", err)
+	}
+	if got, _ := db.GetAPIToken(jenkins.ID); got != nil {
+		t.Error("Expected the token to be deleted")
+	}
+}
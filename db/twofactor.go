@@ -0,0 +1,219 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+var (
+	// ErrTOTPEnabled is returned when starting enrollment for a user who
+	// already has two-factor sign-in on
+	ErrTOTPEnabled = errors.New("two-factor sign-in is already on")
+	// ErrTOTPNotStarted is returned when confirming enrollment for a user
+	// who hasn't started it
+	ErrTOTPNotStarted = errors.New("two-factor setup hasn't been started")
+)
+
+// StartTOTP stores a new authenticator secret for a user, to be turned on
+// by EnableTOTP once they've shown a code from it
+func (db *DB) StartTOTP(userID int64, secret string) error {
+	result, err := db.conn.Exec(`UPDATE users SET totp_secret = ? WHERE id = ? AND totp_enabled = 0`, secret, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n > 0 {
+		return nil
+	}
+
+	user, err := db.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	return ErrTOTPEnabled
+}
+
+// EnableTOTP turns on two-factor sign-in with the secret from StartTOTP,
+// recording step as used and replacing the user's recovery codes
+func (db *DB) EnableTOTP(userID int64, step int64, recoveryHashes []string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE users SET totp_enabled = 1, totp_last_step = ?
+		WHERE id = ? AND totp_enabled = 0 AND totp_secret != ''
+	`, step, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrTOTPNotStarted
+	}
+	if err := replaceRecoveryCodes(tx, userID, recoveryHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DisableTOTP turns off two-factor sign-in for a user and forgets their
+// secret, recovery codes and trusted browsers
+func (db *DB) DisableTOTP(userID int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE users SET totp_enabled = 0, totp_secret = '', totp_last_step = 0 WHERE id = ?
+	`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM trusted_devices WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep records that a user signed in with the code for a time step,
+// reporting false if that step or a later one was already used
+func (db *DB) UseTOTPStep(userID int64, step int64) (bool, error) {
+	result, err := db.conn.Exec(`
+		UPDATE users SET totp_last_step = ?
+		WHERE id = ? AND totp_enabled = 1 AND totp_last_step < ?
+	`, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ReplaceRecoveryCodes replaces a user's recovery codes with new ones
+func (db *DB) ReplaceRecoveryCodes(userID int64, hashes []string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, hashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int64, hashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if _, err := tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode uses up the recovery code of a user with the hash,
+// reporting false if they have no such unused code
+func (db *DB) UseRecoveryCode(userID int64, hash string, now time.Time) (bool, error) {
+	result, err := db.conn.Exec(`
+		UPDATE recovery_codes SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+	`, now.UTC(), userID, hash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has left
+func (db *DB) CountRecoveryCodes(userID int64) (int, error) {
+	var n int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL`, userID).Scan(&n)
+	return n, err
+}
+
+// TrustDevice lets the browser whose cookie token hashes to id skip the code
+// step for a user until expires
+func (db *DB) TrustDevice(id string, userID int64, now, expires time.Time) error {
+	if _, err := db.conn.Exec(`DELETE FROM trusted_devices WHERE expires_at <= ?`, now.UTC()); err != nil {
+		return err
+	}
+	_, err := db.conn.Exec(`
+		INSERT INTO trusted_devices (id, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)
+	`, id, userID, now.UTC(), expires.UTC())
+	return err
+}
+
+// IsTrustedDevice reports whether the browser with the token hash id may skip
+// the code step for a user at now
+func (db *DB) IsTrustedDevice(id string, userID int64, now time.Time) (bool, error) {
+	var n int
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*) FROM trusted_devices WHERE id = ? AND user_id = ? AND expires_at > ?
+	`, id, userID, now.UTC()).Scan(&n)
+	return n > 0, err
+}
+
+// CreateLoginChallenge stores a sign-in waiting for its code, clearing out
+// expired ones
+func (db *DB) CreateLoginChallenge(c *models.LoginChallenge, now time.Time) error {
+	if _, err := db.conn.Exec(`DELETE FROM login_challenges WHERE expires_at <= ?`, now.UTC()); err != nil {
+		return err
+	}
+	_, err := db.conn.Exec(`
+		INSERT INTO login_challenges (id, user_id, expires_at, attempts) VALUES (?, ?, ?, ?)
+	`, c.ID, c.UserID, c.ExpiresAt.UTC(), c.Attempts)
+	return err
+}
+
+// GetLoginChallenge retrieves a sign-in waiting for its code that hasn't
+// expired by now, or nil if there is none
+func (db *DB) GetLoginChallenge(id string, now time.Time) (*models.LoginChallenge, error) {
+	var c models.LoginChallenge
+	err := db.conn.QueryRow(`
+		SELECT id, user_id, expires_at, attempts FROM login_challenges WHERE id = ? AND expires_at > ?
+	`, id, now.UTC()).Scan(&c.ID, &c.UserID, &c.ExpiresAt, &c.Attempts)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+// FailLoginChallenge counts a wrong code against a sign-in and returns how
+// many wrong codes it has had
+func (db *DB) FailLoginChallenge(id string) (int, error) {
+	if _, err := db.conn.Exec(`UPDATE login_challenges SET attempts = attempts + 1 WHERE id = ?`, id); err != nil {
+		return 0, err
+	}
+	var attempts int
+	err := db.conn.QueryRow(`SELECT attempts FROM login_challenges WHERE id = ?`, id).Scan(&attempts)
+	return attempts, err
+}
+
+// DeleteLoginChallenge deletes a sign-in waiting for its code, if it exists
+func (db *DB) DeleteLoginChallenge(id string) error {
+	_, err := db.conn.Exec(`DELETE FROM login_challenges WHERE id = ?`, id)
+	return err
+}
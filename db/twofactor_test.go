@@ -0,0 +1,192 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestTOTPEnrollment(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if _, err := db.BootstrapAdmin("admin", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	admin, _ := db.GetUserByUsername("admin")
+
+	if err := db.EnableTOTP(admin.ID, 1, nil); !errors.Is(err, ErrTOTPNotStarted) {
+		t.Errorf("Expected ErrTOTPNotStarted before setup, got %v", err)
+	}
+	if err := db.StartTOTP(999, "SECRET"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+	if err := db.StartTOTP(admin.ID, "SECRET"); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := db.GetUserByID(admin.ID); u.TOTPEnabled || u.TOTPSecret != "SECRET" {
+		t.Errorf("Expected a pending secret, got %+v", u)
+	}
+
+	if err := db.EnableTOTP(admin.ID, 100, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := db.GetUserByID(admin.ID); !u.TOTPEnabled {
+		t.Error("Expected two-factor sign-in to be on")
+	}
+	if err := db.StartTOTP(admin.ID, "OTHER"); !errors.Is(err, ErrTOTPEnabled) {
+		t.Errorf("Expected ErrTOTPEnabled, got %v", err)
+	}
+
+	// Each step works once, and only after the one used to enable
+	for _, tt := range []struct {
+		step int64
+		want bool
+	}{{100, false}, {99, false}, {101, true}, {101, false}, {103, true}} {
+		ok, err := db.UseTOTPStep(admin.ID, tt.step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.want {
+			t.Errorf("Step %d: expected %t, got %t", tt.step, tt.want, ok)
+		}
+	}
+
+	if err := db.DisableTOTP(admin.ID); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := db.GetUserByID(admin.ID)
+	if u.TOTPEnabled || u.TOTPSecret != "" {
+		t.Errorf("Expected two-factor sign-in to be off, got %+v", u)
+	}
+	if n, _ := db.CountRecoveryCodes(admin.ID); n != 0 {
+		t.Errorf("Expected recovery codes to be removed, got %d", n)
+	}
+	if ok, _ := db.UseTOTPStep(admin.ID, 200); ok {
+		t.Error("Expected codes not to be accepted once off")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if _, err := db.BootstrapAdmin("admin", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	admin, _ := db.GetUserByUsername("admin")
+	now := time.Now()
+
+	if err := db.ReplaceRecoveryCodes(admin.ID, []string{"a", "b", "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := db.UseRecoveryCode(admin.ID, "b", now); !ok || err != nil {
+		t.Errorf("Expected the code to work, got %t %v", ok, err)
+	}
+	if ok, _ := db.UseRecoveryCode(admin.ID, "b", now); ok {
+		t.Error("Expected a used code not to work again")
+	}
+	if ok, _ := db.UseRecoveryCode(admin.ID, "z", now); ok {
+		t.Error("Expected an unknown code not to work")
+	}
+	if n, _ := db.CountRecoveryCodes(admin.ID); n != 2 {
+		t.Errorf("Expected 2 codes left, got %d", n)
+	}
+
+	if err := db.ReplaceRecoveryCodes(admin.ID, []string{"d"}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := db.UseRecoveryCode(admin.ID, "a", now); ok {
+		t.Error("Expected replaced codes not to work")
+	}
+	if n, _ := db.CountRecoveryCodes(admin.ID); n != 1 {
+		t.Errorf("Expected 1 code left, got %d", n)
+	}
+}
+
+func TestTrustedDevices(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if _, err := db.BootstrapAdmin("admin", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	admin, _ := db.GetUserByUsername("admin")
+	now := time.Now().UTC().Truncate(time.Second)
+
+	if err := db.TrustDevice("laptop", admin.ID, now, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		id     string
+		userID int64
+		at     time.Time
+		want   bool
+	}{
+		{"trusted", "laptop", admin.ID, now, true},
+		{"other user", "laptop", admin.ID + 1, now, false},
+		{"unknown", "phone", admin.ID, now, false},
+		{"expired", "laptop", admin.ID, now.Add(time.Hour), false},
+	}
+	for _, tt := range tests {
+		got, err := db.IsTrustedDevice(tt.id, tt.userID, tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %t, got %t", tt.name, tt.want, got)
+		}
+	}
+
+	if err := db.DisableTOTP(admin.ID); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := db.IsTrustedDevice("laptop", admin.ID, now); ok {
+		t.Error("Expected turning off two-factor sign-in to forget trusted browsers")
+	}
+}
+
+func TestLoginChallenges(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if _, err := db.BootstrapAdmin("admin", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	admin, _ := db.GetUserByUsername("admin")
+	now := time.Now().UTC().Truncate(time.Second)
+
+	if err := db.CreateLoginChallenge(&models.LoginChallenge{ID: "c", UserID: admin.ID, ExpiresAt: now.Add(time.Minute)}, now); err != nil {
+		t.Fatal(err)
+	}
+	c, err := db.GetLoginChallenge("c", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c == nil || c.UserID != admin.ID || c.Attempts != 0 {
+		t.Errorf("Unexpected challenge %+v", c)
+	}
+	if c, _ := db.GetLoginChallenge("c", now.Add(time.Minute)); c != nil {
+		t.Error("Expected an expired challenge not to be found")
+	}
+
+	for want := 1; want <= 2; want++ {
+		attempts, err := db.FailLoginChallenge("c")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attempts != want {
+			t.Errorf("Expected %d attempts, got %d", want, attempts)
+		}
+	}
+
+	if err := db.DeleteLoginChallenge("c"); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := db.GetLoginChallenge("c", now); c != nil {
+		t.Error("Expected the challenge to be deleted")
+	}
+}
@@ -0,0 +1,304 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// Uptime bucket sizes stored in service_uptime
+const (
+	BucketHour = "hour"
+	BucketDay  = "day"
+)
+
+// hourlyRetention is how long hourly buckets are kept; daily ones are kept forever
+const hourlyRetention = 90 * 24 * time.Hour
+
+var (
+	// ErrIncidentNotFound is returned when no incident has the given ID
+	ErrIncidentNotFound = errors.New("incident not found")
+	// ErrEmptyNote is returned when adding an incident note without text
+	ErrEmptyNote = errors.New("note is empty")
+)
+
+// updateIncidents opens or resolves the service's incident after a check
+func updateIncidents(tx *sql.Tx, result models.CheckResult) error {
+	var openID int64
+	err := tx.QueryRow(`SELECT id FROM incidents WHERE service_id = ? AND resolved_at IS NULL`, result.ServiceID).Scan(&openID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	open := err == nil
+
+	switch {
+	case !result.Up && !open:
+		_, err = tx.Exec(`INSERT INTO incidents (service_id, started_at, cause) VALUES (?, ?, ?)`,
+			result.ServiceID, result.CheckedAt.UTC(), result.Error)
+	case result.Up && open:
+		_, err = tx.Exec(`UPDATE incidents SET resolved_at = ? WHERE id = ?`, result.CheckedAt.UTC(), openID)
+	default:
+		err = nil
+	}
+	return err
+}
+
+// RollupChecks aggregates raw checks before until into hourly and daily
+// uptime buckets. until should be on an hour boundary so no hour is rolled
+// up while checks are still arriving for it.
+//
+// Only hours from the latest rolled-up hour onwards are recomputed, so it is
+// cheap to call after every round of checks. Raw checks must not be pruned
+// past the last rolled-up hour; PruneChecks with a cutoff before until is safe.
+func (db *DB) RollupChecks(until time.Time) error {
+	until = until.UTC()
+
+	return db.withTx(func(tx *sql.Tx) error {
+		// Scanning the column (not MAX of it) keeps its DATETIME type
+		var from time.Time
+		err := tx.QueryRow(`
+			SELECT period_start FROM service_uptime WHERE bucket = ? ORDER BY period_start DESC LIMIT 1
+		`, BucketHour).Scan(&from)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		// Recount every hour in [from, until) from the raw checks
+		rows, err := tx.Query(`
+			SELECT service_id, checked_at, up FROM service_checks
+			WHERE checked_at >= ? AND checked_at < ?
+		`, from, until)
+		if err != nil {
+			return err
+		}
+		type key struct {
+			service int64
+			start   time.Time
+		}
+		hours := make(map[key]*models.UptimeBucket)
+		for rows.Next() {
+			var id int64
+			var checkedAt time.Time
+			var up bool
+			if err := rows.Scan(&id, &checkedAt, &up); err != nil {
+				rows.Close()
+				return err
+			}
+			k := key{id, checkedAt.UTC().Truncate(time.Hour)}
+			b, ok := hours[k]
+			if !ok {
+				b = &models.UptimeBucket{Start: k.start}
+				hours[k] = b
+			}
+			b.Checks++
+			if up {
+				b.Up++
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		days := make(map[key]bool)
+		for k, b := range hours {
+			if err := upsertBucket(tx, k.service, BucketHour, b); err != nil {
+				return err
+			}
+			days[key{k.service, startOfDay(k.start)}] = true
+		}
+
+		// Each touched day is the sum of its hours
+		for k := range days {
+			b := models.UptimeBucket{Start: k.start}
+			err := tx.QueryRow(`
+				SELECT COALESCE(SUM(checks), 0), COALESCE(SUM(up), 0) FROM service_uptime
+				WHERE service_id = ? AND bucket = ? AND period_start >= ? AND period_start < ?
+			`, k.service, BucketHour, k.start, k.start.AddDate(0, 0, 1)).Scan(&b.Checks, &b.Up)
+			if err != nil {
+				return err
+			}
+			if err := upsertBucket(tx, k.service, BucketDay, &b); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec(`DELETE FROM service_uptime WHERE bucket = ? AND period_start < ?`,
+			BucketHour, until.Add(-hourlyRetention))
+		return err
+	})
+}
+
+func upsertBucket(tx *sql.Tx, serviceID int64, bucket string, b *models.UptimeBucket) error {
+	_, err := tx.Exec(`
+		INSERT INTO service_uptime (service_id, bucket, period_start, checks, up)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(service_id, bucket, period_start) DO UPDATE SET checks = excluded.checks, up = excluded.up
+	`, serviceID, bucket, b.Start.UTC(), b.Checks, b.Up)
+	return err
+}
+
+// EmptyUptime returns the count buckets GetUptime covers, without any checks
+func EmptyUptime(bucket string, count int, now time.Time) []models.UptimeBucket {
+	first, step := uptimeRange(bucket, count, now)
+	series := make([]models.UptimeBucket, count)
+	for i := range series {
+		series[i].Start = step(first, i)
+	}
+	return series
+}
+
+// uptimeRange returns the start of the first of count buckets ending with the
+// one containing now, and a function to move n buckets from a start time
+func uptimeRange(bucket string, count int, now time.Time) (time.Time, func(time.Time, int) time.Time) {
+	step := func(t time.Time, n int) time.Time { return t.Add(time.Duration(n) * time.Hour) }
+	last := now.UTC().Truncate(time.Hour)
+	if bucket == BucketDay {
+		step = func(t time.Time, n int) time.Time { return t.AddDate(0, 0, n) }
+		last = startOfDay(now.UTC())
+	}
+	return step(last, -(count - 1)), step
+}
+
+// GetUptime returns count buckets of the given size per service, oldest
+// first and ending with the bucket containing now. Periods without
+// rolled-up checks are included with zero checks; services without any
+// are absent.
+func (db *DB) GetUptime(bucket string, count int, now time.Time) (map[int64][]models.UptimeBucket, error) {
+	first, step := uptimeRange(bucket, count, now)
+
+	rows, err := db.conn.Query(`
+		SELECT service_id, period_start, checks, up FROM service_uptime
+		WHERE bucket = ? AND period_start >= ?
+	`, bucket, first)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]map[time.Time]models.UptimeBucket)
+	for rows.Next() {
+		var id int64
+		var b models.UptimeBucket
+		if err := rows.Scan(&id, &b.Start, &b.Checks, &b.Up); err != nil {
+			return nil, err
+		}
+		if counts[id] == nil {
+			counts[id] = make(map[time.Time]models.UptimeBucket)
+		}
+		counts[id][b.Start.UTC()] = b
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make(map[int64][]models.UptimeBucket, len(counts))
+	for id, byStart := range counts {
+		series := make([]models.UptimeBucket, count)
+		for i := range series {
+			start := step(first, i)
+			b := byStart[start]
+			b.Start = start
+			if b.Checks > 0 {
+				b.Uptime = float64(b.Up) * 100 / float64(b.Checks)
+			}
+			series[i] = b
+		}
+		result[id] = series
+	}
+	return result, nil
+}
+
+// ListIncidents returns incidents that started after since or are still
+// open, newest first, with their notes
+func (db *DB) ListIncidents(since time.Time) ([]models.Incident, error) {
+	rows, err := db.conn.Query(`
+		SELECT i.id, i.service_id, s.name, i.started_at, i.resolved_at, i.cause
+		FROM incidents i
+		JOIN services s ON s.id = i.service_id
+		WHERE i.started_at >= ? OR i.resolved_at IS NULL
+		ORDER BY i.started_at DESC, i.id DESC
+	`, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	incidents := []models.Incident{}
+	index := make(map[int64]int)
+	for rows.Next() {
+		var inc models.Incident
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(&inc.ID, &inc.ServiceID, &inc.ServiceName, &inc.StartedAt, &resolvedAt, &inc.Cause); err != nil {
+			return nil, err
+		}
+		if resolvedAt.Valid {
+			t := resolvedAt.Time
+			inc.ResolvedAt = &t
+		}
+		inc.Notes = []models.IncidentNote{}
+		index[inc.ID] = len(incidents)
+		incidents = append(incidents, inc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(incidents) == 0 {
+		return incidents, nil
+	}
+
+	notes, err := db.conn.Query(`
+		SELECT n.id, n.incident_id, n.author, n.body, n.created_at
+		FROM incident_notes n
+		JOIN incidents i ON i.id = n.incident_id
+		WHERE i.started_at >= ? OR i.resolved_at IS NULL
+		ORDER BY n.created_at, n.id
+	`, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer notes.Close()
+
+	for notes.Next() {
+		var n models.IncidentNote
+		if err := notes.Scan(&n.ID, &n.IncidentID, &n.Author, &n.Body, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		if i, ok := index[n.IncidentID]; ok {
+			incidents[i].Notes = append(incidents[i].Notes, n)
+		}
+	}
+	return incidents, notes.Err()
+}
+
+// AddIncidentNote attaches an admin note to an incident
+func (db *DB) AddIncidentNote(incidentID int64, author, body string) (*models.IncidentNote, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, ErrEmptyNote
+	}
+
+	var exists bool
+	if err := db.conn.QueryRow(`SELECT EXISTS(SELECT 1 FROM incidents WHERE id = ?)`, incidentID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrIncidentNotFound
+	}
+
+	note := &models.IncidentNote{IncidentID: incidentID, Author: author, Body: body, CreatedAt: time.Now().UTC()}
+	result, err := db.conn.Exec(`INSERT INTO incident_notes (incident_id, author, body, created_at) VALUES (?, ?, ?, ?)`,
+		incidentID, author, body, note.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	note.ID, err = result.LastInsertId()
+	return note, err
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
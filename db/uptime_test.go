@@ -0,0 +1,158 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestRecordCheckOpensAndResolvesIncidents(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	service := &models.Service{Name: "Jenkins", Status: "internal"}
+	if err := db.CreateService(service); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	checks := []models.CheckResult{
+		{Up: true},
+		{Up: false, Error: "connection refused"},
+		{Up: false, Error: "timeout"},
+		{Up: true},
+		{Up: false, Error: "502"},
+	}
+	for i, c := range checks {
+		c.ServiceID = service.ID
+		c.CheckedAt = start.Add(time.Duration(i) * time.Minute)
+		if err := db.RecordCheck(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	incidents, err := db.ListIncidents(start.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incidents) != 2 {
+		t.Fatalf("Expected 2 incidents, got %+v", incidents)
+	}
+
+	latest, first := incidents[0], incidents[1]
+	if !latest.Ongoing() || latest.Cause != "502" || latest.ServiceName != "Jenkins" {
+		t.Errorf("Unexpected ongoing incident: %+v", latest)
+	}
+	if first.Ongoing() || first.Cause != "connection refused" {
+		t.Errorf("Unexpected resolved incident: %+v", first)
+	}
+	if got := first.ResolvedAt.Sub(first.StartedAt); got != 2*time.Minute {
+		t.Errorf("Expected the incident to last 2 minutes, got %v", got)
+	}
+}
+
+func TestIncidentNotes(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	service := &models.Service{Name: "Gitea", Status: "public"}
+	if err := db.CreateService(service); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RecordCheck(models.CheckResult{ServiceID: service.ID, CheckedAt: time.Now(), Error: "down"}); err != nil {
+		t.Fatal(err)
+	}
+	incidents, _ := db.ListIncidents(time.Now().Add(-time.Hour))
+	id := incidents[0].ID
+
+	if _, err := db.AddIncidentNote(id, "admin", "  Restarted the pod "); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.AddIncidentNote(id, "admin", "   "); err != ErrEmptyNote {
+		t.Errorf("Expected ErrEmptyNote, got %v", err)
+	}
+	if _, err := db.AddIncidentNote(id+100, "admin", "hello"); err != ErrIncidentNotFound {
+		t.Errorf("Expected ErrIncidentNotFound, got %v", err)
+	}
+
+	incidents, _ = db.ListIncidents(time.Now().Add(-time.Hour))
+	notes := incidents[0].Notes
+	if len(notes) != 1 || notes[0].Body != "Restarted the pod" || notes[0].Author != "admin" {
+		t.Errorf("Unexpected notes: %+v", notes)
+	}
+}
+
+func TestRollupChecks(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	service := &models.Service{Name: "Grafana", Status: "internal"}
+	if err := db.CreateService(service); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2025, 6, 2, 10, 30, 0, 0, time.UTC)
+	record := func(at time.Time, up bool) {
+		t.Helper()
+		if err := db.RecordCheck(models.CheckResult{ServiceID: service.ID, CheckedAt: at, Up: up}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Yesterday 23:xx: 2 up. Today 08:xx: 3 up, 1 down. Today 10:xx is unfinished.
+	yesterday := time.Date(2025, 6, 1, 23, 0, 0, 0, time.UTC)
+	record(yesterday.Add(10*time.Minute), true)
+	record(yesterday.Add(20*time.Minute), true)
+	eight := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	for i, up := range []bool{true, true, false, true} {
+		record(eight.Add(time.Duration(i)*time.Minute), up)
+	}
+	record(now.Add(-time.Minute), false)
+
+	if err := db.RollupChecks(now.Truncate(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// Rolling up again, and after pruning, doesn't change the counts
+	if _, err := db.PruneChecks(now.Truncate(time.Hour).Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RollupChecks(now.Truncate(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	daily, err := db.GetUptime(BucketDay, 3, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	days := daily[service.ID]
+	if len(days) != 3 {
+		t.Fatalf("Expected 3 daily buckets, got %+v", days)
+	}
+	if days[0].Checks != 0 || days[0].Level() != "none" {
+		t.Errorf("Expected no data two days ago, got %+v", days[0])
+	}
+	if days[1].Checks != 2 || days[1].Uptime != 100 || days[1].Level() != "up" {
+		t.Errorf("Unexpected yesterday bucket: %+v", days[1])
+	}
+	if days[2].Checks != 4 || days[2].Up != 3 || days[2].Uptime != 75 || days[2].Level() != "down" {
+		t.Errorf("Unexpected today bucket (the unfinished hour is excluded): %+v", days[2])
+	}
+
+	hourly, err := db.GetUptime(BucketHour, 3, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hours := hourly[service.ID]
+	if !hours[0].Start.Equal(eight) || hours[0].Checks != 4 || hours[1].Checks != 0 || hours[2].Checks != 0 {
+		t.Errorf("Unexpected hourly buckets: %+v", hours)
+	}
+
+	// Once the 10:00 hour finishes it is added to today
+	if err := db.RollupChecks(now.Add(time.Hour).Truncate(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	daily, _ = db.GetUptime(BucketDay, 1, now)
+	if today := daily[service.ID][0]; today.Checks != 5 || today.Up != 3 {
+		t.Errorf("Expected the finished hour rolled into today, got %+v", today)
+	}
+}
@@ -0,0 +1,269 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+var (
+	// ErrUserNotFound is returned when no user has the given ID
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserExists is returned when another user already has the username
+	ErrUserExists = errors.New("a user with that username already exists")
+	// ErrInvalidUsername is returned for an empty username or one with spaces
+	ErrInvalidUsername = errors.New("username must not be empty or contain spaces")
+	// ErrInvalidRole is returned for a role other than admin, editor or moderator
+	ErrInvalidRole = errors.New("role must be admin, editor or moderator")
+	// ErrLastAdmin is returned when a change would leave no enabled admin
+	ErrLastAdmin = errors.New("can't remove the last enabled admin")
+	// ErrInviteNotFound is returned for an unknown, used or expired invite
+	ErrInviteNotFound = errors.New("invite link is invalid or has expired")
+)
+
+// userColumns is the column list read by scanUser
+const userColumns = `id, username, email, password_hash, role, disabled, created_at, last_login_at, invite_expires_at,
+	totp_enabled, totp_secret`
+
+func scanUser(row rowScanner) (models.User, error) {
+	var u models.User
+	var lastLogin, inviteExpires sql.NullTime
+	if err := row.Scan(&u.ID, &u.Username, &u.Email, &u.PasswordHash, &u.Role, &u.Disabled, &u.CreatedAt,
+		&lastLogin, &inviteExpires, &u.TOTPEnabled, &u.TOTPSecret); err != nil {
+		return u, err
+	}
+	if lastLogin.Valid {
+		u.LastLoginAt = &lastLogin.Time
+	}
+	if inviteExpires.Valid {
+		u.InviteExpiresAt = &inviteExpires.Time
+	}
+	return u, nil
+}
+
+// validateUser trims the username and email and checks the role
+func validateUser(user *models.User) error {
+	user.Username = strings.TrimSpace(user.Username)
+	user.Email = strings.TrimSpace(user.Email)
+	if user.Username == "" || strings.ContainsAny(user.Username, " \t\n") {
+		return ErrInvalidUsername
+	}
+	if !user.Role.Valid() {
+		return ErrInvalidRole
+	}
+	return nil
+}
+
+// userWriteError maps a unique username violation to ErrUserExists
+func userWriteError(err error) error {
+	if strings.Contains(err.Error(), "UNIQUE constraint failed: users.username") {
+		return ErrUserExists
+	}
+	return err
+}
+
+// ListUsers returns every user, ordered by username
+func (db *DB) ListUsers() ([]models.User, error) {
+	rows, err := db.conn.Query(`SELECT ` + userColumns + ` FROM users ORDER BY username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// GetUserByID retrieves a user, or nil if it doesn't exist
+func (db *DB) GetUserByID(id int64) (*models.User, error) {
+	return db.getUser(`SELECT `+userColumns+` FROM users WHERE id = ?`, id)
+}
+
+// GetUserByUsername retrieves a user by username ignoring case, or nil if
+// there is none
+func (db *DB) GetUserByUsername(username string) (*models.User, error) {
+	return db.getUser(`SELECT `+userColumns+` FROM users WHERE username = ?`, strings.TrimSpace(username))
+}
+
+func (db *DB) getUser(query string, args ...interface{}) (*models.User, error) {
+	u, err := scanUser(db.conn.QueryRow(query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+// BootstrapAdmin creates an admin with the given password hash if there are
+// no users yet, reporting whether it did. Once any user exists it does nothing,
+// so the admin's password is managed from the site after the first start.
+func (db *DB) BootstrapAdmin(username, passwordHash string) (bool, error) {
+	user := &models.User{Username: username, Role: models.RoleAdmin}
+	if err := validateUser(user); err != nil {
+		return false, err
+	}
+
+	result, err := db.conn.Exec(`
+		INSERT INTO users (username, password_hash, role, created_at)
+		SELECT ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM users)
+	`, user.Username, passwordHash, user.Role, time.Now().UTC())
+	if err != nil {
+		return false, userWriteError(err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// InviteUser creates a user without a password and sets its ID. The user
+// signs in after choosing a password with the invite link whose token hashes
+// to tokenHash, until expires.
+func (db *DB) InviteUser(user *models.User, tokenHash string, expires time.Time) error {
+	if err := validateUser(user); err != nil {
+		return err
+	}
+
+	user.CreatedAt = time.Now().UTC()
+	expires = expires.UTC()
+	result, err := db.conn.Exec(`
+		INSERT INTO users (username, email, role, invite_token_hash, invite_expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, user.Username, user.Email, user.Role, tokenHash, expires, user.CreatedAt)
+	if err != nil {
+		return userWriteError(err)
+	}
+
+	user.ID, err = result.LastInsertId()
+	user.PasswordHash = ""
+	user.InviteExpiresAt = &expires
+	return err
+}
+
+// UpdateUser changes a user's role and whether they are disabled. It refuses
+// to demote or disable the last enabled admin. Admins who haven't set a
+// password yet don't count, as their invite may never be accepted.
+func (db *DB) UpdateUser(id int64, role models.Role, disabled bool) (*models.User, error) {
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if role != models.RoleAdmin || disabled {
+		var others int
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM users
+			WHERE role = 'admin' AND disabled = 0 AND password_hash != '' AND id != ?
+		`, id).Scan(&others)
+		if err != nil {
+			return nil, err
+		}
+		if others == 0 {
+			var current models.Role
+			var wasDisabled bool
+			err := tx.QueryRow(`SELECT role, disabled FROM users WHERE id = ?`, id).Scan(&current, &wasDisabled)
+			if err == sql.ErrNoRows {
+				return nil, ErrUserNotFound
+			}
+			if err != nil {
+				return nil, err
+			}
+			if current == models.RoleAdmin && !wasDisabled {
+				return nil, ErrLastAdmin
+			}
+		}
+	}
+
+	result, err := tx.Exec(`UPDATE users SET role = ?, disabled = ? WHERE id = ?`, role, disabled, id)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrUserNotFound
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return db.GetUserByID(id)
+}
+
+// ResetUser clears a user's password and issues a new invite link, replacing
+// any outstanding one. The user can't sign in until they use it.
+func (db *DB) ResetUser(id int64, tokenHash string, expires time.Time) (*models.User, error) {
+	result, err := db.conn.Exec(`
+		UPDATE users SET password_hash = '', invite_token_hash = ?, invite_expires_at = ?
+		WHERE id = ?
+	`, tokenHash, expires.UTC(), id)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrUserNotFound
+	}
+	return db.GetUserByID(id)
+}
+
+// GetUserByInvite retrieves the enabled user with an unexpired invite whose
+// token hashes to tokenHash, or nil if there is none
+func (db *DB) GetUserByInvite(tokenHash string) (*models.User, error) {
+	return db.getUser(`
+		SELECT `+userColumns+` FROM users
+		WHERE invite_token_hash = ? AND invite_expires_at > ? AND disabled = 0
+	`, tokenHash, time.Now().UTC())
+}
+
+// AcceptInvite sets the password of the user invited with tokenHash and uses
+// up the invite
+func (db *DB) AcceptInvite(tokenHash, passwordHash string) (*models.User, error) {
+	user, err := db.GetUserByInvite(tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInviteNotFound
+	}
+
+	result, err := db.conn.Exec(`
+		UPDATE users SET password_hash = ?, invite_token_hash = NULL, invite_expires_at = NULL
+		WHERE id = ? AND invite_token_hash = ?
+	`, passwordHash, user.ID, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	// Lost a race with another use of the same link
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrInviteNotFound
+	}
+
+	user.PasswordHash = passwordHash
+	user.InviteExpiresAt = nil
+	return user, nil
+}
+
+// RecordLogin stores the time a user last signed in
+func (db *DB) RecordLogin(id int64) error {
+	_, err := db.conn.Exec(`UPDATE users SET last_login_at = ? WHERE id = ?`, time.Now().UTC(), id)
+	return err
+}
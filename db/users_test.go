@@ -0,0 +1,156 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestBootstrapAdmin(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	created, err := db.BootstrapAdmin("admin", "hash")
+	if err != nil || !created {
+		t.Fatalf("Expected the first admin to be created, got %v %v", created, err)
+	}
+	// Once there are users, the env credentials are ignored
+	created, err = db.BootstrapAdmin("other", "hash2")
+	if err != nil || created {
+		t.Fatalf("Expected no second bootstrap admin, got %v %v", created, err)
+	}
+
+	user, err := db.GetUserByUsername("ADMIN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user == nil || user.Role != models.RoleAdmin || user.PasswordHash != "hash" {
+		t.Errorf("Expected the bootstrap admin, got %+v", user)
+	}
+	if other, _ := db.GetUserByUsername("other"); other != nil {
+		t.Errorf("Expected no user other, got %+v", other)
+	}
+}
+
+func TestInviteAndAcceptUser(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tests := []struct {
+		name string
+		user models.User
+		want error
+	}{
+		{"valid", models.User{Username: " jane ", Email: "jane@example.com", Role: models.RoleEditor}, nil},
+		{"duplicate ignoring case", models.User{Username: "Jane", Role: models.RoleModerator}, ErrUserExists},
+		{"empty username", models.User{Username: " ", Role: models.RoleEditor}, ErrInvalidUsername},
+		{"space in username", models.User{Username: "jane doe", Role: models.RoleEditor}, ErrInvalidUsername},
+		{"unknown role", models.User{Username: "bob", Role: "owner"}, ErrInvalidRole},
+	}
+	for _, tt := range tests {
+		user := tt.user
+		if err := db.InviteUser(&user, "token-"+tt.name, time.Now().Add(time.Hour)); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	invited, err := db.GetUserByInvite("token-valid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invited == nil || invited.Username != "jane" || invited.PasswordHash != "" || invited.InviteExpiresAt == nil {
+		t.Fatalf("Expected the pending invite for jane, got %+v", invited)
+	}
+
+	user, err := db.AcceptInvite("token-valid", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.PasswordHash != "hash" || user.InviteExpiresAt != nil {
+		t.Errorf("Expected the password set and the invite used, got %+v", user)
+	}
+	if _, err := db.AcceptInvite("token-valid", "again"); !errors.Is(err, ErrInviteNotFound) {
+		t.Errorf("Expected a used invite to be rejected, got %v", err)
+	}
+
+	// Expired links don't work
+	expired := models.User{Username: "late", Role: models.RoleEditor}
+	if err := db.InviteUser(&expired, "token-late", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.AcceptInvite("token-late", "hash"); !errors.Is(err, ErrInviteNotFound) {
+		t.Errorf("Expected an expired invite to be rejected, got %v", err)
+	}
+}
+
+func TestUpdateUserKeepsAnAdmin(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if _, err := db.BootstrapAdmin("admin", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	admin, _ := db.GetUserByUsername("admin")
+	second := models.User{Username: "second", Role: models.RoleAdmin}
+	if err := db.InviteUser(&second, "token", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// An admin who hasn't accepted their invite can't take over
+	if _, err := db.UpdateUser(admin.ID, models.RoleEditor, false); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("Expected demotion with only an invited admin left to fail, got %v", err)
+	}
+	if _, err := db.UpdateUser(admin.ID, models.RoleAdmin, true); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("Expected disabling with only an invited admin left to fail, got %v", err)
+	}
+	if _, err := db.AcceptInvite("token", "hash"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.UpdateUser(admin.ID, models.RoleEditor, false); err != nil {
+		t.Fatalf("Expected demotion with another admin left, got %v", err)
+	}
+	if _, err := db.UpdateUser(second.ID, models.RoleAdmin, true); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("Expected disabling the last admin to fail, got %v", err)
+	}
+	if _, err := db.UpdateUser(second.ID, models.RoleModerator, false); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("Expected demoting the last admin to fail, got %v", err)
+	}
+	if _, err := db.UpdateUser(admin.ID, models.RoleModerator, true); err != nil {
+		t.Errorf("Expected changes to non-admins to work, got %v", err)
+	}
+	if _, err := db.UpdateUser(999, models.RoleEditor, false); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+
+	user, _ := db.GetUserByID(admin.ID)
+	if user.Role != models.RoleModerator || !user.Disabled {
+		t.Errorf("Expected the update to be saved, got %+v", user)
+	}
+}
+
+func TestResetUser(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if _, err := db.BootstrapAdmin("admin", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	admin, _ := db.GetUserByUsername("admin")
+
+	user, err := db.ResetUser(admin.ID, "reset-token", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.PasswordHash != "" || user.InviteExpiresAt == nil {
+		t.Errorf("Expected the password cleared and a new link issued, got %+v", user)
+	}
+	if invited, _ := db.GetUserByInvite("reset-token"); invited == nil || invited.ID != admin.ID {
+		t.Errorf("Expected the reset link to find the user, got %+v", invited)
+	}
+	if _, err := db.ResetUser(999, "x", time.Now()); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
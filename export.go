@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"strings"
+
+	"github.com/tinotenda-alfaneti/homelabsite/cache"
+	"github.com/tinotenda-alfaneti/homelabsite/config"
+	"github.com/tinotenda-alfaneti/homelabsite/db"
+	"github.com/tinotenda-alfaneti/homelabsite/export"
+	"github.com/tinotenda-alfaneti/homelabsite/handlers"
+	"github.com/tinotenda-alfaneti/homelabsite/middleware"
+	"github.com/tinotenda-alfaneti/homelabsite/ogimage"
+	"golang.org/x/time/rate"
+)
+
+const exportUsage = `usage: homelabsite export --out DIR [--base-url URL]
+
+Renders the public site from the database into DIR as static files. Run
+"homelabsite sync" first to pick up content changes. Exporting again into the
+same DIR only rewrites the files that changed.`
+
+// runExport implements the "export" subcommand
+func runExport(configPath, dbPath string, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	out := flags.String("out", "", "directory to write the site to")
+	baseURL := flags.String("base-url", "", "public URL of the mirror (default site.base_url)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return fmt.Errorf("--out is required\n%s", exportUsage)
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	// Feeds, the sitemap and canonical links need absolute URLs, and there's
+	// no request host to fall back on
+	if *baseURL != "" {
+		cfg.AppConfig.Site.BaseURL = *baseURL
+	}
+	if u, err := url.Parse(cfg.AppConfig.Site.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New("set site.base_url in config.yaml or pass --base-url")
+	}
+
+	database, err := db.New(dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	templates, err := parseTemplates()
+	if err != nil {
+		return err
+	}
+	app := &handlers.App{
+		Config:     cfg,
+		Templates:  templates,
+		Auth:       middleware.NewAuthMiddleware(database, middleware.NewMemorySessionStore(), nil),
+		ConfigPath: configPath,
+		DB:         database,
+		Cache:      cache.New(),
+		OGImages:   ogimage.NewCache(getOGCacheDir(dbPath)),
+		Static:     true,
+	}
+	// Every page is fetched in-process, so don't rate limit
+	router, err := newRouter(app, middleware.NewRateLimiter(rate.Inf, 0))
+	if err != nil {
+		return err
+	}
+
+	paths, err := exportPaths(database)
+	if err != nil {
+		return err
+	}
+	staticFS, err := fs.Sub(embedFS, "web/static")
+	if err != nil {
+		return err
+	}
+
+	exporter := &export.Exporter{
+		Handler:  router,
+		Out:      *out,
+		Paths:    paths,
+		Assets:   map[string]fs.FS{"/static/": staticFS},
+		NotFound: "/404.html",
+	}
+	report, err := exporter.Run()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Exported to %s: %s\n", *out, report)
+	return nil
+}
+
+// exportPaths are the paths the sitemap doesn't list: feeds, preview images
+// and robots.txt
+func exportPaths(database *db.DB) ([]string, error) {
+	paths := []string{"/rss", "/feed", "/atom", "/feed.json", "/robots.txt", "/og.png"}
+
+	posts, err := database.GetPostIndex()
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		paths = append(paths, "/og/"+url.PathEscape(post.ID)+".png")
+	}
+
+	tags, err := database.GetTagCounts()
+	if err != nil {
+		return nil, err
+	}
+	categories, err := database.GetCategoryCounts()
+	if err != nil {
+		return nil, err
+	}
+	var listings []string
+	for _, tag := range tags {
+		listings = append(listings, "/tags/"+url.PathEscape(tag.Name))
+	}
+	for _, category := range categories {
+		listings = append(listings, "/category/"+url.PathEscape(category.Name))
+	}
+	for _, listing := range listings {
+		for _, file := range []string{"feed.xml", "atom.xml", "feed.json"} {
+			paths = append(paths, strings.TrimSuffix(listing, "/")+"/"+file)
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,299 @@
+// Package export renders a site through its http.Handler into a directory of
+// static files, for hosting a read-only mirror without the server.
+//
+// Pages are found from the site's sitemap and the extra paths given. HTML
+// pages are written as {path}/index.html so their URLs stay the same, and
+// "?cursor=" page links are rewritten to {path}/page/{n}/. Files whose content
+// hasn't changed are left alone, and files written by a previous export that
+// the site no longer has are removed.
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ManifestFile lists the files of the last export, so the next one can remove
+// those that are gone without touching anything else in the directory
+const ManifestFile = ".export-manifest"
+
+// Exporter writes the pages a handler serves into a directory
+type Exporter struct {
+	Handler http.Handler
+	// Out is the directory the site is written to
+	Out string
+	// Paths are exported along with everything /sitemap.xml lists
+	Paths []string
+	// Assets are copied as they are, keyed by the URL prefix they're served
+	// under, e.g. "/static/"
+	Assets map[string]fs.FS
+	// NotFound, when set, is a path the handler answers with its 404 page,
+	// which is written as 404.html for static hosts to serve
+	NotFound string
+}
+
+// Report counts what an export did with each file
+type Report struct {
+	Written   int
+	Unchanged int
+	Removed   int
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("%d written, %d unchanged, %d removed", r.Written, r.Unchanged, r.Removed)
+}
+
+// pageLink matches the href of a link to another page of a listing
+var pageLink = regexp.MustCompile(`href="(/[^"?]*\?[^"]*)"`)
+
+// run is the state of one export
+type run struct {
+	*Exporter
+	report Report
+	// files are the files written or kept by this export, relative to Out
+	files map[string]bool
+	queue []string
+	seen  map[string]bool
+	// pages maps each "?cursor=" URL to its static path, and pageCount
+	// counts the pages found so far of each listing
+	pages     map[string]string
+	pageCount map[string]int
+}
+
+// Run exports the site. It stops at the first page the handler fails to serve,
+// leaving the files of the previous export in place.
+func (e *Exporter) Run() (Report, error) {
+	r := &run{
+		Exporter:  e,
+		files:     map[string]bool{},
+		seen:      map[string]bool{},
+		pages:     map[string]string{},
+		pageCount: map[string]int{},
+	}
+	if err := os.MkdirAll(e.Out, 0o755); err != nil {
+		return r.report, err
+	}
+
+	r.enqueue("/sitemap.xml")
+	for _, p := range e.Paths {
+		r.enqueue(p)
+	}
+	for len(r.queue) > 0 {
+		p := r.queue[0]
+		r.queue = r.queue[1:]
+		if err := r.export(p); err != nil {
+			return r.report, err
+		}
+	}
+
+	if e.NotFound != "" {
+		rec := r.get(e.NotFound)
+		if rec.Code != http.StatusNotFound {
+			return r.report, fmt.Errorf("GET %s: expected 404, got %d", e.NotFound, rec.Code)
+		}
+		if err := r.write("404.html", r.rewriteLinks(rec.Body.Bytes())); err != nil {
+			return r.report, err
+		}
+	}
+
+	prefixes := make([]string, 0, len(e.Assets))
+	for prefix := range e.Assets {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		if err := r.copyAssets(prefix, e.Assets[prefix]); err != nil {
+			return r.report, err
+		}
+	}
+
+	return r.report, r.prune()
+}
+
+func (r *run) enqueue(p string) {
+	if !r.seen[p] {
+		r.seen[p] = true
+		r.queue = append(r.queue, p)
+	}
+}
+
+func (r *run) get(p string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	r.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, p, nil))
+	return rec
+}
+
+// export fetches one path, queues the pages it leads to and writes it out
+func (r *run) export(p string) error {
+	rec := r.get(p)
+	if rec.Code != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", p, rec.Code)
+	}
+	body := rec.Body.Bytes()
+
+	if isSitemap(p) {
+		locs, err := sitemapLocs(body)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		for _, loc := range locs {
+			r.enqueue(loc)
+		}
+	}
+
+	// Files are named by the decoded path, which is what static hosts look up
+	name, err := url.PathUnescape(r.target(p))
+	if err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		return r.write(strings.TrimPrefix(name, "/"), body)
+	}
+	return r.write(htmlFile(name), r.rewriteLinks(body))
+}
+
+// target is the static path a URL is exported to: itself, or for another
+// page of a listing, the path assigned to it when it was first linked
+func (r *run) target(p string) string {
+	if page, ok := r.pages[p]; ok {
+		return page
+	}
+	return p
+}
+
+// rewriteLinks points links to other pages of a listing at their static
+// paths, queueing the pages not seen yet. Other links with a query string
+// are left as they are.
+func (r *run) rewriteLinks(body []byte) []byte {
+	return pageLink.ReplaceAllFunc(body, func(m []byte) []byte {
+		href := html.UnescapeString(string(pageLink.FindSubmatch(m)[1]))
+		u, err := url.Parse(href)
+		if err != nil {
+			return m
+		}
+		q := u.Query()
+		if len(q) != 1 || q.Get("cursor") == "" {
+			return m
+		}
+
+		page, ok := r.pages[href]
+		if !ok {
+			listing := u.EscapedPath()
+			r.pageCount[listing]++
+			page = strings.TrimSuffix(listing, "/") + "/page/" + strconv.Itoa(r.pageCount[listing]+1) + "/"
+			r.pages[href] = page
+			r.enqueue(href)
+		}
+		return []byte(`href="` + html.EscapeString(page) + `"`)
+	})
+}
+
+// htmlFile is the file a page is served from: index.html in its directory
+func htmlFile(p string) string {
+	return path.Join(strings.TrimPrefix(p, "/"), "index.html")
+}
+
+func (r *run) copyAssets(prefix string, fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		return r.write(path.Join(strings.Trim(prefix, "/"), p), data)
+	})
+}
+
+// write stores a file under Out, leaving it untouched if it already has data
+func (r *run) write(name string, data []byte) error {
+	if name == "" || strings.HasPrefix(path.Clean(name), "..") {
+		return fmt.Errorf("refusing to write %q outside the output directory", name)
+	}
+	r.files[name] = true
+
+	dest := filepath.Join(r.Out, filepath.FromSlash(name))
+	if old, err := os.ReadFile(dest); err == nil && bytes.Equal(old, data) {
+		r.report.Unchanged++
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(dest, data, 0o644); err != nil {
+		return err
+	}
+	r.report.Written++
+	return nil
+}
+
+// prune removes the files of the previous export that this one didn't write,
+// then records this export's files in the manifest
+func (r *run) prune() error {
+	manifest := filepath.Join(r.Out, ManifestFile)
+	old, err := os.ReadFile(manifest)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, name := range strings.Split(string(old), "\n") {
+		if name == "" || r.files[name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(r.Out, filepath.FromSlash(name))); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		r.report.Removed++
+	}
+
+	names := make([]string, 0, len(r.files))
+	for name := range r.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return os.WriteFile(manifest, []byte(strings.Join(names, "\n")+"\n"), 0o644)
+}
+
+// isSitemap reports whether a path is the sitemap or one of its parts
+func isSitemap(p string) bool {
+	return p == "/sitemap.xml" || (strings.HasPrefix(p, "/sitemap-") && strings.HasSuffix(p, ".xml"))
+}
+
+// sitemapLocs returns the paths a sitemap or sitemap index lists
+func sitemapLocs(body []byte) ([]string, error) {
+	var doc struct {
+		URLs     []string `xml:"url>loc"`
+		Sitemaps []string `xml:"sitemap>loc"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, loc := range append(doc.URLs, doc.Sitemaps...) {
+		u, err := url.Parse(strings.TrimSpace(loc))
+		if err != nil {
+			return nil, err
+		}
+		p := u.EscapedPath()
+		if p == "" {
+			p = "/"
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
@@ -0,0 +1,139 @@
+package export
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// fakeSite serves a sitemap, a paginated listing and a few pages
+type fakeSite struct {
+	pages map[string]string
+}
+
+func newFakeSite() *fakeSite {
+	return &fakeSite{pages: map[string]string{
+		"/":                `<a href="/blog">Blog</a>`,
+		"/blog":            `<a href="/blog/hello">Hello</a> <a href="/blog?cursor=abc">Older</a>`,
+		"/blog?cursor=abc": `<a href="/blog">Newer</a> <a href="/blog?cursor=def">Older</a>`,
+		"/blog?cursor=def": `<a href="/blog?cursor=abc">Newer</a>`,
+		"/blog/hello":      `<a href="/search?q=x">Search</a>`,
+		"/blog/bye":        `Bye`,
+	}}
+}
+
+func (s *fakeSite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/sitemap.xml":
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, `<?xml version="1.0"?><urlset>`)
+		for _, p := range []string{"/", "/blog", "/blog/hello", "/blog/bye"} {
+			if _, ok := s.pages[p]; ok {
+				fmt.Fprintf(w, "<url><loc>https://example.com%s</loc></url>", p)
+			}
+		}
+		fmt.Fprint(w, `</urlset>`)
+		return
+	case "/rss":
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, "<rss/>")
+		return
+	}
+	body, ok := s.pages[r.URL.RequestURI()]
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `Not found <a href="/">Home</a>`)
+		return
+	}
+	fmt.Fprint(w, body)
+}
+
+func readFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatalf("Expected %s to be exported: %v", name, err)
+	}
+	return string(data)
+}
+
+func TestRun(t *testing.T) {
+	out := t.TempDir()
+	site := newFakeSite()
+	e := &Exporter{
+		Handler:  site,
+		Out:      out,
+		Paths:    []string{"/rss"},
+		Assets:   map[string]fs.FS{"/static/": fstest.MapFS{"css/style.css": {Data: []byte("body{}")}}},
+		NotFound: "/404.html",
+	}
+
+	report, err := e.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sitemap, rss, 4 pages, 2 more blog pages, 404 and the stylesheet
+	if report != (Report{Written: 10}) {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"index.html", `<a href="/blog">Blog</a>`},
+		{"blog/index.html", `<a href="/blog/page/2/">Older</a>`},
+		{"blog/page/2/index.html", `<a href="/blog">Newer</a> <a href="/blog/page/3/">Older</a>`},
+		{"blog/page/3/index.html", `<a href="/blog/page/2/">Newer</a>`},
+		{"blog/hello/index.html", `<a href="/search?q=x">Search</a>`},
+		{"blog/bye/index.html", "Bye"},
+		{"rss", "<rss/>"},
+		{"404.html", "Not found"},
+		{"static/css/style.css", "body{}"},
+	}
+	for _, tt := range tests {
+		if got := readFile(t, out, tt.name); !strings.Contains(got, tt.want) {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+
+	// A second export leaves everything alone
+	report, err = e.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (Report{Unchanged: 10}) {
+		t.Errorf("Expected every file unchanged, got %+v", report)
+	}
+
+	// Pages the site no longer has are removed, other files are kept
+	if err := os.WriteFile(filepath.Join(out, "CNAME"), []byte("example.com"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	delete(site.pages, "/blog/bye")
+	site.pages["/"] = `<a href="/blog">All posts</a>`
+	report, err = e.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (Report{Written: 2, Unchanged: 7, Removed: 1}) {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if _, err := os.Stat(filepath.Join(out, "blog", "bye", "index.html")); !os.IsNotExist(err) {
+		t.Error("Expected the removed page to be deleted")
+	}
+	readFile(t, out, "CNAME")
+}
+
+func TestRunFailsOnError(t *testing.T) {
+	e := &Exporter{Handler: newFakeSite(), Out: t.TempDir(), Paths: []string{"/missing"}}
+	if _, err := e.Run(); err == nil || !strings.Contains(err.Error(), "/missing") {
+		t.Errorf("Expected an error for the missing page, got %v", err)
+	}
+}
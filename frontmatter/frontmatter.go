@@ -0,0 +1,254 @@
+package frontmatter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+	"gopkg.in/yaml.v3"
+)
+
+const delimiter = "---"
+
+// FileError is a problem with a single content file.
+// Line is 1-based within the file, or 0 when it doesn't apply to one line.
+type FileError struct {
+	Path string
+	Line int
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %v", e.Path, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// LoadErrors collects the per-file errors from LoadPosts
+type LoadErrors []*FileError
+
+func (e LoadErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return fmt.Sprintf("%d invalid content file(s):\n  %s", len(e), strings.Join(msgs, "\n  "))
+}
+
+// postMeta is the front matter accepted at the top of a post file
+type postMeta struct {
+	ID        string   `yaml:"id"`
+	Title     string   `yaml:"title"`
+	Date      string   `yaml:"date"`
+	Category  string   `yaml:"category"`
+	Summary   string   `yaml:"summary"`
+	Tags      []string `yaml:"tags"`
+	Status    string   `yaml:"status"`
+	PublishAt string   `yaml:"publish_at"`
+	// Series names a multi-part series; series_order is the part number
+	Series      string `yaml:"series"`
+	SeriesOrder int    `yaml:"series_order"`
+}
+
+// dateLayouts are the accepted front matter date formats
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// LoadPosts reads every *.md file in dir and returns the posts sorted by date,
+// newest first. A missing directory yields no posts.
+//
+// Files with invalid front matter are skipped and reported together as
+// LoadErrors, so callers can still use the posts that did parse.
+func LoadPosts(dir string) ([]models.Post, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", dir, err)
+	}
+	sort.Strings(paths)
+
+	var posts []models.Post
+	var loadErrs LoadErrors
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			loadErrs = append(loadErrs, &FileError{Path: path, Err: err})
+			continue
+		}
+
+		post, err := ParsePost(path, data)
+		if err != nil {
+			var fe *FileError
+			if !errors.As(err, &fe) {
+				fe = &FileError{Path: path, Err: err}
+			}
+			loadErrs = append(loadErrs, fe)
+			continue
+		}
+		posts = append(posts, *post)
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].Date.After(posts[j].Date)
+	})
+
+	if len(loadErrs) > 0 {
+		return posts, loadErrs
+	}
+	return posts, nil
+}
+
+// ParsePost parses a markdown file with YAML front matter into a post.
+// The id defaults to the file name without its extension.
+func ParsePost(path string, data []byte) (*models.Post, error) {
+	front, body, frontLine, err := split(data)
+	if err != nil {
+		return nil, &FileError{Path: path, Line: 1, Err: err}
+	}
+
+	var meta postMeta
+	dec := yaml.NewDecoder(bytes.NewReader(front))
+	dec.KnownFields(true)
+	if err := dec.Decode(&meta); err != nil && !errors.Is(err, io.EOF) {
+		line, msg := yamlErrorLine(err, frontLine-1)
+		return nil, &FileError{Path: path, Line: line, Err: errors.New(msg)}
+	}
+
+	var node yaml.Node
+	_ = yaml.Unmarshal(front, &node)
+	lineOf := func(key string) int {
+		if line := keyLine(&node, key); line > 0 {
+			return line + frontLine - 1
+		}
+		return 1
+	}
+
+	if meta.ID == "" {
+		meta.ID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if strings.TrimSpace(meta.Title) == "" {
+		return nil, &FileError{Path: path, Line: lineOf("title"), Err: errors.New("title is required")}
+	}
+
+	var date time.Time
+	if meta.Date != "" {
+		date, err = parseDate(meta.Date)
+		if err != nil {
+			return nil, &FileError{Path: path, Line: lineOf("date"), Err: err}
+		}
+	}
+
+	if meta.Status != "" && !models.ValidPostStatus(meta.Status) {
+		return nil, &FileError{Path: path, Line: lineOf("status"),
+			Err: fmt.Errorf("invalid status %q: use draft, scheduled, published or archived", meta.Status)}
+	}
+
+	var publishAt *time.Time
+	if meta.PublishAt != "" {
+		t, err := parseDate(meta.PublishAt)
+		if err != nil {
+			return nil, &FileError{Path: path, Line: lineOf("publish_at"), Err: err}
+		}
+		publishAt = &t
+	}
+	if meta.Status == models.PostStatusScheduled && publishAt == nil {
+		return nil, &FileError{Path: path, Line: lineOf("status"), Err: errors.New("scheduled posts need publish_at")}
+	}
+
+	return &models.Post{
+		ID:          meta.ID,
+		Title:       meta.Title,
+		Date:        date,
+		Category:    meta.Category,
+		Summary:     meta.Summary,
+		Content:     string(body),
+		Tags:        meta.Tags,
+		Status:      meta.Status,
+		PublishAt:   publishAt,
+		Series:      meta.Series,
+		SeriesOrder: meta.SeriesOrder,
+	}, nil
+}
+
+// split separates the front matter from the body. frontLine is the file line
+// on which the front matter YAML starts.
+func split(data []byte) (front, body []byte, frontLine int, err error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	lines := strings.SplitAfter(string(data), "\n")
+	if len(lines) == 0 || strings.TrimRight(lines[0], " \t\r\n") != delimiter {
+		return nil, nil, 0, fmt.Errorf("missing front matter: file must start with %q", delimiter)
+	}
+
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], " \t\r\n") == delimiter {
+			front = []byte(strings.Join(lines[1:i], ""))
+			body = []byte(strings.TrimLeft(strings.Join(lines[i+1:], ""), "\r\n"))
+			return front, body, 2, nil
+		}
+	}
+	return nil, nil, 0, fmt.Errorf("unterminated front matter: no closing %q", delimiter)
+}
+
+var yamlLineRe = regexp.MustCompile(`line (\d+)`)
+
+// yamlErrorLine shifts the "line N" references in a yaml error by offset so
+// they point into the file, and returns the first one along with the message
+func yamlErrorLine(err error, offset int) (int, string) {
+	first := 0
+	msg := yamlLineRe.ReplaceAllStringFunc(err.Error(), func(m string) string {
+		n, _ := strconv.Atoi(yamlLineRe.FindStringSubmatch(m)[1])
+		if first == 0 {
+			first = n + offset
+		}
+		return fmt.Sprintf("line %d", n+offset)
+	})
+	if first == 0 {
+		return offset + 1, strings.TrimPrefix(msg, "yaml: ")
+	}
+	// The line is reported separately, so drop a leading "yaml: line N: "
+	msg = strings.TrimPrefix(msg, "yaml: ")
+	msg = strings.TrimPrefix(msg, fmt.Sprintf("line %d: ", first))
+	return first, msg
+}
+
+// keyLine returns the 1-based line of a top-level key within the YAML,
+// or 0 when the key is absent
+func keyLine(node *yaml.Node, key string) int {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				return node.Content[i].Line
+			}
+		}
+	}
+	return 0
+}
+
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD or RFC 3339", value)
+}
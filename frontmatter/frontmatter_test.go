@@ -0,0 +1,183 @@
+package frontmatter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const validPost = `---
+id: k8s-backups
+title: Backing up the cluster
+date: 2025-03-04
+category: Cloud Native
+summary: Velero and restic on MicroK8s
+tags: [k8s, backups]
+series: Cluster operations
+series_order: 2
+---
+
+## Why
+
+Because disks die.
+`
+
+func TestParsePost(t *testing.T) {
+	post, err := ParsePost("k8s-backups.md", []byte(validPost))
+	if err != nil {
+		t.Fatalf("ParsePost failed: %v", err)
+	}
+
+	if post.ID != "k8s-backups" || post.Title != "Backing up the cluster" {
+		t.Errorf("Unexpected id/title: %q %q", post.ID, post.Title)
+	}
+	if !post.Date.Equal(time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected date: %v", post.Date)
+	}
+	if post.Category != "Cloud Native" || post.Summary != "Velero and restic on MicroK8s" {
+		t.Errorf("Unexpected category/summary: %q %q", post.Category, post.Summary)
+	}
+	if strings.Join(post.Tags, ",") != "k8s,backups" {
+		t.Errorf("Unexpected tags: %v", post.Tags)
+	}
+	if post.Series != "Cluster operations" || post.SeriesOrder != 2 {
+		t.Errorf("Unexpected series: %q part %d", post.Series, post.SeriesOrder)
+	}
+	if post.Content != "## Why\n\nBecause disks die.\n" {
+		t.Errorf("Unexpected content: %q", post.Content)
+	}
+}
+
+func TestParsePostDefaults(t *testing.T) {
+	post, err := ParsePost("content/posts/hello-world.md", []byte("---\ntitle: Hello\ndate: 2025-01-02T10:00:00Z\n---\nBody\n"))
+	if err != nil {
+		t.Fatalf("ParsePost failed: %v", err)
+	}
+	if post.ID != "hello-world" {
+		t.Errorf("Expected id from filename, got %q", post.ID)
+	}
+	if post.Date.Hour() != 10 {
+		t.Errorf("Expected RFC 3339 date to keep its time, got %v", post.Date)
+	}
+}
+
+func TestParsePostErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantLine int
+		wantMsg  string
+	}{
+		{
+			name:     "no front matter",
+			input:    "# Just markdown\n",
+			wantLine: 1,
+			wantMsg:  "missing front matter",
+		},
+		{
+			name:     "unterminated",
+			input:    "---\ntitle: Oops\n\nBody\n",
+			wantLine: 1,
+			wantMsg:  "unterminated front matter",
+		},
+		{
+			name:     "yaml syntax error",
+			input:    "---\ntitle: Fine\nsummary: \"unclosed\ntags: [a]\n---\nBody\n",
+			wantLine: 3,
+			wantMsg:  "found unexpected end of stream",
+		},
+		{
+			name:     "unknown field",
+			input:    "---\ntitle: Fine\ncategroy: Go\n---\nBody\n",
+			wantLine: 3,
+			wantMsg:  "field categroy not found",
+		},
+		{
+			name:     "wrong type",
+			input:    "---\ntitle: Fine\ntags:\n  nested: map\n---\nBody\n",
+			wantLine: 4,
+			wantMsg:  "cannot unmarshal",
+		},
+		{
+			name:     "missing title",
+			input:    "---\nid: untitled\n---\nBody\n",
+			wantLine: 1,
+			wantMsg:  "title is required",
+		},
+		{
+			name:     "bad date",
+			input:    "---\ntitle: Fine\n\ndate: next tuesday\n---\nBody\n",
+			wantLine: 4,
+			wantMsg:  "invalid date",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePost("post.md", []byte(tt.input))
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+
+			var fe *FileError
+			if !errors.As(err, &fe) {
+				t.Fatalf("Expected *FileError, got %T: %v", err, err)
+			}
+			if fe.Line != tt.wantLine {
+				t.Errorf("Expected line %d, got %d (%v)", tt.wantLine, fe.Line, err)
+			}
+			if !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("Expected error containing %q, got %q", tt.wantMsg, err.Error())
+			}
+			if !strings.HasPrefix(err.Error(), "post.md:") {
+				t.Errorf("Expected error prefixed with the file name, got %q", err.Error())
+			}
+		})
+	}
+}
+
+func TestLoadPosts(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"older.md":    "---\ntitle: Older\ndate: 2024-01-01\n---\nOld\n",
+		"newer.md":    "---\ntitle: Newer\ndate: 2025-01-01\n---\nNew\n",
+		"broken.md":   "---\ntitle: [unclosed\n---\n",
+		"untitled.md": "---\ndate: 2025-01-01\n---\n",
+		"notes.txt":   "not a post",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	posts, err := LoadPosts(dir)
+
+	if len(posts) != 2 || posts[0].ID != "newer" || posts[1].ID != "older" {
+		t.Errorf("Expected valid posts newest first, got %+v", posts)
+	}
+
+	var loadErrs LoadErrors
+	if !errors.As(err, &loadErrs) {
+		t.Fatalf("Expected LoadErrors, got %T: %v", err, err)
+	}
+	if len(loadErrs) != 2 {
+		t.Fatalf("Expected 2 file errors, got %d: %v", len(loadErrs), err)
+	}
+	if filepath.Base(loadErrs[0].Path) != "broken.md" || filepath.Base(loadErrs[1].Path) != "untitled.md" {
+		t.Errorf("Unexpected error files: %v", err)
+	}
+}
+
+func TestLoadPostsMissingDir(t *testing.T) {
+	posts, err := LoadPosts(filepath.Join(t.TempDir(), "does-not-exist"))
+	if err != nil {
+		t.Errorf("Expected no error for a missing directory, got %v", err)
+	}
+	if len(posts) != 0 {
+		t.Errorf("Expected no posts, got %d", len(posts))
+	}
+}
@@ -6,9 +6,20 @@ require (
 	github.com/gorilla/feeds v1.2.0
 	github.com/gorilla/mux v1.8.1
 	github.com/mattn/go-sqlite3 v1.14.24
+	github.com/yuin/goldmark v1.7.8
 	golang.org/x/crypto v0.31.0
 	golang.org/x/time v0.8.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
-require github.com/joho/godotenv v1.5.1
+require (
+	github.com/alecthomas/chroma/v2 v2.14.0
+	github.com/joho/godotenv v1.5.1
+	golang.org/x/image v0.18.0
+	rsc.io/qr v0.2.0
+)
+
+require (
+	github.com/dlclark/regexp2 v1.11.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
@@ -2,15 +2,30 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/db"
 	"github.com/tinotenda-alfaneti/homelabsite/models"
 )
 
 const htmxRequestHeader = "true"
 
+// uptimeWindow is how far back the uptime shown with each service reaches
+const uptimeWindow = 24 * time.Hour
+
+// attachHealth adds live health to services. A failure only loses the
+// health badges, so it is logged rather than failing the request.
+func (app *App) attachHealth(services []models.Service) {
+	if err := app.DB.AttachHealth(services, time.Now().Add(-uptimeWindow)); err != nil {
+		log.Printf("Error loading service health: %v", err)
+	}
+}
+
 func (app *App) HandleAPIServices(w http.ResponseWriter, r *http.Request) {
 	status := r.URL.Query().Get("status")
 
@@ -31,6 +46,7 @@ func (app *App) HandleAPIServices(w http.ResponseWriter, r *http.Request) {
 		}
 		services = filtered
 	}
+	app.attachHealth(services)
 
 	// Check if HTMX request
 	if r.Header.Get("HX-Request") == htmxRequestHeader {
@@ -52,16 +68,135 @@ func (app *App) HandleAPIServices(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (app *App) HandleAPIPosts(w http.ResponseWriter, _ *http.Request) {
-	posts, err := app.DB.GetAllPosts()
+func (app *App) HandleAPICreateService(w http.ResponseWriter, r *http.Request) {
+	service, ok := decodeService(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.DB.CreateService(service); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"service": service,
+	}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+func (app *App) HandleAPIUpdateService(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid service id", http.StatusBadRequest)
+		return
+	}
+
+	service, ok := decodeService(w, r)
+	if !ok {
+		return
+	}
+	// The URL decides which row is updated, whatever id the body carries
+	service.ID = id
+
+	if err := app.DB.UpdateService(service); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"service": service,
+	}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+func (app *App) HandleAPIDeleteService(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid service id", http.StatusBadRequest)
+		return
+	}
+
+	if err := app.DB.DeleteService(id); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// decodeService reads and validates a service from the request body,
+// writing a 400 response and returning false if it is invalid
+func decodeService(w http.ResponseWriter, r *http.Request) (*models.Service, bool) {
+	var service models.Service
+	if err := json.NewDecoder(r.Body).Decode(&service); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if !models.ValidServiceStatus(service.Status) {
+		http.Error(w, "Invalid status: use public, internal or development", http.StatusBadRequest)
+		return nil, false
+	}
+	return &service, true
+}
+
+// writeServiceError maps errors from the service store to HTTP responses
+func writeServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, db.ErrServiceNotFound):
+		http.Error(w, "Service not found", http.StatusNotFound)
+	case errors.Is(err, db.ErrDuplicateServiceName):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, db.ErrServiceNameRequired):
+		http.Error(w, "Service name is required", http.StatusBadRequest)
+	default:
+		log.Printf("Error saving service: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// HandleAPIPosts lists published posts a page at a time, newest first.
+// It takes ?limit=, ?cursor= and ?fields=; the next page is in the Link header.
+func (app *App) HandleAPIPosts(w http.ResponseWriter, r *http.Request) {
+	page, fields, err := listParams(r, postFields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	posts, next, err := app.DB.ListPosts("", page)
+	if errors.Is(err, db.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		log.Printf("Error getting posts from database: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
+	body, err := selectFields(posts, fields)
+	if err != nil {
+		log.Printf("Error selecting post fields: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	setNextLink(w, r, next)
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(posts); err != nil {
+	if err := json.NewEncoder(w).Encode(body); err != nil {
 		log.Printf("Error encoding posts to JSON: %v", err)
 	}
 }
@@ -77,7 +212,8 @@ func (app *App) HandleAPIGetPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if post == nil {
+	// Drafts and scheduled posts are only visible to admins
+	if post == nil || (!post.IsPublished(time.Now()) && !app.Auth.IsAuthenticated(r)) {
 		http.NotFound(w, r)
 		return
 	}
@@ -95,8 +231,21 @@ func (app *App) HandleAPISavePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Save to database
-	if err := app.DB.SavePost(&post); err != nil {
+	// Posts saved without a status stay hidden until they are published
+	if post.Status == "" {
+		post.Status = models.PostStatusDraft
+	}
+	if !models.ValidPostStatus(post.Status) {
+		http.Error(w, "Invalid status: use draft, scheduled, published or archived", http.StatusBadRequest)
+		return
+	}
+	if post.Status == models.PostStatusScheduled && post.PublishAt == nil {
+		http.Error(w, "Scheduled posts need a publish_at time", http.StatusBadRequest)
+		return
+	}
+
+	// Save to database, recording a revision attributed to the signed-in admin
+	if err := app.DB.SavePostAs(&post, app.Auth.Username(r)); err != nil {
 		log.Printf("Error saving post: %v", err)
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(map[string]interface{}{
@@ -142,8 +291,17 @@ func (app *App) HandleAPIDeletePost(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (app *App) HandleAPIPopularPosts(w http.ResponseWriter, _ *http.Request) {
+func (app *App) HandleAPIPopularPosts(w http.ResponseWriter, r *http.Request) {
+	page, fields, err := listParams(r, postFields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	limit := 5 // Default to 5 popular posts
+	if page.Limit > 0 {
+		limit = Min(page.Limit, db.MaxPageSize)
+	}
 
 	posts, err := app.DB.GetPopularPosts(limit)
 	if err != nil {
@@ -152,8 +310,15 @@ func (app *App) HandleAPIPopularPosts(w http.ResponseWriter, _ *http.Request) {
 		return
 	}
 
+	body, err := selectFields(posts, fields)
+	if err != nil {
+		log.Printf("Error selecting post fields: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(posts); err != nil {
+	if err := json.NewEncoder(w).Encode(body); err != nil {
 		log.Printf("Error encoding popular posts to JSON: %v", err)
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"testing"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/tinotenda-alfaneti/homelabsite/db"
 	"github.com/tinotenda-alfaneti/homelabsite/middleware"
 	"github.com/tinotenda-alfaneti/homelabsite/models"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func setupTestApp(t *testing.T) *App {
@@ -53,13 +55,36 @@ func setupTestApp(t *testing.T) *App {
 		t.Fatalf("Failed to save test service: %v", err)
 	}
 
+	// The lowest bcrypt cost keeps signing in fast
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.BootstrapAdmin("admin", string(hash)); err != nil {
+		t.Fatalf("Failed to create admin user: %v", err)
+	}
+
 	return &App{
 		DB:    database,
-		Auth:  middleware.NewAuthMiddleware("admin", "password"),
+		Auth:  middleware.NewAuthMiddleware(database, database, database),
 		Cache: cache.New(),
 	}
 }
 
+// sessionCookie signs in as the named user
+func sessionCookie(t *testing.T, app *App, username string) *http.Cookie {
+	t.Helper()
+	user, err := app.DB.GetUserByUsername(username)
+	if err != nil || user == nil {
+		t.Fatalf("No user %s: %v", username, err)
+	}
+	token, _, err := app.Auth.CreateSession(httptest.NewRequest("POST", "/admin/login", nil), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Cookie{Name: "session_token", Value: token}
+}
+
 func teardownTestApp(app *App) {
 	if app.DB != nil {
 		app.DB.Close()
@@ -348,3 +373,167 @@ func TestHandleHealth(t *testing.T) {
 		t.Errorf("Handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
 	}
 }
+
+func TestHandleAPISavePostDefaultsToDraft(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	body, _ := json.Marshal(models.Post{ID: "unsaved-status", Title: "No Status", Date: time.Now()})
+	req := httptest.NewRequest("POST", "/api/posts", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleAPISavePost).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	saved, _ := app.DB.GetPostByID("unsaved-status")
+	if saved == nil || saved.Status != models.PostStatusDraft {
+		t.Fatalf("Expected post saved as draft, got %+v", saved)
+	}
+
+	// The public JSON API hides drafts from anonymous readers
+	req = httptest.NewRequest("GET", "/api/posts/unsaved-status", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "unsaved-status"})
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(app.HandleAPIGetPost).ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for anonymous draft request, got %d", rr.Code)
+	}
+}
+
+func TestHandleAPISavePostRejectsInvalidStatus(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	tests := []struct {
+		name string
+		post models.Post
+	}{
+		{"unknown status", models.Post{ID: "bad-status", Title: "Bad", Status: "live"}},
+		{"scheduled without publish_at", models.Post{ID: "bad-schedule", Title: "Bad", Status: models.PostStatusScheduled}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.post)
+			req := httptest.NewRequest("POST", "/api/posts", bytes.NewBuffer(body))
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(app.HandleAPISavePost).ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400, got %d", rr.Code)
+			}
+		})
+	}
+}
+
+func TestHandleAPIServiceCRUD(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	// Create
+	body := `{"name": "Grafana", "url": "https://grafana.example.com", "status": "internal"}`
+	req := httptest.NewRequest("POST", "/api/services", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleAPICreateService).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Create returned %d: %s", rr.Code, rr.Body.String())
+	}
+	var created struct {
+		Service models.Service `json:"service"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	id := created.Service.ID
+	if id == 0 {
+		t.Fatal("Expected the created service to have an ID")
+	}
+
+	// Rename
+	body = `{"name": "Grafana Dashboards", "status": "public"}`
+	req = httptest.NewRequest("PUT", "/api/services/1", bytes.NewBufferString(body))
+	req = mux.SetURLVars(req, map[string]string{"id": strconv.FormatInt(id, 10)})
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(app.HandleAPIUpdateService).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Update returned %d: %s", rr.Code, rr.Body.String())
+	}
+	services, _ := app.DB.GetAllServices()
+	if len(services) != 2 {
+		t.Errorf("Expected the rename to keep 2 services, got %d", len(services))
+	}
+
+	// Delete
+	req = httptest.NewRequest("DELETE", "/api/services/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": strconv.FormatInt(id, 10)})
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(app.HandleAPIDeleteService).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Delete returned %d: %s", rr.Code, rr.Body.String())
+	}
+	if s, _ := app.DB.GetServiceByID(id); s != nil {
+		t.Error("Expected service to be deleted")
+	}
+}
+
+func TestHandleAPIServiceErrors(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		id      string
+		body    string
+		want    int
+	}{
+		{"invalid status", app.HandleAPICreateService, "", `{"name": "X", "status": "live"}`, http.StatusBadRequest},
+		{"missing name", app.HandleAPICreateService, "", `{"status": "public"}`, http.StatusBadRequest},
+		{"duplicate name", app.HandleAPICreateService, "", `{"name": "Test Service", "status": "public"}`, http.StatusConflict},
+		{"update missing", app.HandleAPIUpdateService, "999", `{"name": "X", "status": "public"}`, http.StatusNotFound},
+		{"delete missing", app.HandleAPIDeleteService, "999", ``, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/services", bytes.NewBufferString(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleAPIServicesIncludesHealth(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	services, _ := app.DB.GetAllServices()
+	if err := app.DB.RecordCheck(models.CheckResult{ServiceID: services[0].ID, CheckedAt: time.Now(), Up: true, LatencyMS: 12}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/services", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleAPIServices).ServeHTTP(rr, req)
+
+	var got []models.Service
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Health == nil {
+		t.Fatalf("Expected a service with health, got %+v", got)
+	}
+	if h := got[0].Health; h.State != models.HealthUp || h.LatencyMS != 12 || h.Uptime != 100 {
+		t.Errorf("Unexpected health: %+v", h)
+	}
+}
@@ -1,16 +1,33 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"html/template"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/tinotenda-alfaneti/homelabsite/cache"
 	"github.com/tinotenda-alfaneti/homelabsite/db"
+	"github.com/tinotenda-alfaneti/homelabsite/markdown"
 	"github.com/tinotenda-alfaneti/homelabsite/middleware"
 	"github.com/tinotenda-alfaneti/homelabsite/models"
+	"github.com/tinotenda-alfaneti/homelabsite/ogimage"
 )
 
+// TemplateFuncs are the functions available to the page templates
+var TemplateFuncs = template.FuncMap{
+	"markdown": markdown.Render,
+	"add": func(a, b int) int {
+		return a + b
+	},
+	"tagURL":      tagURL,
+	"categoryURL": categoryURL,
+}
+
 type App struct {
 	Config     *models.Config
 	Templates  *template.Template
@@ -18,9 +35,25 @@ type App struct {
 	ConfigPath string
 	DB         *db.DB
 	Cache      *cache.Cache
+	OGImages   *ogimage.Cache
+	// Static is set while rendering pages for a static export, which has no
+	// server behind it for comments, filters or view counts
+	Static bool
+	// Now is the clock two-factor codes are checked against; nil means time.Now
+	Now func() time.Time
+}
+
+func (app *App) now() time.Time {
+	if app.Now != nil {
+		return app.Now()
+	}
+	return time.Now()
 }
 
 func (app *App) Render(w http.ResponseWriter, tmpl string, data map[string]interface{}) {
+	if app.Static && data != nil {
+		data["Static"] = true
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := app.Templates.ExecuteTemplate(w, tmpl, data); err != nil {
 		log.Printf("Error rendering template %s: %v", tmpl, err)
@@ -28,6 +61,44 @@ func (app *App) Render(w http.ResponseWriter, tmpl string, data map[string]inter
 	}
 }
 
+// site returns the site settings from config.yaml, with the title falling
+// back to the app name
+func (app *App) site() models.SiteConfig {
+	var site models.SiteConfig
+	if app.Config != nil && app.Config.AppConfig != nil {
+		site = app.Config.AppConfig.Site
+		if site.Title == "" {
+			site.Title = app.Config.AppConfig.App.Name
+		}
+	}
+	if site.Title == "" {
+		site.Title = "Atarnet Homelab"
+	}
+	return site
+}
+
+// baseURL is the public URL of the site without a trailing slash: site.base_url
+// from config.yaml, or the scheme and host the request came in on
+func (app *App) baseURL(r *http.Request) string {
+	if base := strings.TrimRight(app.site().BaseURL, "/"); base != "" {
+		return base
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// serveGenerated writes a generated document with an ETag of its content and
+// the given Last-Modified, answering conditional GETs with 304 Not Modified
+func serveGenerated(w http.ResponseWriter, r *http.Request, contentType string, modified time.Time, body []byte) {
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(w, r, "", modified, bytes.NewReader(body))
+}
+
 func Min(a, b int) int {
 	if a < b {
 		return a
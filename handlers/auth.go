@@ -1,39 +1,58 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
 	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/middleware"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
 )
 
-func (app *App) HandleLoginPage(w http.ResponseWriter, _ *http.Request) {
-	data := map[string]interface{}{
-		"Title": "Admin Login - Atarnet Homelab",
-		"Error": nil,
-	}
-	app.Render(w, "login.html", data)
+func (app *App) HandleLoginPage(w http.ResponseWriter, r *http.Request) {
+	app.renderLogin(w, r, "", false)
 }
 
 func (app *App) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	username := r.FormValue("username")
 	password := r.FormValue("password")
 
-	if !app.Auth.ValidateCredentials(username, password) {
-		data := map[string]interface{}{
-			"Title": "Admin Login - Atarnet Homelab",
-			"Error": "Invalid username or password",
-		}
-		app.Render(w, "login.html", data)
+	user := app.Auth.ValidateCredentials(username, password)
+	if user == nil {
+		app.renderLogin(w, r, "Invalid username or password", false)
 		return
 	}
 
-	// Create session
-	sessionToken, expiry := app.Auth.CreateSession()
+	app.requireSecondFactor(w, r, user)
+}
+
+// renderLogin shows the login page with an optional error, asking for the
+// two-factor code instead of the password when codeStep is set
+func (app *App) renderLogin(w http.ResponseWriter, r *http.Request, message string, codeStep bool) {
+	app.Render(w, "login.html", map[string]interface{}{
+		"Title":     "Admin Login - Atarnet Homelab",
+		"Error":     message,
+		"CodeStep":  codeStep,
+		"CSRFToken": middleware.CSRFToken(r),
+	})
+}
+
+// startSession signs the user in and sends them to the admin
+func (app *App) startSession(w http.ResponseWriter, r *http.Request, user *models.User) {
+	sessionToken, expiry, err := app.Auth.CreateSession(r, user.ID)
+	if err != nil {
+		log.Printf("Error creating session: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
 
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session_token",
 		Value:    sessionToken,
 		Expires:  expiry,
 		HttpOnly: true,
+		Secure:   middleware.SecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
 		Path:     "/",
 	})
 
@@ -51,6 +70,8 @@ func (app *App) HandleLogout(w http.ResponseWriter, r *http.Request) {
 		Value:    "",
 		Expires:  time.Unix(0, 0),
 		HttpOnly: true,
+		Secure:   middleware.SecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
 		Path:     "/",
 	})
 
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/tinotenda-alfaneti/homelabsite/middleware"
+)
+
+func TestLoginCSRF(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+	app.Templates = template.Must(template.New("").Funcs(TemplateFuncs).ParseGlob("../web/templates/*.html"))
+
+	page := middleware.CSRF(http.HandlerFunc(app.HandleLoginPage))
+	login := middleware.CSRF(http.HandlerFunc(app.HandleLogin))
+
+	rr := httptest.NewRecorder()
+	page.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/login", nil))
+	var csrf *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == middleware.CSRFCookie {
+			csrf = c
+		}
+	}
+	if csrf == nil {
+		t.Fatal("Expected the login page to set the CSRF cookie")
+	}
+	if !strings.Contains(rr.Body.String(), `name="csrf_token" value="`+csrf.Value+`"`) {
+		t.Error("Expected the login form to carry the CSRF token")
+	}
+
+	signIn := func(token string) *httptest.ResponseRecorder {
+		form := url.Values{"username": {"admin"}, "password": {"password"}}
+		if token != "" {
+			form.Set(middleware.CSRFField, token)
+		}
+		req := httptest.NewRequest("POST", "/admin/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.AddCookie(csrf)
+		rr := httptest.NewRecorder()
+		login.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// A login form posted from another site can't know the token
+	if rr := signIn(""); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected a forged login to get 403, got %d", rr.Code)
+	}
+
+	rr = signIn(csrf.Value)
+	if rr.Code != http.StatusFound {
+		t.Fatalf("Expected 302, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var session *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == "session_token" {
+			session = c
+		}
+	}
+	if session == nil || !session.HttpOnly || !session.Secure || session.SameSite != http.SameSiteLaxMode {
+		t.Errorf("Expected a hardened session cookie, got %+v", session)
+	}
+}
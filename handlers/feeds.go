@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/feeds"
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/db"
+	"github.com/tinotenda-alfaneti/homelabsite/markdown"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// feedSize is how many of the newest posts a feed carries
+const feedSize = 20
+
+// Feed formats
+const (
+	feedRSS  = "rss"
+	feedAtom = "atom"
+	feedJSON = "json"
+)
+
+// feedFiles maps the file names of tag and category feeds to their format
+var feedFiles = map[string]string{
+	"feed.xml":  feedRSS,
+	"atom.xml":  feedAtom,
+	"feed.json": feedJSON,
+}
+
+var feedContentTypes = map[string]string{
+	feedRSS:  "application/rss+xml; charset=utf-8",
+	feedAtom: "application/atom+xml; charset=utf-8",
+	feedJSON: "application/feed+json; charset=utf-8",
+}
+
+// feedSource is what a feed lists and the page it mirrors
+type feedSource struct {
+	Title       string
+	Description string
+	// Path is the HTML page of the feed, e.g. /blog or /tags/k8s
+	Path  string
+	Posts []models.Post
+}
+
+// HandleRSS serves the blog feed as RSS, or as Atom when the client asks for it
+func (app *App) HandleRSS(w http.ResponseWriter, r *http.Request) {
+	format := feedRSS
+	if strings.Contains(r.Header.Get("Accept"), "application/atom+xml") {
+		format = feedAtom
+	}
+	app.serveBlogFeed(w, r, format)
+}
+
+// HandleAtom serves the blog feed as Atom
+func (app *App) HandleAtom(w http.ResponseWriter, r *http.Request) {
+	app.serveBlogFeed(w, r, feedAtom)
+}
+
+// HandleJSONFeed serves the blog feed as JSON Feed 1.1
+func (app *App) HandleJSONFeed(w http.ResponseWriter, r *http.Request) {
+	app.serveBlogFeed(w, r, feedJSON)
+}
+
+func (app *App) serveBlogFeed(w http.ResponseWriter, r *http.Request, format string) {
+	posts, _, err := app.DB.ListPosts("", db.PageOptions{Limit: feedSize, Content: true})
+	if err != nil {
+		log.Printf("Error getting posts for feed: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	site := app.site()
+	app.serveFeed(w, r, format, feedSource{
+		Title:       site.Title,
+		Description: site.Description,
+		Path:        "/blog",
+		Posts:       posts,
+	})
+}
+
+// HandleTagFeed serves /tags/{tag}/feed.xml, atom.xml and feed.json
+func (app *App) HandleTagFeed(w http.ResponseWriter, r *http.Request) {
+	tag, err := app.DB.GetTag(mux.Vars(r)["tag"])
+	if err != nil {
+		log.Printf("Error getting tag: %v", err)
+	}
+	if tag == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	posts, _, err := app.DB.ListPostsByTag(tag.Name, db.PageOptions{Limit: feedSize, Content: true})
+	if err != nil {
+		log.Printf("Error getting posts for feed: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	app.serveFeed(w, r, feedFiles[mux.Vars(r)["file"]], feedSource{
+		Title:       app.site().Title + " - #" + tag.Name,
+		Description: "Posts tagged " + tag.Name,
+		Path:        tagURL(tag.Name),
+		Posts:       posts,
+	})
+}
+
+// HandleCategoryFeed serves /category/{name}/feed.xml, atom.xml and feed.json
+func (app *App) HandleCategoryFeed(w http.ResponseWriter, r *http.Request) {
+	category, err := app.DB.GetCategory(mux.Vars(r)["name"])
+	if err != nil {
+		log.Printf("Error getting category: %v", err)
+	}
+	if category == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	posts, _, err := app.DB.ListPosts(category.Name, db.PageOptions{Limit: feedSize, Content: true})
+	if err != nil {
+		log.Printf("Error getting posts for feed: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	app.serveFeed(w, r, feedFiles[mux.Vars(r)["file"]], feedSource{
+		Title:       app.site().Title + " - " + category.Name,
+		Description: "Posts filed under " + category.Name,
+		Path:        categoryURL(category.Name),
+		Posts:       posts,
+	})
+}
+
+// serveFeed renders a feed and serves it with serveGenerated. The output only
+// depends on the posts, so unchanged feeds keep their ETag.
+func (app *App) serveFeed(w http.ResponseWriter, r *http.Request, format string, src feedSource) {
+	base := app.baseURL(r)
+	modified := lastModified(src.Posts)
+
+	var body []byte
+	var err error
+	if format == feedJSON {
+		body, err = json.Marshal(app.jsonFeed(base, r.URL.Path, modified, src))
+	} else {
+		body, err = app.xmlFeed(base, format, modified, src)
+	}
+	if err != nil {
+		log.Printf("Error generating %s feed: %v", format, err)
+		http.Error(w, "Error generating feed", http.StatusInternalServerError)
+		return
+	}
+
+	serveGenerated(w, r, feedContentTypes[format], modified, body)
+}
+
+func (app *App) xmlFeed(base, format string, modified time.Time, src feedSource) ([]byte, error) {
+	site := app.site()
+	var author *feeds.Author
+	if site.Author.Name != "" {
+		author = &feeds.Author{Name: site.Author.Name, Email: site.Author.Email}
+	}
+
+	feed := &feeds.Feed{
+		Title:       src.Title,
+		Link:        &feeds.Link{Href: base + src.Path},
+		Description: src.Description,
+		Author:      author,
+		Created:     modified,
+		Updated:     modified,
+		Items:       make([]*feeds.Item, 0, len(src.Posts)),
+	}
+	for _, post := range src.Posts {
+		link := base + "/blog/" + post.ID
+		item := &feeds.Item{
+			Id:          link,
+			Title:       post.Title,
+			Link:        &feeds.Link{Href: link},
+			Description: post.Summary,
+			Author:      author,
+			Created:     published(post),
+			Content:     string(markdown.Render(post.Content)),
+		}
+		if post.UpdatedAt != nil {
+			item.Updated = *post.UpdatedAt
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	var out string
+	var err error
+	if format == feedAtom {
+		out, err = feed.ToAtom()
+	} else {
+		out, err = feed.ToRss()
+	}
+	return []byte(out), err
+}
+
+// JSON Feed 1.1, https://www.jsonfeed.org/version/1.1/
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Description string           `json:"description,omitempty"`
+	Authors     []jsonFeedAuthor `json:"authors,omitempty"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type jsonFeedItem struct {
+	ID            string     `json:"id"`
+	URL           string     `json:"url"`
+	Title         string     `json:"title"`
+	ContentHTML   string     `json:"content_html"`
+	Summary       string     `json:"summary,omitempty"`
+	DatePublished time.Time  `json:"date_published"`
+	DateModified  *time.Time `json:"date_modified,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+}
+
+func (app *App) jsonFeed(base, path string, modified time.Time, src feedSource) jsonFeed {
+	site := app.site()
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       src.Title,
+		HomePageURL: base + src.Path,
+		FeedURL:     base + path,
+		Description: src.Description,
+		Items:       make([]jsonFeedItem, 0, len(src.Posts)),
+	}
+	if site.Author.Name != "" {
+		feed.Authors = []jsonFeedAuthor{{Name: site.Author.Name, URL: base + "/about"}}
+	}
+	for _, post := range src.Posts {
+		link := base + "/blog/" + post.ID
+		feed.Items = append(feed.Items, jsonFeedItem{
+			ID:            link,
+			URL:           link,
+			Title:         post.Title,
+			ContentHTML:   string(markdown.Render(post.Content)),
+			Summary:       post.Summary,
+			DatePublished: published(post).UTC(),
+			DateModified:  post.UpdatedAt,
+			Tags:          post.Tags,
+		})
+	}
+	return feed
+}
+
+// published is when a post went live: its publish_at if later than its date
+func published(post models.Post) time.Time {
+	if post.PublishAt != nil && post.PublishAt.After(post.Date) {
+		return *post.PublishAt
+	}
+	return post.Date
+}
+
+// lastModified is the newest publish or edit time of the posts, zero for none
+func lastModified(posts []models.Post) time.Time {
+	var latest time.Time
+	for _, post := range posts {
+		if t := published(post); t.After(latest) {
+			latest = t
+		}
+		if post.UpdatedAt != nil && post.UpdatedAt.After(latest) {
+			latest = *post.UpdatedAt
+		}
+	}
+	// A post dated in the future must not push Last-Modified past now
+	if now := time.Now(); latest.After(now) {
+		return now
+	}
+	return latest
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestFeeds(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	post, _ := app.DB.GetPostByID("test-post-1")
+	post.Content = "Some **bold** text"
+	if err := app.DB.SavePost(post); err != nil {
+		t.Fatal(err)
+	}
+	app.Config = &models.Config{AppConfig: &models.AppConfig{}}
+	app.Config.AppConfig.Site.BaseURL = "https://blog.example.com/"
+	app.Config.AppConfig.Site.Title = "Example Blog"
+	app.Config.AppConfig.Site.Author.Name = "Jane Doe"
+
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		vars        map[string]string
+		accept      string
+		contentType string
+		want        []string
+	}{
+		{"rss", app.HandleRSS, nil, "", "application/rss+xml", []string{"<rss", "<title>Example Blog</title>", "Jane Doe", "<strong>bold</strong>"}},
+		{"rss accepting atom", app.HandleRSS, nil, "application/atom+xml", "application/atom+xml", []string{"<feed", "&lt;strong&gt;bold"}},
+		{"atom", app.HandleAtom, nil, "", "application/atom+xml", []string{"<feed", "<id>https://blog.example.com/blog/test-post-1</id>", "&lt;strong&gt;bold"}},
+		{"json", app.HandleJSONFeed, nil, "", "application/feed+json", []string{`"version":"https://jsonfeed.org/version/1.1"`, `"name":"Jane Doe"`, `\u003cstrong\u003ebold`}},
+		{"tag", app.HandleTagFeed, map[string]string{"tag": "GO", "file": "feed.xml"}, "", "application/rss+xml", []string{"Example Blog - #go", "https://blog.example.com/tags/go", "<strong>bold</strong>"}},
+		{"category", app.HandleCategoryFeed, map[string]string{"name": "testing", "file": "atom.xml"}, "", "application/atom+xml", []string{"Example Blog - Testing", "&lt;strong&gt;bold"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest("GET", "/feed", nil), tt.vars)
+			req.Header.Set("Accept", tt.accept)
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d", rr.Code)
+			}
+			if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+				t.Errorf("Expected %s, got %s", tt.contentType, ct)
+			}
+			body := rr.Body.String()
+			for _, want := range append(tt.want, "https://blog.example.com/blog/test-post-1") {
+				if !strings.Contains(body, want) {
+					t.Errorf("Expected %q in the feed:\n%s", want, body)
+				}
+			}
+			if strings.Contains(body, "**bold**") {
+				t.Error("Expected rendered HTML, not markdown")
+			}
+		})
+	}
+}
+
+func TestJSONFeedItems(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	req := httptest.NewRequest("GET", "http://localhost:8082/feed.json", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleJSONFeed).ServeHTTP(rr, req)
+
+	var feed jsonFeed
+	if err := json.NewDecoder(rr.Body).Decode(&feed); err != nil {
+		t.Fatal(err)
+	}
+	if feed.FeedURL != "http://localhost:8082/feed.json" || feed.HomePageURL != "http://localhost:8082/blog" {
+		t.Errorf("Expected links from the request host, got %q %q", feed.FeedURL, feed.HomePageURL)
+	}
+	if len(feed.Items) != 1 {
+		t.Fatalf("Expected 1 item, got %d", len(feed.Items))
+	}
+	item := feed.Items[0]
+	if item.ID != "http://localhost:8082/blog/test-post-1" || item.ContentHTML != "<p>Test post content</p>\n" {
+		t.Errorf("Unexpected item %+v", item)
+	}
+	if strings.Join(item.Tags, ",") != "test,go" || item.DatePublished.IsZero() {
+		t.Errorf("Expected tags and a publish date, got %+v", item)
+	}
+}
+
+func TestFeedConditionalGet(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleRSS).ServeHTTP(rr, httptest.NewRequest("GET", "/rss", nil))
+	etag := rr.Header().Get("ETag")
+	modified := rr.Header().Get("Last-Modified")
+	if etag == "" || modified == "" {
+		t.Fatalf("Expected ETag and Last-Modified, got %q %q", etag, modified)
+	}
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		wantCode int
+	}{
+		{"matching etag", "If-None-Match", etag, http.StatusNotModified},
+		{"stale etag", "If-None-Match", `"stale"`, http.StatusOK},
+		{"not modified since", "If-Modified-Since", modified, http.StatusNotModified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/rss", nil)
+			req.Header.Set(tt.header, tt.value)
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(app.HandleRSS).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Errorf("Expected %d, got %d", tt.wantCode, rr.Code)
+			}
+		})
+	}
+}
+
+func TestFeedUnknownTagOrCategory(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	for _, tt := range []struct {
+		handler http.HandlerFunc
+		vars    map[string]string
+	}{
+		{app.HandleTagFeed, map[string]string{"tag": "rust", "file": "feed.xml"}},
+		{app.HandleCategoryFeed, map[string]string{"name": "Cooking", "file": "feed.json"}},
+	} {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), tt.vars)
+		rr := httptest.NewRecorder()
+		tt.handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for %v, got %d", tt.vars, rr.Code)
+		}
+	}
+}
@@ -1,27 +1,34 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/db"
+	"github.com/tinotenda-alfaneti/homelabsite/markdown"
+	"github.com/tinotenda-alfaneti/homelabsite/middleware"
 	"github.com/tinotenda-alfaneti/homelabsite/models"
 )
 
-func (app *App) HandleHome(w http.ResponseWriter, _ *http.Request) {
+func (app *App) HandleHome(w http.ResponseWriter, r *http.Request) {
 	services, _ := app.DB.GetAllServices()
-	posts, _ := app.DB.GetAllPosts()
+	posts, _, _ := app.DB.ListPosts("", db.PageOptions{Limit: 3})
 
 	data := map[string]interface{}{
 		"Title":    "Atarnet Homelab - K8s Infrastructure at Home",
 		"Services": services[:Min(4, len(services))],
-		"Posts":    posts[:Min(3, len(posts))],
+		"Posts":    posts,
+		"SEO":      app.pageSEO(r, app.site().Title, ""),
 	}
 	app.Render(w, "home.html", data)
 }
 
-func (app *App) HandleServices(w http.ResponseWriter, _ *http.Request) {
+func (app *App) HandleServices(w http.ResponseWriter, r *http.Request) {
 	services, _ := app.DB.GetAllServices()
+	app.attachHealth(services)
 
 	// Build breadcrumbs
 	breadcrumbs := []models.Breadcrumb{
@@ -33,22 +40,25 @@ func (app *App) HandleServices(w http.ResponseWriter, _ *http.Request) {
 		"Title":       "Services - Atarnet Homelab",
 		"Services":    services,
 		"Breadcrumbs": breadcrumbs,
+		"SEO":         app.pageSEO(r, "Services", "The services running in the homelab and whether they're up"),
 	}
 	app.Render(w, "services.html", data)
 }
 
 func (app *App) HandleBlog(w http.ResponseWriter, r *http.Request) {
-	category := r.URL.Query().Get("category")
-	posts, _ := app.DB.GetAllPosts()
-
-	if category != "" {
-		filtered := []models.Post{}
-		for _, p := range posts {
-			if p.Category == category {
-				filtered = append(filtered, p)
-			}
-		}
-		posts = filtered
+	// Categories have their own pages now; keep old filter links working
+	if category := r.URL.Query().Get("category"); category != "" {
+		http.Redirect(w, r, categoryURL(category), http.StatusMovedPermanently)
+		return
+	}
+
+	posts, next, err := app.DB.ListPosts("", db.PageOptions{Limit: blogPageSize, Cursor: r.URL.Query().Get("cursor")})
+	if errors.Is(err, db.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error listing posts: %v", err)
 	}
 
 	// Build breadcrumbs
@@ -60,8 +70,11 @@ func (app *App) HandleBlog(w http.ResponseWriter, r *http.Request) {
 	data := map[string]interface{}{
 		"Title":       "Blog - Atarnet Homelab",
 		"Posts":       posts,
-		"Category":    category,
+		"Category":    "",
+		"Categories":  app.blogCategories(),
+		"Pages":       pageLinks("/blog", r.URL.Query(), next),
 		"Breadcrumbs": breadcrumbs,
+		"SEO":         app.pageSEO(r, "Blog", ""),
 	}
 	app.Render(w, "blog.html", data)
 }
@@ -76,8 +89,17 @@ func (app *App) HandleBlogPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Unpublished posts are only visible to admins, as a preview
+	preview := !post.IsPublished(time.Now())
+	if preview && !app.Auth.IsAuthenticated(r) {
+		app.Handle404(w, r)
+		return
+	}
+
 	// Increment view count (ignore errors)
-	_ = app.DB.IncrementPostViews(id)
+	if !preview && !app.Static {
+		_ = app.DB.IncrementPostViews(id)
+	}
 
 	// Build breadcrumbs
 	breadcrumbs := []models.Breadcrumb{
@@ -86,27 +108,98 @@ func (app *App) HandleBlogPost(w http.ResponseWriter, r *http.Request) {
 		{Name: post.Title, URL: ""},
 	}
 
+	// Render once so the content and table of contents share heading IDs
+	doc := markdown.RenderDocument(post.Content)
+
+	related, err := app.DB.GetRelatedPosts(post.ID, relatedPostsShown)
+	if err != nil {
+		log.Printf("Error getting related posts: %v", err)
+	}
+
 	data := map[string]interface{}{
-		"Title":       post.Title + " - Atarnet Homelab",
-		"Post":        post,
-		"Breadcrumbs": breadcrumbs,
+		"Title":          post.Title + " - Atarnet Homelab",
+		"Post":           post,
+		"Content":        doc.HTML,
+		"TOC":            doc.TOC,
+		"Preview":        preview,
+		"Related":        related,
+		"Series":         app.seriesNav(post),
+		"Breadcrumbs":    breadcrumbs,
+		"StructuredData": app.postStructuredData(r, post, breadcrumbs),
+		"SEO":            app.postSEO(r, post),
+	}
+	if !app.Static {
+		data["CSRFToken"] = middleware.CSRFToken(r)
 	}
 	app.Render(w, "post.html", data)
 }
 
-func (app *App) HandleAbout(w http.ResponseWriter, _ *http.Request) {
+// relatedPostsShown is how many related posts a post page lists
+const relatedPostsShown = 3
+
+// seriesNav is the series index and previous/next links on a post page
+type seriesNav struct {
+	Name  string
+	Posts []models.Post
+	// Part is the post's 1-based position in Posts, 0 while it is unpublished
+	Part int
+	Prev *models.Post
+	Next *models.Post
+}
+
+// seriesNav returns the navigation for the series a post belongs to, or nil
+func (app *App) seriesNav(post *models.Post) *seriesNav {
+	if post.Series == "" {
+		return nil
+	}
+	posts, err := app.DB.GetSeries(post.Series)
+	if err != nil {
+		log.Printf("Error getting series %q: %v", post.Series, err)
+		return nil
+	}
+
+	nav := &seriesNav{Name: post.Series, Posts: posts}
+	for i := range posts {
+		if posts[i].ID != post.ID {
+			continue
+		}
+		nav.Part = i + 1
+		if i > 0 {
+			nav.Prev = &posts[i-1]
+		}
+		if i < len(posts)-1 {
+			nav.Next = &posts[i+1]
+		}
+	}
+	return nav
+}
+
+func (app *App) HandleAbout(w http.ResponseWriter, r *http.Request) {
 	data := map[string]interface{}{
 		"Title": "About - Atarnet Homelab",
+		"SEO":   app.pageSEO(r, "About", ""),
 	}
 	app.Render(w, "about.html", data)
 }
 
-func (app *App) HandleAdmin(w http.ResponseWriter, _ *http.Request) {
-	posts, _ := app.DB.GetAllPosts()
+func (app *App) HandleAdmin(w http.ResponseWriter, r *http.Request) {
+	posts, next, err := app.DB.ListPostsForAdmin(db.PageOptions{Limit: adminPageSize, Cursor: r.URL.Query().Get("cursor")})
+	if errors.Is(err, db.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error listing posts: %v", err)
+	}
+	services, _ := app.DB.GetAllServices()
 
 	data := map[string]interface{}{
-		"Title": "Blog Admin - Atarnet Homelab",
-		"Posts": posts,
+		"Title":     "Blog Admin - Atarnet Homelab",
+		"Posts":     posts,
+		"Pages":     pageLinks("/admin", r.URL.Query(), next),
+		"Services":  services,
+		"User":      app.Auth.User(r),
+		"CSRFToken": middleware.CSRFToken(r),
 	}
 	app.Render(w, "admin.html", data)
 }
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestHandleBlogPostTOC(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	post := &models.Post{
+		ID:       "toc-post",
+		Title:    "TOC Post",
+		Date:     time.Now(),
+		Category: "Testing",
+		Content:  "## Setup\n\n### Install\n\n## Setup\n",
+	}
+	if err := app.DB.SavePost(post); err != nil {
+		t.Fatalf("Failed to save post: %v", err)
+	}
+
+	// Stub template that prints the heading tree and the rendered content
+	app.Templates = template.Must(template.New("").Parse(
+		`{{define "post.html"}}{{range .TOC}}{{.ID}}[{{range .Children}}{{.ID}}{{end}}] {{end}}|{{.Content}}{{end}}`,
+	))
+
+	req := httptest.NewRequest("GET", "/blog/toc-post", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "toc-post"})
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleBlogPost).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	toc, content, _ := strings.Cut(rr.Body.String(), "|")
+	if toc != "setup[install] setup-1[] " {
+		t.Errorf("Unexpected TOC: %q", toc)
+	}
+	if !strings.Contains(content, `<h2 id="setup-1">`) {
+		t.Errorf("Expected content headings to carry matching IDs, got %q", content)
+	}
+}
+
+func TestHandleBlogPostDraftPreview(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	draft := &models.Post{
+		ID:       "draft-post",
+		Title:    "Draft Post",
+		Date:     time.Now(),
+		Category: "Testing",
+		Content:  "Not ready yet",
+		Status:   models.PostStatusDraft,
+	}
+	if err := app.DB.SavePost(draft); err != nil {
+		t.Fatalf("Failed to save post: %v", err)
+	}
+
+	app.Templates = template.Must(template.New("").Parse(
+		`{{define "post.html"}}preview={{.Preview}}{{end}}{{define "404.html"}}not found{{end}}`,
+	))
+
+	request := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/blog/draft-post", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "draft-post"})
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(app.HandleBlogPost).ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := request(nil); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for anonymous draft request, got %d", rr.Code)
+	}
+
+	rr := request(sessionCookie(t, app, "admin"))
+	if rr.Code != http.StatusOK || rr.Body.String() != "preview=true" {
+		t.Errorf("Expected admin preview, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	saved, _ := app.DB.GetPostByID("draft-post")
+	if saved.Views != 0 {
+		t.Errorf("Previews should not count as views, got %d", saved.Views)
+	}
+}
+
+func TestHandleBlogPostSeriesAndRelated(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+	app.Templates = template.Must(template.New("").Funcs(TemplateFuncs).ParseGlob("../web/templates/*.html"))
+
+	past := time.Now().Add(-time.Hour)
+	for i, title := range []string{"Cluster Part One", "Cluster Part Two", "Cluster Part Three"} {
+		post := &models.Post{
+			ID:          fmt.Sprintf("cluster-%d", i+1),
+			Title:       title,
+			Date:        past,
+			Category:    "Testing",
+			Tags:        []string{"test"},
+			Series:      "Cluster",
+			SeriesOrder: i + 1,
+		}
+		if err := app.DB.SavePost(post); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/blog/cluster-2", nil), map[string]string{"id": "cluster-2"})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleBlogPost).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+
+	body := rr.Body.String()
+	for _, want := range []string{
+		"Part 2 of 3 in the series <strong>Cluster</strong>",
+		`<a href="/blog/cluster-1" class="series-prev" rel="prev">`,
+		`<a href="/blog/cluster-3" class="series-next" rel="next">`,
+		"Related Posts",
+		`<a href="/blog/test-post-1" class="related-card">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in the post page", want)
+		}
+	}
+}
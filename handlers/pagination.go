@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tinotenda-alfaneti/homelabsite/db"
+)
+
+// Page sizes for the HTML listings; the JSON APIs take ?limit=
+const (
+	blogPageSize  = 10
+	adminPageSize = 50
+)
+
+// postFields are the post fields listings can return with ?fields=
+var postFields = []string{"id", "title", "date", "category", "summary", "content", "tags", "views", "status", "publish_at",
+	"series", "series_order", "updated_at"}
+
+// searchFields are the fields of a search result
+var searchFields = append(append([]string{}, postFields...), "score", "snippet")
+
+// listParams reads ?limit=, ?cursor= and ?fields= for a JSON listing.
+// Without fields= every allowed field except content is returned, and
+// content is only loaded when it is asked for.
+func listParams(r *http.Request, allowed []string) (db.PageOptions, []string, error) {
+	query := r.URL.Query()
+	page := db.PageOptions{Cursor: query.Get("cursor")}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return page, nil, fmt.Errorf("limit must be a positive number")
+		}
+		page.Limit = n
+	}
+
+	fields := query.Get("fields")
+	if fields == "" {
+		var defaults []string
+		for _, f := range allowed {
+			if f != "content" {
+				defaults = append(defaults, f)
+			}
+		}
+		return page, defaults, nil
+	}
+
+	var selected []string
+	for _, f := range strings.Split(fields, ",") {
+		f = strings.TrimSpace(f)
+		if !contains(allowed, f) {
+			return page, nil, fmt.Errorf("unknown field %q; use %s", f, strings.Join(allowed, ", "))
+		}
+		selected = append(selected, f)
+		if f == "content" {
+			page.Content = true
+		}
+	}
+	return page, selected, nil
+}
+
+// selectFields encodes items, a slice, as JSON objects with only the given fields
+func selectFields(items interface{}, fields []string) ([]map[string]json.RawMessage, error) {
+	b, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(b, &objects); err != nil {
+		return nil, err
+	}
+
+	for _, obj := range objects {
+		for key := range obj {
+			if !contains(fields, key) {
+				delete(obj, key)
+			}
+		}
+	}
+	if objects == nil {
+		objects = []map[string]json.RawMessage{}
+	}
+	return objects, nil
+}
+
+// pageURL returns path with query and the given cursor, for page links
+func pageURL(path string, query url.Values, cursor string) string {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	if cursor == "" {
+		q.Del("cursor")
+	} else {
+		q.Set("cursor", cursor)
+	}
+	if len(q) == 0 {
+		return path
+	}
+	return path + "?" + q.Encode()
+}
+
+// setNextLink advertises the next page of a JSON listing in the Link and
+// X-Next-Cursor headers
+func setNextLink(w http.ResponseWriter, r *http.Request, next string) {
+	if next == "" {
+		return
+	}
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, pageURL(r.URL.Path, r.URL.Query(), next)))
+	w.Header().Set("X-Next-Cursor", next)
+}
+
+// pageLinks returns the template data for the newest/older page navigation
+func pageLinks(path string, query url.Values, next string) map[string]string {
+	links := map[string]string{}
+	if query.Get("cursor") != "" {
+		links["First"] = pageURL(path, query, "")
+	}
+	if next != "" {
+		links["Next"] = pageURL(path, query, next)
+	}
+	return links
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
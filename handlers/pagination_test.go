@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestHandleAPIPostsPagination(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	older := &models.Post{ID: "older-post", Title: "Older", Date: time.Now().Add(-time.Hour), Category: "Testing", Content: "Older content"}
+	if err := app.DB.SavePost(older); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(url string) (*httptest.ResponseRecorder, []map[string]interface{}) {
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(app.HandleAPIPosts).ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		var posts []map[string]interface{}
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&posts); err != nil {
+				t.Fatalf("Failed to decode posts: %v", err)
+			}
+		}
+		return rr, posts
+	}
+
+	rr, posts := get("/api/posts?limit=1")
+	if len(posts) != 1 || posts[0]["id"] != "test-post-1" {
+		t.Fatalf("Expected the newest post, got %v", posts)
+	}
+	if _, ok := posts[0]["content"]; ok {
+		t.Error("Expected content to be left out by default")
+	}
+	next := rr.Header().Get("X-Next-Cursor")
+	if link := rr.Header().Get("Link"); next == "" || !strings.Contains(link, "cursor="+next) || !strings.HasSuffix(link, `rel="next"`) {
+		t.Fatalf("Expected a next Link header, got %q (cursor %q)", link, next)
+	}
+
+	rr, posts = get("/api/posts?limit=1&fields=id,content&cursor=" + next)
+	if len(posts) != 1 || posts[0]["id"] != "older-post" || posts[0]["content"] != "Older content" || len(posts[0]) != 2 {
+		t.Errorf("Expected only id and content of the older post, got %v", posts)
+	}
+	if rr.Header().Get("Link") != "" {
+		t.Error("Expected no Link header on the last page")
+	}
+
+	for _, url := range []string{
+		"/api/posts?limit=0",
+		"/api/posts?limit=abc",
+		"/api/posts?fields=id,password",
+		"/api/posts?cursor=bogus",
+	} {
+		if rr, _ := get(url); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", url, rr.Code)
+		}
+	}
+}
+
+func TestHandleSearchNextCursor(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	older := &models.Post{ID: "older-post", Title: "Older test", Date: time.Now().Add(-time.Hour), Category: "Testing", Content: "test"}
+	if err := app.DB.SavePost(older); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleSearch).ServeHTTP(rr, httptest.NewRequest("GET", "/api/search?q=test&limit=1", nil))
+
+	var response struct {
+		Posts      []map[string]interface{} `json:"posts"`
+		NextCursor string                   `json:"next_cursor"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Posts) != 1 || response.NextCursor == "" || rr.Header().Get("Link") == "" {
+		t.Errorf("Expected one result and a next cursor, got %+v", response)
+	}
+}
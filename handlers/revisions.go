@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+	"github.com/tinotenda-alfaneti/homelabsite/textdiff"
+)
+
+// FieldChange is a post field that differs between two revisions
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// RevisionDiff compares two revisions of a post
+type RevisionDiff struct {
+	PostID   string          `json:"post_id"`
+	From     int             `json:"from"`
+	To       int             `json:"to"`
+	Fields   []FieldChange   `json:"fields"`
+	Lines    []textdiff.Line `json:"lines"`
+	Inserted int             `json:"inserted"`
+	Deleted  int             `json:"deleted"`
+}
+
+func (app *App) HandleAPIListRevisions(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	revisions, err := app.DB.ListRevisions(id)
+	if err != nil {
+		log.Printf("Error listing revisions for %s: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(revisions); err != nil {
+		log.Printf("Error encoding revisions to JSON: %v", err)
+	}
+}
+
+func (app *App) HandleAPIGetRevision(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	rev, err := strconv.Atoi(vars["rev"])
+	if err != nil {
+		http.Error(w, "Invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	revision, err := app.DB.GetRevision(vars["id"], rev)
+	if err != nil {
+		log.Printf("Error getting revision: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if revision == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(revision); err != nil {
+		log.Printf("Error encoding revision to JSON: %v", err)
+	}
+}
+
+// HandleAPIDiffRevisions compares the revisions given by the from and to
+// query parameters, e.g. ?from=3&to=5
+func (app *App) HandleAPIDiffRevisions(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	from, errFrom := strconv.Atoi(r.URL.Query().Get("from"))
+	to, errTo := strconv.Atoi(r.URL.Query().Get("to"))
+	if errFrom != nil || errTo != nil {
+		http.Error(w, "from and to must be revision numbers", http.StatusBadRequest)
+		return
+	}
+
+	older, err := app.DB.GetRevision(id, from)
+	if err != nil {
+		log.Printf("Error getting revision: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	newer, err := app.DB.GetRevision(id, to)
+	if err != nil {
+		log.Printf("Error getting revision: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if older == nil || newer == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	lines := textdiff.Lines(older.Post.Content, newer.Post.Content)
+	inserted, deleted := textdiff.Stats(lines)
+	diff := RevisionDiff{
+		PostID:   id,
+		From:     from,
+		To:       to,
+		Fields:   fieldChanges(&older.Post, &newer.Post),
+		Lines:    lines,
+		Inserted: inserted,
+		Deleted:  deleted,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(diff); err != nil {
+		log.Printf("Error encoding diff to JSON: %v", err)
+	}
+}
+
+func (app *App) HandleAPIRestoreRevision(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	rev, err := strconv.Atoi(vars["rev"])
+	if err != nil {
+		http.Error(w, "Invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	post, err := app.DB.RestoreRevision(vars["id"], rev, app.Auth.Username(r))
+	if err != nil {
+		log.Printf("Error restoring revision: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if post == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"post":    post,
+	}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// fieldChanges lists the metadata fields that differ between two snapshots.
+// Content is diffed line by line separately.
+func fieldChanges(a, b *models.Post) []FieldChange {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"title", a.Title, b.Title},
+		{"date", formatTime(&a.Date), formatTime(&b.Date)},
+		{"category", a.Category, b.Category},
+		{"summary", a.Summary, b.Summary},
+		{"tags", strings.Join(a.Tags, ", "), strings.Join(b.Tags, ", ")},
+		{"status", a.Status, b.Status},
+		{"publish_at", formatTime(a.PublishAt), formatTime(b.PublishAt)},
+		{"series", a.Series, b.Series},
+		{"series_order", strconv.Itoa(a.SeriesOrder), strconv.Itoa(b.SeriesOrder)},
+	}
+
+	changes := []FieldChange{}
+	for _, f := range fields {
+		if f.from != f.to {
+			changes = append(changes, FieldChange{Field: f.name, From: f.from, To: f.to})
+		}
+	}
+	return changes
+}
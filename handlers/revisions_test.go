@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestHandleAPIRevisions(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	// setupTestApp saved revision 1; save an edit as revision 2
+	post, _ := app.DB.GetPostByID("test-post-1")
+	post.Title = "Edited title"
+	post.Content = "Test post content\nA new line"
+	if err := app.DB.SavePostAs(post, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	// List
+	req := httptest.NewRequest("GET", "/api/admin/posts/test-post-1/revisions", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "test-post-1"})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleAPIListRevisions).ServeHTTP(rr, req)
+
+	var revisions []map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&revisions); err != nil {
+		t.Fatalf("Failed to decode revisions: %v", err)
+	}
+	if len(revisions) != 2 || revisions[0]["author"] != "admin" {
+		t.Fatalf("Unexpected revisions: %v", revisions)
+	}
+
+	// Diff
+	req = httptest.NewRequest("GET", "/api/admin/posts/test-post-1/revisions/diff?from=1&to=2", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "test-post-1"})
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(app.HandleAPIDiffRevisions).ServeHTTP(rr, req)
+
+	var diff RevisionDiff
+	if err := json.NewDecoder(rr.Body).Decode(&diff); err != nil {
+		t.Fatalf("Failed to decode diff: %v", err)
+	}
+	if diff.Inserted != 1 || diff.Deleted != 0 {
+		t.Errorf("Expected +1 -0, got +%d -%d", diff.Inserted, diff.Deleted)
+	}
+	if len(diff.Fields) != 1 || diff.Fields[0].Field != "title" || diff.Fields[0].To != "Edited title" {
+		t.Errorf("Unexpected field changes: %+v", diff.Fields)
+	}
+
+	// Restore
+	req = httptest.NewRequest("POST", "/api/admin/posts/test-post-1/revisions/1/restore", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "test-post-1", "rev": "1"})
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(app.HandleAPIRestoreRevision).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Restore returned %d: %s", rr.Code, rr.Body.String())
+	}
+	restored, _ := app.DB.GetPostByID("test-post-1")
+	if restored.Title != "Test Post 1" {
+		t.Errorf("Expected title restored, got %q", restored.Title)
+	}
+}
+
+func TestFieldChangesSeries(t *testing.T) {
+	base := models.Post{Title: "Part 1", Series: "Homelab", SeriesOrder: 1}
+
+	tests := []struct {
+		name string
+		edit func(p *models.Post)
+		want []FieldChange
+	}{
+		{"series", func(p *models.Post) { p.Series = "Kubernetes" }, []FieldChange{{Field: "series", From: "Homelab", To: "Kubernetes"}}},
+		{"series order", func(p *models.Post) { p.SeriesOrder = 2 }, []FieldChange{{Field: "series_order", From: "1", To: "2"}}},
+		{"unchanged", func(p *models.Post) {}, []FieldChange{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edited := base
+			tt.edit(&edited)
+			if got := fieldChanges(&base, &edited); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHandleAPIRevisionsNotFound(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		url     string
+		vars    map[string]string
+		want    int
+	}{
+		{"missing revision", app.HandleAPIGetRevision, "/", map[string]string{"id": "test-post-1", "rev": "7"}, http.StatusNotFound},
+		{"diff missing revision", app.HandleAPIDiffRevisions, "/?from=1&to=7", map[string]string{"id": "test-post-1"}, http.StatusNotFound},
+		{"diff bad params", app.HandleAPIDiffRevisions, "/?from=a&to=1", map[string]string{"id": "test-post-1"}, http.StatusBadRequest},
+		{"restore missing revision", app.HandleAPIRestoreRevision, "/", map[string]string{"id": "test-post-1", "rev": "7"}, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest("GET", tt.url, nil), tt.vars)
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+}
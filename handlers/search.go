@@ -2,40 +2,90 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+
+	"github.com/tinotenda-alfaneti/homelabsite/db"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+	"github.com/tinotenda-alfaneti/homelabsite/search"
 )
 
+// search parses q with the search query language and runs it, adding tag
+// as a tag: filter. searchType is "query", "tag" or "all".
+func (app *App) search(q, tag string, page db.PageOptions) (results []models.SearchResult, next, searchType string, err error) {
+	query, err := search.Parse(q)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if tag != "" {
+		query.Tags = append(query.Tags, tag)
+	}
+
+	switch {
+	case q != "":
+		searchType = "query"
+	case tag != "":
+		searchType = "tag"
+	default:
+		searchType = "all"
+	}
+
+	results, next, err = app.DB.Search(query, page)
+	return results, next, searchType, err
+}
+
+// HandleSearch serves /api/search: JSON a page at a time with ?limit=,
+// ?cursor= and ?fields=, or the blog-posts fragment for HTMX requests
 func (app *App) HandleSearch(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
 	tag := r.URL.Query().Get("tag")
-
-	var posts interface{}
-	var err error
-
-	if tag != "" {
-		// Search by tag
-		posts, err = app.DB.SearchPostsByTag(tag)
-	} else if query != "" {
-		// Full-text search
-		posts, err = app.DB.SearchPosts(query)
-	} else {
-		// No search query, return empty
-		posts, err = app.DB.GetAllPosts()
+	htmx := r.Header.Get("HX-Request") == "true"
+
+	page := db.PageOptions{Limit: blogPageSize, Cursor: r.URL.Query().Get("cursor")}
+	var fields []string
+	if !htmx {
+		var err error
+		if page, fields, err = listParams(r, searchFields); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 	}
 
-	if err != nil {
+	posts, next, searchType, err := app.search(query, tag, page)
+	var syntaxErr *search.SyntaxError
+	if errors.As(err, &syntaxErr) && !htmx {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": syntaxErr,
+			"query": query,
+		}); err != nil {
+			log.Printf("Error encoding search error to JSON: %v", err)
+		}
+		return
+	}
+	if errors.Is(err, db.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil && syntaxErr == nil {
 		log.Printf("Search error: %v", err)
 		http.Error(w, "Search failed", http.StatusInternalServerError)
 		return
 	}
 
-	// Check if HTMX request
-	if r.Header.Get("HX-Request") == "true" {
+	// HTMX requests get the results fragment, with parse errors shown inline
+	if htmx {
 		data := map[string]interface{}{
-			"Posts": posts,
-			"Query": query,
-			"Tag":   tag,
+			"Posts":      posts,
+			"Query":      query,
+			"Tag":        tag,
+			"SearchType": searchType,
+			"Pages":      pageLinks("/search", r.URL.Query(), next),
+		}
+		if syntaxErr != nil {
+			data["Error"] = syntaxErr.Message
 		}
 		w.Header().Set("Content-Type", "text/html")
 		if err := app.Templates.ExecuteTemplate(w, "blog-posts", data); err != nil {
@@ -44,12 +94,21 @@ func (app *App) HandleSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	body, err := selectFields(posts, fields)
+	if err != nil {
+		log.Printf("Error selecting search result fields: %v", err)
+		http.Error(w, "Search failed", http.StatusInternalServerError)
+		return
+	}
+
 	// Return JSON
+	setNextLink(w, r, next)
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(map[string]interface{}{
-		"posts": posts,
-		"query": query,
-		"tag":   tag,
+		"posts":       body,
+		"query":       query,
+		"tag":         tag,
+		"next_cursor": next,
 	}); err != nil {
 		log.Printf("Error encoding search results to JSON: %v", err)
 	}
@@ -59,22 +118,14 @@ func (app *App) HandleSearchPage(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
 	tag := r.URL.Query().Get("tag")
 
-	var posts interface{}
-	var err error
-	var searchType string
-
-	if tag != "" {
-		posts, err = app.DB.SearchPostsByTag(tag)
-		searchType = "tag"
-	} else if query != "" {
-		posts, err = app.DB.SearchPosts(query)
-		searchType = "query"
-	} else {
-		posts, err = app.DB.GetAllPosts()
-		searchType = "all"
+	page := db.PageOptions{Limit: blogPageSize, Cursor: r.URL.Query().Get("cursor")}
+	posts, next, searchType, err := app.search(query, tag, page)
+	var syntaxErr *search.SyntaxError
+	if errors.Is(err, db.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
 	}
-
-	if err != nil {
+	if err != nil && !errors.As(err, &syntaxErr) {
 		log.Printf("Search error: %v", err)
 		http.Error(w, "Search failed", http.StatusInternalServerError)
 		return
@@ -90,22 +141,33 @@ func (app *App) HandleSearchPage(w http.ResponseWriter, r *http.Request) {
 		"Tag":        tag,
 		"SearchType": searchType,
 		"AllTags":    allTags,
+		"Pages":      pageLinks("/search", r.URL.Query(), next),
+	}
+	if syntaxErr != nil {
+		data["Error"] = syntaxErr.Message
 	}
 
 	app.Render(w, "search.html", data)
 }
 
+// HandleAPITags lists the tags of published posts by name, with "counts"
+// giving the number of published posts that have each
 func (app *App) HandleAPITags(w http.ResponseWriter, _ *http.Request) {
-	tags, err := app.DB.GetAllTags()
+	counts, err := app.DB.GetTagCounts()
 	if err != nil {
 		log.Printf("Error getting tags: %v", err)
 		http.Error(w, "Failed to get tags", http.StatusInternalServerError)
 		return
 	}
+	names := make([]string, len(counts))
+	for i, t := range counts {
+		names[i] = t.Name
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(map[string]interface{}{
-		"tags": tags,
+		"tags":   names,
+		"counts": counts,
 	}); err != nil {
 		log.Printf("Error encoding tags to JSON: %v", err)
 	}
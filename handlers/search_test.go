@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"html/template"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 )
 
@@ -59,6 +61,100 @@ func TestHandleSearch(t *testing.T) {
 	}
 }
 
+func TestHandleSearchHTMXFragment(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	app.Templates = template.Must(template.New("").Funcs(TemplateFuncs).ParseGlob("../web/templates/*.html"))
+
+	req := httptest.NewRequest("GET", "/api/search?q=test", nil)
+	req.Header.Set("HX-Request", "true")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleSearch).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, `Search results for "test"`) || !strings.Contains(body, `href="/blog/test-post-1"`) {
+		t.Errorf("Expected the blog-posts fragment with the matching post, got:\n%s", body)
+	}
+}
+
+func TestHandleSearchQueryLanguage(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	tests := []struct {
+		name          string
+		url           string
+		expectedCount int
+	}{
+		{"Tag filter", "/api/search?q=" + url.QueryEscape("tag:go"), 1},
+		{"Excluded tag", "/api/search?q=" + url.QueryEscape("-tag:go"), 0},
+		{"Query and tag together", "/api/search?tag=test&q=" + url.QueryEscape(`category:Testing "test post"`), 1},
+		{"Tag param narrows the query", "/api/search?tag=missing&q=test", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(app.HandleSearch).ServeHTTP(rr, httptest.NewRequest("GET", tt.url, nil))
+
+			var response struct {
+				Posts []map[string]interface{} `json:"posts"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(response.Posts) != tt.expectedCount {
+				t.Errorf("Expected %d results, got %d", tt.expectedCount, len(response.Posts))
+			}
+		})
+	}
+}
+
+func TestHandleSearchSyntaxError(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+	app.Templates = template.Must(template.New("").Funcs(TemplateFuncs).ParseGlob("../web/templates/*.html"))
+
+	// JSON clients get a 400 with a structured error
+	req := httptest.NewRequest("GET", "/api/search?q="+url.QueryEscape("go after:soon"), nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleSearch).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d", rr.Code)
+	}
+	var response struct {
+		Error struct {
+			Message  string `json:"message"`
+			Position int    `json:"position"`
+			Token    string `json:"token"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode error: %v", err)
+	}
+	if response.Error.Position != 3 || response.Error.Token != "after:soon" || response.Error.Message == "" {
+		t.Errorf("Unexpected error: %+v", response.Error)
+	}
+
+	// The HTMX search box gets the message in the results fragment
+	req = httptest.NewRequest("GET", "/api/search?q="+url.QueryEscape(`"unclosed`), nil)
+	req.Header.Set("HX-Request", "true")
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(app.HandleSearch).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 so HTMX swaps the message in, got %d", rr.Code)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, "missing closing quote") || !strings.Contains(body, `class="search-error"`) {
+		t.Errorf("Expected a friendly error in the fragment, got:\n%s", body)
+	}
+}
+
 func TestHandleAPITags(t *testing.T) {
 	app := setupTestApp(t)
 	defer teardownTestApp(app)
@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// sitemapSize is the most URLs one sitemap may list, from the sitemap
+// protocol. Past it, /sitemap.xml becomes an index of /sitemap-{n}.xml files.
+var sitemapSize = 50000
+
+// sitemapPages are the public pages that aren't posts, tags or categories.
+// Those marked fresh change whenever a post is published or edited.
+var sitemapPages = []struct {
+	Path  string
+	Fresh bool
+}{
+	{"/", true},
+	{"/blog", true},
+	{"/services", false},
+	{"/status", false},
+	{"/about", false},
+}
+
+// robotsDisallow are the paths robots.txt always keeps crawlers out of
+var robotsDisallow = []string{"/admin", "/api"}
+
+const sitemapNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+	// modified is LastMod as a time, zero when unknown
+	modified time.Time
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	NS      string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	NS       string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// HandleSitemap serves /sitemap.xml: every public URL, or an index of the
+// numbered sitemaps once there are more than sitemapSize
+func (app *App) HandleSitemap(w http.ResponseWriter, r *http.Request) {
+	urls, err := app.sitemapURLs(r)
+	if err != nil {
+		log.Printf("Error building sitemap: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if len(urls) <= sitemapSize {
+		app.serveSitemap(w, r, sitemapURLSet{NS: sitemapNS, URLs: urls}, newest(urls))
+		return
+	}
+
+	base := app.baseURL(r)
+	index := sitemapIndex{NS: sitemapNS}
+	for n := 1; (n-1)*sitemapSize < len(urls); n++ {
+		part := sitemapPart(urls, n)
+		index.Sitemaps = append(index.Sitemaps, sitemapURL{
+			Loc:     fmt.Sprintf("%s/sitemap-%d.xml", base, n),
+			LastMod: lastMod(newest(part)),
+		})
+	}
+	app.serveSitemap(w, r, index, newest(urls))
+}
+
+// HandleSitemapPart serves /sitemap-{n}.xml, the nth sitemapSize URLs
+func (app *App) HandleSitemapPart(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.Atoi(mux.Vars(r)["n"])
+	if err != nil || n < 1 {
+		http.NotFound(w, r)
+		return
+	}
+	urls, err := app.sitemapURLs(r)
+	if err != nil {
+		log.Printf("Error building sitemap: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	part := sitemapPart(urls, n)
+	if len(part) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	app.serveSitemap(w, r, sitemapURLSet{NS: sitemapNS, URLs: part}, newest(part))
+}
+
+func (app *App) serveSitemap(w http.ResponseWriter, r *http.Request, doc interface{}, modified time.Time) {
+	body, err := xml.Marshal(doc)
+	if err != nil {
+		log.Printf("Error encoding sitemap: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	serveGenerated(w, r, "application/xml; charset=utf-8", modified, append([]byte(xml.Header), body...))
+}
+
+// sitemapURLs lists the public pages, then posts newest first, then tags and
+// categories by name. A tag or category was last modified with its newest
+// post; posts carry the canonical names, so they match the counts exactly.
+func (app *App) sitemapURLs(r *http.Request) ([]sitemapURL, error) {
+	posts, err := app.DB.GetPostIndex()
+	if err != nil {
+		return nil, err
+	}
+	tags, err := app.DB.GetTagCounts()
+	if err != nil {
+		return nil, err
+	}
+	categories, err := app.DB.GetCategoryCounts()
+	if err != nil {
+		return nil, err
+	}
+
+	tagModified := map[string]time.Time{}
+	categoryModified := map[string]time.Time{}
+	postURLs := make([]sitemapURL, 0, len(posts))
+	for _, post := range posts {
+		modified := lastModified([]models.Post{post})
+		postURLs = append(postURLs, sitemapURL{Loc: "/blog/" + post.ID, modified: modified})
+		for _, tag := range post.Tags {
+			tagModified[tag] = later(tagModified[tag], modified)
+		}
+		if post.Category != "" {
+			categoryModified[post.Category] = later(categoryModified[post.Category], modified)
+		}
+	}
+
+	var urls []sitemapURL
+	for _, page := range sitemapPages {
+		u := sitemapURL{Loc: page.Path}
+		if page.Fresh {
+			u.modified = lastModified(posts)
+		}
+		urls = append(urls, u)
+	}
+	urls = append(urls, postURLs...)
+	for _, tag := range tags {
+		urls = append(urls, sitemapURL{Loc: tagURL(tag.Name), modified: tagModified[tag.Name]})
+	}
+	for _, category := range categories {
+		urls = append(urls, sitemapURL{Loc: categoryURL(category.Name), modified: categoryModified[category.Name]})
+	}
+
+	base := app.baseURL(r)
+	for i := range urls {
+		urls[i].Loc = base + urls[i].Loc
+		urls[i].LastMod = lastMod(urls[i].modified)
+	}
+	return urls, nil
+}
+
+// sitemapPart returns the nth (1-based) sitemapSize URLs
+func sitemapPart(urls []sitemapURL, n int) []sitemapURL {
+	start := (n - 1) * sitemapSize
+	if start >= len(urls) {
+		return nil
+	}
+	return urls[start:Min(start+sitemapSize, len(urls))]
+}
+
+func newest(urls []sitemapURL) time.Time {
+	var t time.Time
+	for _, u := range urls {
+		t = later(t, u.modified)
+	}
+	return t
+}
+
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// lastMod formats a time for a sitemap, empty for the zero time
+func lastMod(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// HandleRobots serves /robots.txt, keeping crawlers out of robotsDisallow
+// and site.robots.disallow from config.yaml, and pointing them at the sitemap
+func (app *App) HandleRobots(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	seen := map[string]bool{}
+	for _, path := range append(append([]string{}, robotsDisallow...), app.site().Robots.Disallow...) {
+		path = strings.TrimSpace(path)
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		b.WriteString("Disallow: " + path + "\n")
+	}
+	b.WriteString("\nSitemap: " + app.baseURL(r) + "/sitemap.xml\n")
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := w.Write([]byte(b.String())); err != nil {
+		log.Printf("Error writing robots.txt: %v", err)
+	}
+}
+
+// schema.org types for JSON-LD structured data, https://schema.org/BlogPosting
+type ldBlogPosting struct {
+	Context          string   `json:"@context"`
+	Type             string   `json:"@type"`
+	Headline         string   `json:"headline"`
+	Description      string   `json:"description,omitempty"`
+	URL              string   `json:"url"`
+	MainEntityOfPage ldThing  `json:"mainEntityOfPage"`
+	DatePublished    string   `json:"datePublished"`
+	DateModified     string   `json:"dateModified"`
+	Author           *ldThing `json:"author,omitempty"`
+	Publisher        *ldThing `json:"publisher,omitempty"`
+	ArticleSection   string   `json:"articleSection,omitempty"`
+	Keywords         string   `json:"keywords,omitempty"`
+	IsPartOf         *ldThing `json:"isPartOf,omitempty"`
+	Image            string   `json:"image,omitempty"`
+}
+
+// ldThing is a named or identified schema.org node
+type ldThing struct {
+	Type string `json:"@type"`
+	ID   string `json:"@id,omitempty"`
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+type ldBreadcrumbList struct {
+	Context         string             `json:"@context"`
+	Type            string             `json:"@type"`
+	ItemListElement []ldBreadcrumbItem `json:"itemListElement"`
+}
+
+type ldBreadcrumbItem struct {
+	Type     string `json:"@type"`
+	Position int    `json:"position"`
+	Name     string `json:"name"`
+	Item     string `json:"item"`
+}
+
+// postStructuredData is the JSON-LD of a post page: a BlogPosting and the
+// BreadcrumbList of its breadcrumbs
+func (app *App) postStructuredData(r *http.Request, post *models.Post, breadcrumbs []models.Breadcrumb) template.JS {
+	base := app.baseURL(r)
+	site := app.site()
+	pageURL := base + "/blog/" + post.ID
+
+	modified := published(*post)
+	if post.UpdatedAt != nil && post.UpdatedAt.After(modified) {
+		modified = *post.UpdatedAt
+	}
+	posting := ldBlogPosting{
+		Context:          "https://schema.org",
+		Type:             "BlogPosting",
+		Headline:         post.Title,
+		Description:      post.Summary,
+		URL:              pageURL,
+		MainEntityOfPage: ldThing{Type: "WebPage", ID: pageURL},
+		DatePublished:    published(*post).UTC().Format(time.RFC3339),
+		DateModified:     modified.UTC().Format(time.RFC3339),
+		Publisher:        &ldThing{Type: "Organization", Name: site.Title, URL: base + "/"},
+		ArticleSection:   post.Category,
+		Keywords:         strings.Join(post.Tags, ", "),
+		Image:            base + "/og/" + post.ID + ".png",
+	}
+	if site.Author.Name != "" {
+		posting.Author = &ldThing{Type: "Person", Name: site.Author.Name, URL: base + "/about"}
+	}
+	if post.Series != "" {
+		posting.IsPartOf = &ldThing{Type: "CreativeWorkSeries", Name: post.Series}
+	}
+
+	return structuredData(posting, breadcrumbList(base, pageURL, breadcrumbs))
+}
+
+// breadcrumbList turns a page's breadcrumbs into a BreadcrumbList. The last
+// crumb, which has no URL since it is the current page, links to pageURL.
+func breadcrumbList(base, pageURL string, breadcrumbs []models.Breadcrumb) ldBreadcrumbList {
+	list := ldBreadcrumbList{Context: "https://schema.org", Type: "BreadcrumbList"}
+	for i, crumb := range breadcrumbs {
+		item := pageURL
+		if crumb.URL != "" {
+			item = base + crumb.URL
+		}
+		list.ItemListElement = append(list.ItemListElement, ldBreadcrumbItem{
+			Type:     "ListItem",
+			Position: i + 1,
+			Name:     crumb.Name,
+			Item:     item,
+		})
+	}
+	return list
+}
+
+// structuredData encodes JSON-LD nodes for a <script type="application/ld+json">
+// tag. json.Marshal escapes <, > and &, so the result can't close the script.
+func structuredData(nodes ...interface{}) template.JS {
+	body, err := json.Marshal(nodes)
+	if err != nil {
+		log.Printf("Error encoding structured data: %v", err)
+		return ""
+	}
+	return template.JS(body)
+}
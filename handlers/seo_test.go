@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestHandleSitemap(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	draft := &models.Post{ID: "draft", Title: "Draft", Date: time.Now(), Status: models.PostStatusDraft, Tags: []string{"secret"}}
+	if err := app.DB.SavePost(draft); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleSitemap).ServeHTTP(rr, httptest.NewRequest("GET", "http://example.com/sitemap.xml", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+
+	var set sitemapURLSet
+	if err := xml.Unmarshal(rr.Body.Bytes(), &set); err != nil {
+		t.Fatal(err)
+	}
+	lastmods := map[string]string{}
+	for _, u := range set.URLs {
+		lastmods[u.Loc] = u.LastMod
+	}
+	for _, loc := range []string{"/", "/blog", "/about", "/blog/test-post-1", "/tags/go", "/category/Testing"} {
+		if _, ok := lastmods["http://example.com"+loc]; !ok {
+			t.Errorf("Expected %s in the sitemap, got %v", loc, lastmods)
+		}
+	}
+	post, _ := app.DB.GetPostByID("test-post-1")
+	if want := post.UpdatedAt.UTC().Format(time.RFC3339); lastmods["http://example.com/blog/test-post-1"] != want || lastmods["http://example.com/tags/go"] != want {
+		t.Errorf("Expected the post's updated_at as lastmod, got %v", lastmods)
+	}
+	if lastmods["http://example.com/about"] != "" {
+		t.Error("Expected no lastmod for static pages")
+	}
+	for loc := range lastmods {
+		if strings.Contains(loc, "draft") || strings.Contains(loc, "secret") {
+			t.Errorf("Expected unpublished posts and their tags to be left out, got %s", loc)
+		}
+	}
+}
+
+func TestHandleSitemapIndex(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	defer func(size int) { sitemapSize = size }(sitemapSize)
+	sitemapSize = 4
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleSitemap).ServeHTTP(rr, httptest.NewRequest("GET", "http://example.com/sitemap.xml", nil))
+	var index sitemapIndex
+	if err := xml.Unmarshal(rr.Body.Bytes(), &index); err != nil {
+		t.Fatal(err)
+	}
+	// 5 pages, 1 post, 2 tags and 1 category
+	if len(index.Sitemaps) != 3 || index.Sitemaps[2].Loc != "http://example.com/sitemap-3.xml" {
+		t.Fatalf("Expected 3 sitemaps, got %+v", index.Sitemaps)
+	}
+
+	tests := []struct {
+		n        string
+		wantCode int
+		wantURLs int
+	}{
+		{"1", http.StatusOK, 4},
+		{"3", http.StatusOK, 1},
+		{"4", http.StatusNotFound, 0},
+		{"0", http.StatusNotFound, 0},
+	}
+	for _, tt := range tests {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), map[string]string{"n": tt.n})
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(app.HandleSitemapPart).ServeHTTP(rr, req)
+		if rr.Code != tt.wantCode {
+			t.Errorf("sitemap-%s: expected %d, got %d", tt.n, tt.wantCode, rr.Code)
+			continue
+		}
+		if tt.wantCode != http.StatusOK {
+			continue
+		}
+		var set sitemapURLSet
+		if err := xml.Unmarshal(rr.Body.Bytes(), &set); err != nil {
+			t.Fatal(err)
+		}
+		if len(set.URLs) != tt.wantURLs {
+			t.Errorf("sitemap-%s: expected %d URLs, got %d", tt.n, tt.wantURLs, len(set.URLs))
+		}
+	}
+}
+
+func TestHandleRobots(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	app.Config = &models.Config{AppConfig: &models.AppConfig{}}
+	app.Config.AppConfig.Site.BaseURL = "https://example.com"
+	app.Config.AppConfig.Site.Robots.Disallow = []string{"/search", "/api", " "}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleRobots).ServeHTTP(rr, httptest.NewRequest("GET", "/robots.txt", nil))
+
+	want := "User-agent: *\nDisallow: /admin\nDisallow: /api\nDisallow: /search\n\nSitemap: https://example.com/sitemap.xml\n"
+	if rr.Body.String() != want {
+		t.Errorf("Unexpected robots.txt:\n%s", rr.Body.String())
+	}
+}
+
+func TestPostStructuredData(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+	app.Templates = template.Must(template.New("").Funcs(TemplateFuncs).ParseGlob("../web/templates/*.html"))
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "http://example.com/blog/test-post-1", nil), map[string]string{"id": "test-post-1"})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleBlogPost).ServeHTTP(rr, req)
+
+	m := regexp.MustCompile(`<script type="application/ld\+json">(.*?)</script>`).FindStringSubmatch(rr.Body.String())
+	if m == nil {
+		t.Fatal("Expected JSON-LD in the post page")
+	}
+	var nodes []map[string]interface{}
+	if err := json.Unmarshal([]byte(m[1]), &nodes); err != nil {
+		t.Fatalf("Invalid JSON-LD %s: %v", m[1], err)
+	}
+	if len(nodes) != 2 || nodes[0]["@type"] != "BlogPosting" || nodes[1]["@type"] != "BreadcrumbList" {
+		t.Fatalf("Expected a BlogPosting and a BreadcrumbList, got %v", nodes)
+	}
+	if nodes[0]["headline"] != "Test Post 1" || nodes[0]["keywords"] != "test, go" || nodes[0]["url"] != "http://example.com/blog/test-post-1" {
+		t.Errorf("Unexpected BlogPosting %v", nodes[0])
+	}
+
+	items := nodes[1]["itemListElement"].([]interface{})
+	last := items[len(items)-1].(map[string]interface{})
+	if len(items) != 3 || last["name"] != "Test Post 1" || last["item"] != "http://example.com/blog/test-post-1" || last["position"] != 3.0 {
+		t.Errorf("Expected the breadcrumbs ending at the post, got %v", items)
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// sessionInfo is a session as the admin lists it
+type sessionInfo struct {
+	models.Session
+	Username string `json:"username"`
+	// Current marks the session of the request
+	Current bool `json:"current"`
+}
+
+// HandleAPISessions lists the signed-in sessions of every user
+func (app *App) HandleAPISessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := app.Auth.Sessions()
+	if err != nil {
+		log.Printf("Error listing sessions: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	users, err := app.DB.ListUsers()
+	if err != nil {
+		log.Printf("Error listing users: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	usernames := make(map[int64]string, len(users))
+	for _, u := range users {
+		usernames[u.ID] = u.Username
+	}
+
+	current := app.Auth.SessionID(r)
+	infos := make([]sessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		infos = append(infos, sessionInfo{Session: s, Username: usernames[s.UserID], Current: s.ID == current})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(infos); err != nil {
+		log.Printf("Error encoding sessions: %v", err)
+	}
+}
+
+// HandleAPIRevokeSession signs out the session with the {id} route variable
+func (app *App) HandleAPIRevokeSession(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	s, err := app.Auth.GetSession(id)
+	if err != nil {
+		log.Printf("Error getting session: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if s == nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	app.Auth.RevokeSession(id)
+	log.Printf("%s revoked a session of user %d", app.Auth.Username(r), s.UserID)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
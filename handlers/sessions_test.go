@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestHandleAPISessions(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	mine := sessionCookie(t, app, "admin")
+	other := sessionCookie(t, app, "admin")
+
+	list := func() []sessionInfo {
+		req := httptest.NewRequest("GET", "/api/admin/sessions", nil)
+		req.AddCookie(mine)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(app.HandleAPISessions).ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rr.Code)
+		}
+		var sessions []sessionInfo
+		if err := json.Unmarshal(rr.Body.Bytes(), &sessions); err != nil {
+			t.Fatal(err)
+		}
+		return sessions
+	}
+
+	sessions := list()
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %+v", sessions)
+	}
+	var revoke string
+	for _, s := range sessions {
+		if s.Username != "admin" || s.IP == "" {
+			t.Errorf("Expected the session's user and IP, got %+v", s)
+		}
+		if !s.Current {
+			revoke = s.ID
+		}
+	}
+	if revoke == "" {
+		t.Fatal("Expected one session to be marked current")
+	}
+
+	tests := []struct {
+		id   string
+		want int
+	}{
+		{revoke, http.StatusOK},
+		{revoke, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := mux.SetURLVars(httptest.NewRequest("DELETE", "/", nil), map[string]string{"id": tt.id})
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(app.HandleAPIRevokeSession).ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("Expected %d, got %d", tt.want, rr.Code)
+		}
+	}
+
+	check := httptest.NewRequest("GET", "/admin", nil)
+	check.AddCookie(other)
+	if app.Auth.IsAuthenticated(check) {
+		t.Error("Expected the revoked session to be signed out")
+	}
+	if sessions := list(); len(sessions) != 1 || !sessions[0].Current {
+		t.Errorf("Expected only the current session left, got %+v", sessions)
+	}
+}
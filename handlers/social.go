@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+	"github.com/tinotenda-alfaneti/homelabsite/ogimage"
+)
+
+// pageSEO is the metadata of a page other than a post, using the site's
+// description when the page has none and the site's preview image
+func (app *App) pageSEO(r *http.Request, title, description string) models.SEO {
+	site := app.site()
+	base := app.baseURL(r)
+	if description == "" {
+		description = site.Description
+	}
+	return models.SEO{
+		Title:       title,
+		Description: description,
+		Canonical:   base + r.URL.EscapedPath(),
+		Type:        "website",
+		Image:       base + "/og.png",
+		SiteName:    site.Title,
+	}
+}
+
+// postSEO is the metadata of a post page, with the post's own preview image
+func (app *App) postSEO(r *http.Request, post *models.Post) models.SEO {
+	base := app.baseURL(r)
+	return models.SEO{
+		Title:       post.Title,
+		Description: post.Summary,
+		Canonical:   base + "/blog/" + post.ID,
+		Type:        "article",
+		Image:       base + "/og/" + post.ID + ".png",
+		SiteName:    app.site().Title,
+	}
+}
+
+// HandleOGImage serves the social preview image of a post at /og/{id}.png
+func (app *App) HandleOGImage(w http.ResponseWriter, r *http.Request) {
+	post, err := app.DB.GetPostByID(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("Error getting post: %v", err)
+	}
+	published := post != nil && post.IsPublished(time.Now())
+	if post == nil || (!published && !app.Auth.IsAuthenticated(r)) {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Drafts are only shown to signed-in users, so shared caches mustn't keep them
+	cacheControl := "public, max-age=86400"
+	if !published {
+		cacheControl = "private, no-store"
+	}
+	app.serveOGImage(w, r, app.postCard(post), lastModified([]models.Post{*post}), cacheControl)
+}
+
+// HandleSiteOGImage serves the social preview image of the other pages at /og.png
+func (app *App) HandleSiteOGImage(w http.ResponseWriter, r *http.Request) {
+	app.serveOGImage(w, r, app.siteCard(), time.Time{}, "public, max-age=86400")
+}
+
+// PruneOGImages removes cached preview images that no post or page uses any
+// more, such as those of old titles and deleted posts
+func (app *App) PruneOGImages() (int, error) {
+	posts, err := app.DB.GetAllPostsForAdmin()
+	if err != nil {
+		return 0, err
+	}
+	cards := []ogimage.Card{app.siteCard()}
+	for i := range posts {
+		cards = append(cards, app.postCard(&posts[i]))
+	}
+	return app.OGImages.Prune(cards)
+}
+
+func (app *App) postCard(post *models.Post) ogimage.Card {
+	return app.brandCard(ogimage.Card{Title: post.Title, Category: post.Category})
+}
+
+func (app *App) siteCard() ogimage.Card {
+	site := app.site()
+	title := site.Description
+	if title == "" {
+		title = site.Title
+	}
+	return app.brandCard(ogimage.Card{Title: title})
+}
+
+// brandCard adds the site's name and host to a card. The host only comes from
+// site.base_url, never the request, so clients can't have a card drawn and
+// cached for every Host header they send.
+func (app *App) brandCard(card ogimage.Card) ogimage.Card {
+	site := app.site()
+	card.Site = site.Title
+	card.Host = strings.TrimPrefix(strings.TrimPrefix(strings.TrimRight(site.BaseURL, "/"), "https://"), "http://")
+	return card
+}
+
+// serveOGImage serves a card from the image cache with the given Cache-Control.
+// Images are named by their content, so public ones can be cached a while.
+func (app *App) serveOGImage(w http.ResponseWriter, r *http.Request, card ogimage.Card, modified time.Time, cacheControl string) {
+	img, err := app.OGImages.Get(card)
+	if err != nil {
+		log.Printf("Error rendering social image: %v", err)
+		http.Error(w, "Error rendering image", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	serveGenerated(w, r, "image/png", modified, img)
+}
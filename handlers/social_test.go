@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+	"github.com/tinotenda-alfaneti/homelabsite/ogimage"
+)
+
+func TestPageSEOMetadata(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+	app.Templates = template.Must(template.New("").Funcs(TemplateFuncs).ParseGlob("../web/templates/*.html"))
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		url     string
+		vars    map[string]string
+		want    []string
+	}{
+		{"post", app.HandleBlogPost, "http://example.com/blog/test-post-1", map[string]string{"id": "test-post-1"}, []string{
+			`<meta property="og:type" content="article">`,
+			`<meta property="og:title" content="Test Post 1">`,
+			`<meta name="description" content="A test post summary">`,
+			`<link rel="canonical" href="http://example.com/blog/test-post-1">`,
+			`<meta property="og:image" content="http://example.com/og/test-post-1.png">`,
+			`<meta name="twitter:card" content="summary_large_image">`,
+		}},
+		{"home", app.HandleHome, "http://example.com/", nil, []string{
+			`<meta property="og:type" content="website">`,
+			`<meta property="og:title" content="Atarnet Homelab">`,
+			`<link rel="canonical" href="http://example.com/">`,
+			`<meta property="og:image" content="http://example.com/og.png">`,
+		}},
+		{"tag", app.HandleTag, "http://example.com/tags/go", map[string]string{"tag": "go"}, []string{
+			`<meta property="og:title" content="Posts tagged go">`,
+			`<link rel="canonical" href="http://example.com/tags/go">`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest("GET", tt.url, nil), tt.vars)
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+
+			body := rr.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("Expected %s in the page", want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleOGImage(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+	app.OGImages = ogimage.NewCache(t.TempDir())
+
+	draft := &models.Post{ID: "draft", Title: "Draft", Date: time.Now(), Status: models.PostStatusDraft}
+	if err := app.DB.SavePost(draft); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		id       string
+		wantCode int
+	}{
+		{"post", app.HandleOGImage, "test-post-1", http.StatusOK},
+		{"draft", app.HandleOGImage, "draft", http.StatusNotFound},
+		{"unknown", app.HandleOGImage, "nope", http.StatusNotFound},
+		{"site", app.HandleSiteOGImage, "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), map[string]string{"id": tt.id})
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("Expected %d, got %d", tt.wantCode, rr.Code)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "image/png" || !strings.HasPrefix(rr.Body.String(), "\x89PNG") {
+				t.Errorf("Expected a PNG, got %s", ct)
+			}
+			if rr.Header().Get("ETag") == "" {
+				t.Error("Expected an ETag")
+			}
+			if cc := rr.Header().Get("Cache-Control"); cc != "public, max-age=86400" {
+				t.Errorf("Expected a public image, got %q", cc)
+			}
+		})
+	}
+
+	// Signed-in users see drafts, but shared caches mustn't keep them
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), map[string]string{"id": "draft"})
+	req.AddCookie(sessionCookie(t, app, "admin"))
+	rr := httptest.NewRecorder()
+	app.HandleOGImage(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected a signed-in user to see the draft's image, got %d", rr.Code)
+	}
+	if cc := rr.Header().Get("Cache-Control"); cc != "private, no-store" {
+		t.Errorf("Expected a draft's image not to be stored, got %q", cc)
+	}
+}
+
+func TestOGImageCacheIsBounded(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+	dir := t.TempDir()
+	app.OGImages = ogimage.NewCache(dir)
+
+	pngs := func() int {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.png"))
+		return len(files)
+	}
+
+	// Without a base URL, the Host header doesn't make new images
+	for _, host := range []string{"a.example.com", "b.example.com"} {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), map[string]string{"id": "test-post-1"})
+		req.Host = host
+		app.HandleOGImage(httptest.NewRecorder(), req)
+	}
+	if n := pngs(); n != 1 {
+		t.Fatalf("Expected one image for every Host, got %d", n)
+	}
+
+	// A renamed post's old image is pruned
+	post, _ := app.DB.GetPostByID("test-post-1")
+	post.Title = "Renamed"
+	if err := app.DB.SavePost(post); err != nil {
+		t.Fatal(err)
+	}
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), map[string]string{"id": "test-post-1"})
+	app.HandleOGImage(httptest.NewRecorder(), req)
+	if removed, err := app.PruneOGImages(); err != nil || removed != 1 || pngs() != 1 {
+		t.Errorf("Expected the old image to be pruned, got %d, %v, %d left", removed, err, pngs())
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/db"
+	"github.com/tinotenda-alfaneti/homelabsite/middleware"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// Status page windows
+const (
+	statusDays     = 90
+	statusHours    = 24
+	incidentWindow = statusDays * 24 * time.Hour
+)
+
+// StatusReport is the body of /api/status
+type StatusReport struct {
+	GeneratedAt time.Time              `json:"generated_at"`
+	Operational bool                   `json:"operational"`
+	Services    []models.ServiceStatus `json:"services"`
+	Incidents   []models.Incident      `json:"incidents"`
+}
+
+// buildStatus gathers the status of every monitored service and recent incidents
+func (app *App) buildStatus() (*StatusReport, error) {
+	now := time.Now()
+
+	services, err := app.DB.GetAllServices()
+	if err != nil {
+		return nil, err
+	}
+	if err := app.DB.AttachHealth(services, now.Add(-uptimeWindow)); err != nil {
+		return nil, err
+	}
+	daily, err := app.DB.GetUptime(db.BucketDay, statusDays, now)
+	if err != nil {
+		return nil, err
+	}
+	hourly, err := app.DB.GetUptime(db.BucketHour, statusHours, now)
+	if err != nil {
+		return nil, err
+	}
+	incidents, err := app.DB.ListIncidents(now.Add(-incidentWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &StatusReport{
+		GeneratedAt: now.UTC(),
+		Operational: true,
+		Services:    []models.ServiceStatus{},
+		Incidents:   incidents,
+	}
+	for _, s := range services {
+		// Services that have never been checked aren't monitored
+		if s.Health.State == models.HealthUnknown && daily[s.ID] == nil {
+			continue
+		}
+		if s.Health.State == models.HealthDown {
+			report.Operational = false
+		}
+
+		status := models.ServiceStatus{
+			ID:     s.ID,
+			Name:   s.Name,
+			Icon:   s.Icon,
+			Health: s.Health,
+			Daily:  daily[s.ID],
+			Hourly: hourly[s.ID],
+		}
+		if status.Daily == nil {
+			status.Daily = db.EmptyUptime(db.BucketDay, statusDays, now)
+		}
+		if status.Hourly == nil {
+			status.Hourly = db.EmptyUptime(db.BucketHour, statusHours, now)
+		}
+
+		var checks, up int
+		for _, b := range status.Daily {
+			checks += b.Checks
+			up += b.Up
+		}
+		if checks > 0 {
+			status.Uptime = float64(up) * 100 / float64(checks)
+		}
+		report.Services = append(report.Services, status)
+	}
+
+	return report, nil
+}
+
+func (app *App) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	report, err := app.buildStatus()
+	if err != nil {
+		log.Printf("Error building status page: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	breadcrumbs := []models.Breadcrumb{
+		{Name: "Home", URL: "/"},
+		{Name: "Status", URL: ""},
+	}
+
+	isAdmin := app.Auth.IsAuthenticated(r)
+	data := map[string]interface{}{
+		"Title":       "Status - Atarnet Homelab",
+		"Status":      report,
+		"IsAdmin":     isAdmin,
+		"Breadcrumbs": breadcrumbs,
+		"SEO":         app.pageSEO(r, "Status", "Uptime of the homelab services over the last 90 days, and incidents"),
+	}
+	if isAdmin {
+		data["CSRFToken"] = middleware.CSRFToken(r)
+	}
+	app.Render(w, "status.html", data)
+}
+
+func (app *App) HandleAPIStatus(w http.ResponseWriter, _ *http.Request) {
+	report, err := app.buildStatus()
+	if err != nil {
+		log.Printf("Error building status: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error encoding status to JSON: %v", err)
+	}
+}
+
+// HandleAPIAddIncidentNote adds an admin note to an incident.
+// The body is JSON: {"body": "Restarted the pod"}.
+func (app *App) HandleAPIAddIncidentNote(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid incident id", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	note, err := app.DB.AddIncidentNote(id, app.Auth.Username(r), req.Body)
+	switch {
+	case errors.Is(err, db.ErrIncidentNotFound):
+		http.Error(w, "Incident not found", http.StatusNotFound)
+		return
+	case errors.Is(err, db.ErrEmptyNote):
+		http.Error(w, "Note is empty", http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("Error adding incident note: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"note":    note,
+	}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
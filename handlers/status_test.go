@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestHandleAPIStatus(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	// Only checked services appear on the status page
+	unchecked := &models.Service{Name: "Unchecked", Status: "internal"}
+	if err := app.DB.CreateService(unchecked); err != nil {
+		t.Fatal(err)
+	}
+	services, _ := app.DB.GetAllServices()
+	var serviceID int64
+	for _, s := range services {
+		if s.Name == "Test Service" {
+			serviceID = s.ID
+		}
+	}
+	if err := app.DB.RecordCheck(models.CheckResult{ServiceID: serviceID, CheckedAt: time.Now(), Error: "connection refused"}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/status", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleAPIStatus).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	var report StatusReport
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode status: %v", err)
+	}
+	if report.Operational {
+		t.Error("Expected a down service to make the site not operational")
+	}
+	if len(report.Services) != 1 || report.Services[0].Name != "Test Service" {
+		t.Fatalf("Expected only the checked service, got %+v", report.Services)
+	}
+	if got := len(report.Services[0].Daily); got != statusDays {
+		t.Errorf("Expected %d daily buckets, got %d", statusDays, got)
+	}
+	if got := len(report.Services[0].Hourly); got != statusHours {
+		t.Errorf("Expected %d hourly buckets, got %d", statusHours, got)
+	}
+	if len(report.Incidents) != 1 || !report.Incidents[0].Ongoing() {
+		t.Errorf("Expected one ongoing incident, got %+v", report.Incidents)
+	}
+}
+
+func TestHandleAPIAddIncidentNote(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	services, _ := app.DB.GetAllServices()
+	if err := app.DB.RecordCheck(models.CheckResult{ServiceID: services[0].ID, CheckedAt: time.Now(), Error: "timeout"}); err != nil {
+		t.Fatal(err)
+	}
+	incidents, _ := app.DB.ListIncidents(time.Now().Add(-time.Hour))
+	id := strconv.FormatInt(incidents[0].ID, 10)
+
+	tests := []struct {
+		name       string
+		id         string
+		body       string
+		wantStatus int
+	}{
+		{"adds note", id, `{"body": "Restarted the pod"}`, http.StatusCreated},
+		{"empty note", id, `{"body": "  "}`, http.StatusBadRequest},
+		{"invalid json", id, `{`, http.StatusBadRequest},
+		{"unknown incident", "9999", `{"body": "hello"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/admin/incidents/"+tt.id+"/notes", strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(app.HandleAPIAddIncidentNote).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	incidents, _ = app.DB.ListIncidents(time.Now().Add(-time.Hour))
+	if len(incidents[0].Notes) != 1 || incidents[0].Notes[0].Body != "Restarted the pod" {
+		t.Errorf("Expected the note to be saved, got %+v", incidents[0].Notes)
+	}
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/db"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// HandleTag lists the published posts with a tag at /tags/{tag}
+func (app *App) HandleTag(w http.ResponseWriter, r *http.Request) {
+	tag, err := app.DB.GetTag(mux.Vars(r)["tag"])
+	if err != nil {
+		log.Printf("Error getting tag: %v", err)
+	}
+	if tag == nil {
+		app.Handle404(w, r)
+		return
+	}
+
+	posts, next, err := app.DB.ListPostsByTag(tag.Name, db.PageOptions{Limit: blogPageSize, Cursor: r.URL.Query().Get("cursor")})
+	if errors.Is(err, db.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error listing posts: %v", err)
+	}
+
+	breadcrumbs := []models.Breadcrumb{
+		{Name: "Home", URL: "/"},
+		{Name: "Blog", URL: "/blog"},
+		{Name: "#" + tag.Name, URL: ""},
+	}
+
+	data := map[string]interface{}{
+		"Title":       "Posts tagged " + tag.Name + " - Atarnet Homelab",
+		"Posts":       posts,
+		"Tag":         tag,
+		"Categories":  app.blogCategories(),
+		"Pages":       pageLinks(r.URL.EscapedPath(), r.URL.Query(), next),
+		"Breadcrumbs": breadcrumbs,
+		"SEO":         app.pageSEO(r, "Posts tagged "+tag.Name, ""),
+	}
+	app.Render(w, "blog.html", data)
+}
+
+// HandleCategory lists the published posts in a category at /category/{name}
+func (app *App) HandleCategory(w http.ResponseWriter, r *http.Request) {
+	category, err := app.DB.GetCategory(mux.Vars(r)["name"])
+	if err != nil {
+		log.Printf("Error getting category: %v", err)
+	}
+	if category == nil {
+		app.Handle404(w, r)
+		return
+	}
+
+	posts, next, err := app.DB.ListPosts(category.Name, db.PageOptions{Limit: blogPageSize, Cursor: r.URL.Query().Get("cursor")})
+	if errors.Is(err, db.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error listing posts: %v", err)
+	}
+
+	breadcrumbs := []models.Breadcrumb{
+		{Name: "Home", URL: "/"},
+		{Name: "Blog", URL: "/blog"},
+		{Name: category.Name, URL: ""},
+	}
+
+	data := map[string]interface{}{
+		"Title":       category.Name + " - Atarnet Homelab",
+		"Posts":       posts,
+		"Category":    category.Name,
+		"Categories":  app.blogCategories(),
+		"Pages":       pageLinks(r.URL.EscapedPath(), r.URL.Query(), next),
+		"Breadcrumbs": breadcrumbs,
+		"SEO":         app.pageSEO(r, category.Name, "Posts filed under "+category.Name),
+	}
+	app.Render(w, "blog.html", data)
+}
+
+// blogCategories returns the categories for the blog filter buttons
+func (app *App) blogCategories() []models.Category {
+	categories, err := app.DB.GetCategoryCounts()
+	if err != nil {
+		log.Printf("Error getting categories: %v", err)
+	}
+	return categories
+}
+
+// tagURL is the listing page of a tag
+func tagURL(name string) string {
+	return "/tags/" + url.PathEscape(name)
+}
+
+// categoryURL is the listing page of a category
+func categoryURL(name string) string {
+	return "/category/" + url.PathEscape(name)
+}
+
+// HandleAPIAdminTags lists every tag with its number of posts of any status
+func (app *App) HandleAPIAdminTags(w http.ResponseWriter, _ *http.Request) {
+	tags, err := app.DB.GetTagCountsForAdmin()
+	if err != nil {
+		log.Printf("Error getting tags: %v", err)
+		http.Error(w, "Failed to get tags", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"tags": tags,
+	}); err != nil {
+		log.Printf("Error encoding tags to JSON: %v", err)
+	}
+}
+
+// HandleAPIRenameTag renames a tag on every post.
+// The body is JSON: {"name": "Kubernetes"}.
+func (app *App) HandleAPIRenameTag(w http.ResponseWriter, r *http.Request) {
+	id, name, ok := decodeRename(w, r, "tag")
+	if !ok {
+		return
+	}
+
+	tag, err := app.DB.RenameTag(id, name)
+	if err != nil {
+		writeTaxonomyError(w, err)
+		return
+	}
+	writeTaxonomyResponse(w, "tag", tag)
+}
+
+// HandleAPIMergeTag moves the posts of one tag to another and deletes it.
+// The body is JSON: {"into": 3}.
+func (app *App) HandleAPIMergeTag(w http.ResponseWriter, r *http.Request) {
+	src, dst, ok := decodeMerge(w, r, "tag")
+	if !ok {
+		return
+	}
+
+	tag, err := app.DB.MergeTags(src, dst)
+	if err != nil {
+		writeTaxonomyError(w, err)
+		return
+	}
+	writeTaxonomyResponse(w, "tag", tag)
+}
+
+// HandleAPIAdminCategories lists every category with its number of posts of any status
+func (app *App) HandleAPIAdminCategories(w http.ResponseWriter, _ *http.Request) {
+	categories, err := app.DB.GetCategoryCountsForAdmin()
+	if err != nil {
+		log.Printf("Error getting categories: %v", err)
+		http.Error(w, "Failed to get categories", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"categories": categories,
+	}); err != nil {
+		log.Printf("Error encoding categories to JSON: %v", err)
+	}
+}
+
+// HandleAPIRenameCategory renames a category on every post in it.
+// The body is JSON: {"name": "Cloud Native"}.
+func (app *App) HandleAPIRenameCategory(w http.ResponseWriter, r *http.Request) {
+	id, name, ok := decodeRename(w, r, "category")
+	if !ok {
+		return
+	}
+
+	category, err := app.DB.RenameCategory(id, name)
+	if err != nil {
+		writeTaxonomyError(w, err)
+		return
+	}
+	writeTaxonomyResponse(w, "category", category)
+}
+
+// HandleAPIMergeCategory moves the posts of one category to another and deletes it.
+// The body is JSON: {"into": 3}.
+func (app *App) HandleAPIMergeCategory(w http.ResponseWriter, r *http.Request) {
+	src, dst, ok := decodeMerge(w, r, "category")
+	if !ok {
+		return
+	}
+
+	category, err := app.DB.MergeCategories(src, dst)
+	if err != nil {
+		writeTaxonomyError(w, err)
+		return
+	}
+	writeTaxonomyResponse(w, "category", category)
+}
+
+// decodeRename reads the {id} route variable and the new name from the body,
+// writing a 400 response and returning false if either is invalid
+func decodeRename(w http.ResponseWriter, r *http.Request, kind string) (int64, string, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid "+kind+" id", http.StatusBadRequest)
+		return 0, "", false
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, "", false
+	}
+	return id, req.Name, true
+}
+
+// decodeMerge reads the {id} route variable and the id to merge it into
+// from the body, writing a 400 response and returning false if either is invalid
+func decodeMerge(w http.ResponseWriter, r *http.Request, kind string) (int64, int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid "+kind+" id", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	var req struct {
+		Into int64 `json:"into"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, 0, false
+	}
+	if req.Into == 0 {
+		http.Error(w, `"into" must be the id of the `+kind+` to merge into`, http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return id, req.Into, true
+}
+
+// writeTaxonomyError maps tag and category errors to HTTP status codes
+func writeTaxonomyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, db.ErrTagNotFound), errors.Is(err, db.ErrCategoryNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, db.ErrTagExists), errors.Is(err, db.ErrCategoryExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, db.ErrInvalidName), errors.Is(err, db.ErrMergeIntoSelf):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Error updating tags or categories: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func writeTaxonomyResponse(w http.ResponseWriter, key string, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		key:       value,
+	}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
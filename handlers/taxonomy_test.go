@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestHandleTagAndCategoryPages(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+	app.Templates = template.Must(template.New("").Funcs(TemplateFuncs).ParseGlob("../web/templates/*.html"))
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		vars     map[string]string
+		wantCode int
+		want     string
+	}{
+		{"tag any case", app.HandleTag, map[string]string{"tag": "GO"}, http.StatusOK, "#go"},
+		{"unknown tag", app.HandleTag, map[string]string{"tag": "rust"}, http.StatusNotFound, ""},
+		{"category any case", app.HandleCategory, map[string]string{"name": "testing"}, http.StatusOK, "Posts filed under Testing"},
+		{"unknown category", app.HandleCategory, map[string]string{"name": "Cooking"}, http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), tt.vars)
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("Expected %d, got %d", tt.wantCode, rr.Code)
+			}
+			body := rr.Body.String()
+			if tt.want != "" && !strings.Contains(body, tt.want) {
+				t.Errorf("Expected %q in the page", tt.want)
+			}
+			if tt.wantCode == http.StatusOK && !strings.Contains(body, "Test Post 1") {
+				t.Error("Expected the matching post to be listed")
+			}
+		})
+	}
+}
+
+func TestHandleBlogRedirectsCategoryFilter(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	req := httptest.NewRequest("GET", "/blog?category=Cloud+Native", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleBlog).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusMovedPermanently {
+		t.Fatalf("Expected 301, got %d", rr.Code)
+	}
+	if loc := rr.Header().Get("Location"); loc != "/category/Cloud%20Native" {
+		t.Errorf("Expected redirect to the category page, got %q", loc)
+	}
+}
+
+func TestHandleAPIRenameAndMergeTags(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	if err := app.DB.SavePost(&models.Post{ID: "golang", Title: "Golang", Date: time.Now(), Tags: []string{"golang"}}); err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]int64{}
+	tags, _ := app.DB.GetTagCountsForAdmin()
+	for _, tag := range tags {
+		ids[tag.Name] = tag.ID
+	}
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		id       int64
+		body     string
+		wantCode int
+	}{
+		{"rename", app.HandleAPIRenameTag, ids["test"], `{"name": "Testing"}`, http.StatusOK},
+		{"rename to existing", app.HandleAPIRenameTag, ids["test"], `{"name": "GO"}`, http.StatusConflict},
+		{"rename empty", app.HandleAPIRenameTag, ids["test"], `{"name": ""}`, http.StatusBadRequest},
+		{"rename unknown", app.HandleAPIRenameTag, 999, `{"name": "x"}`, http.StatusNotFound},
+		{"merge", app.HandleAPIMergeTag, ids["golang"], `{"into": ` + strconv.FormatInt(ids["go"], 10) + `}`, http.StatusOK},
+		{"merge without target", app.HandleAPIMergeTag, ids["go"], `{}`, http.StatusBadRequest},
+		{"merge into itself", app.HandleAPIMergeTag, ids["go"], `{"into": ` + strconv.FormatInt(ids["go"], 10) + `}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": strconv.FormatInt(tt.id, 10)})
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Errorf("Expected %d, got %d: %s", tt.wantCode, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleAPITags).ServeHTTP(rr, httptest.NewRequest("GET", "/api/tags", nil))
+	var resp struct {
+		Tags   []string     `json:"tags"`
+		Counts []models.Tag `json:"counts"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(resp.Tags, ",") != "go,Testing" {
+		t.Errorf("Expected the renamed and merged tags in order, got %v", resp.Tags)
+	}
+	if len(resp.Counts) != 2 || resp.Counts[0].Count != 2 {
+		t.Errorf("Expected go on 2 posts, got %+v", resp.Counts)
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/db"
+	"github.com/tinotenda-alfaneti/homelabsite/middleware"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+const (
+	// tokenPrefix starts every API token, so leaked ones are easy to search for
+	tokenPrefix = "hl_"
+	// tokenPrefixLength is how much of a token is kept to tell tokens apart
+	tokenPrefixLength = 10
+	// maxTokenDays is the longest a token can last
+	maxTokenDays = 365
+)
+
+var (
+	errInvalidExpiry   = errors.New("expires_in_days must be between 1 and 365")
+	errScopeNotAllowed = errors.New("your role doesn't allow one of the scopes")
+)
+
+// HandleAPITokens lists API tokens: every user's for admins, and the
+// signed-in user's own for everyone else. Secrets are never returned.
+func (app *App) HandleAPITokens(w http.ResponseWriter, r *http.Request) {
+	user := app.Auth.User(r)
+	owner := user.ID
+	if user.Role == models.RoleAdmin {
+		owner = 0
+	}
+	tokens, err := app.DB.ListAPITokens(owner)
+	if err != nil {
+		log.Printf("Error listing API tokens: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tokens); err != nil {
+		log.Printf("Error encoding API tokens: %v", err)
+	}
+}
+
+// HandleAPICreateToken creates a token for the signed-in user from
+// {"name", "scopes", "expires_in_days"}. Users can only grant scopes their
+// role allows. The secret is in the response and can't be shown again.
+func (app *App) HandleAPICreateToken(w http.ResponseWriter, r *http.Request) {
+	user := app.Auth.User(r)
+	var req struct {
+		Name          string         `json:"name"`
+		Scopes        []models.Scope `json:"scopes"`
+		ExpiresInDays int            `json:"expires_in_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresInDays < 1 || req.ExpiresInDays > maxTokenDays {
+		writeTokenError(w, errInvalidExpiry)
+		return
+	}
+	for _, scope := range req.Scopes {
+		if scope.Valid() && !user.HasRole(scope.Role()) {
+			writeTokenError(w, errScopeNotAllowed)
+			return
+		}
+	}
+
+	secret := tokenPrefix + middleware.GenerateToken()
+	token := &models.APIToken{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Name:      req.Name,
+		Prefix:    secret[:tokenPrefixLength],
+		Scopes:    req.Scopes,
+		ExpiresAt: app.now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour),
+	}
+	if err := app.DB.CreateAPIToken(token, middleware.HashToken(secret)); err != nil {
+		writeTokenError(w, err)
+		return
+	}
+	log.Printf("%s created API token %q", user.Username, token.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"token":   token,
+		"secret":  secret,
+	}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// HandleAPIDeleteToken revokes a token. Users can revoke their own tokens,
+// and admins anyone's.
+func (app *App) HandleAPIDeleteToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid token id", http.StatusBadRequest)
+		return
+	}
+	user := app.Auth.User(r)
+	token, err := app.DB.GetAPIToken(id)
+	if err != nil {
+		writeTokenError(w, err)
+		return
+	}
+	// Other users' tokens are reported missing, not forbidden, so their IDs
+	// can't be probed
+	if token == nil || (token.UserID != user.ID && user.Role != models.RoleAdmin) {
+		writeTokenError(w, db.ErrTokenNotFound)
+		return
+	}
+
+	if err := app.DB.DeleteAPIToken(id); err != nil {
+		writeTokenError(w, err)
+		return
+	}
+	log.Printf("%s revoked API token %q of %s", user.Username, token.Name, token.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"success": true}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// writeTokenError maps API token errors to HTTP status codes
+func writeTokenError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, db.ErrTokenNotFound), errors.Is(err, db.ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, db.ErrInvalidTokenName), errors.Is(err, db.ErrInvalidScope), errors.Is(err, errInvalidExpiry):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, errScopeNotAllowed):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		log.Printf("Error updating API token: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/middleware"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// tokenAPI calls a token handler as the signed-in user
+func tokenAPI(handler http.HandlerFunc, session *http.Cookie, method, path string, body interface{}) *httptest.ResponseRecorder {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	req.AddCookie(session)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// createToken creates a token as the signed-in user and returns it with its secret
+func createToken(t *testing.T, app *App, session *http.Cookie, scopes ...models.Scope) (models.APIToken, string) {
+	t.Helper()
+	rr := tokenAPI(app.HandleAPICreateToken, session, "POST", "/api/admin/tokens", map[string]interface{}{
+		"name": "jenkins", "scopes": scopes, "expires_in_days": 30,
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Token  models.APIToken `json:"token"`
+		Secret string          `json:"secret"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Token, resp.Secret
+}
+
+func TestCreateToken(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	app.Now = func() time.Time { return now }
+	inviteUser(t, app, "jane", models.RoleEditor)
+	editor := sessionCookie(t, app, "jane")
+
+	token, secret := createToken(t, app, editor, models.ScopePostsWrite)
+	if !strings.HasPrefix(secret, tokenPrefix) || token.Prefix != secret[:tokenPrefixLength] {
+		t.Errorf("Expected an %s token with its prefix kept, got %q and %q", tokenPrefix, secret, token.Prefix)
+	}
+	if token.Username != "jane" || !token.ExpiresAt.Equal(now.Add(30*24*time.Hour)) {
+		t.Errorf("Unexpected token %+v", token)
+	}
+	if stored, _ := app.DB.GetAPITokenByHash(middleware.HashToken(secret)); stored == nil || stored.ID != token.ID {
+		t.Error("Expected the token to be stored by its hash")
+	}
+
+	tests := []struct {
+		name string
+		body map[string]interface{}
+		want int
+	}{
+		{"no expiry", map[string]interface{}{"name": "ci", "scopes": []string{"posts:write"}}, http.StatusBadRequest},
+		{"too long", map[string]interface{}{"name": "ci", "scopes": []string{"posts:write"}, "expires_in_days": maxTokenDays + 1}, http.StatusBadRequest},
+		{"no name", map[string]interface{}{"scopes": []string{"posts:write"}, "expires_in_days": 30}, http.StatusBadRequest},
+		{"no scopes", map[string]interface{}{"name": "ci", "expires_in_days": 30}, http.StatusBadRequest},
+		{"unknown scope", map[string]interface{}{"name": "ci", "scopes": []string{"posts:read"}, "expires_in_days": 30}, http.StatusBadRequest},
+		{"scope beyond the role", map[string]interface{}{"name": "ci", "scopes": []string{"services:write"}, "expires_in_days": 30}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if rr := tokenAPI(app.HandleAPICreateToken, editor, "POST", "/api/admin/tokens", tt.body); rr.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestListAndRevokeTokens(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+	inviteUser(t, app, "jane", models.RoleEditor)
+	admin, editor := sessionCookie(t, app, "admin"), sessionCookie(t, app, "jane")
+
+	adminToken, _ := createToken(t, app, admin, models.ScopeServicesWrite)
+	editorToken, _ := createToken(t, app, editor, models.ScopePostsWrite)
+
+	list := func(session *http.Cookie) []models.APIToken {
+		rr := tokenAPI(app.HandleAPITokens, session, "GET", "/api/admin/tokens", nil)
+		var tokens []models.APIToken
+		if err := json.Unmarshal(rr.Body.Bytes(), &tokens); err != nil {
+			t.Fatal(err)
+		}
+		return tokens
+	}
+	if tokens := list(admin); len(tokens) != 2 {
+		t.Errorf("Expected admins to see every token, got %+v", tokens)
+	}
+	if tokens := list(editor); len(tokens) != 1 || tokens[0].ID != editorToken.ID {
+		t.Errorf("Expected editors to see only their own tokens, got %+v", tokens)
+	}
+
+	revoke := func(session *http.Cookie, id int64) int {
+		req := httptest.NewRequest("DELETE", fmt.Sprintf("/api/admin/tokens/%d", id), nil)
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(id)})
+		req.AddCookie(session)
+		rr := httptest.NewRecorder()
+		app.HandleAPIDeleteToken(rr, req)
+		return rr.Code
+	}
+	if code := revoke(editor, adminToken.ID); code != http.StatusNotFound {
+		t.Errorf("Expected an editor revoking another user's token to get 404, got %d", code)
+	}
+	if code := revoke(admin, editorToken.ID); code != http.StatusOK {
+		t.Errorf("Expected an admin to revoke any token, got %d", code)
+	}
+	if code := revoke(admin, editorToken.ID); code != http.StatusNotFound {
+		t.Errorf("Expected a revoked token to be gone, got %d", code)
+	}
+	if tokens := list(editor); len(tokens) != 0 {
+		t.Errorf("Expected no tokens left for the editor, got %+v", tokens)
+	}
+}
+
+func TestTokenPublishesPost(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+	inviteUser(t, app, "jane", models.RoleEditor)
+	_, secret := createToken(t, app, sessionCookie(t, app, "jane"), models.ScopePostsWrite)
+	publish := app.Auth.RequireScope(models.ScopePostsWrite)(app.HandleAPISavePost)
+
+	post := func(auth string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.Post{ID: "from-ci", Title: "From CI", Date: time.Now(), Status: models.PostStatusPublished})
+		req := httptest.NewRequest("POST", "/api/posts", bytes.NewReader(body))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rr := httptest.NewRecorder()
+		publish.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := post(""); rr.Code != http.StatusUnauthorized || !strings.Contains(rr.Body.String(), `"success":false`) {
+		t.Errorf("Expected a JSON 401 without a token, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := post("Bearer " + secret); rr.Code != http.StatusOK {
+		t.Fatalf("Expected the token to publish, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if saved, _ := app.DB.GetPostByID("from-ci"); saved == nil || saved.Status != models.PostStatusPublished {
+		t.Errorf("Expected the post to be published, got %+v", saved)
+	}
+}
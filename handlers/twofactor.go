@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/db"
+	"github.com/tinotenda-alfaneti/homelabsite/middleware"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+	"github.com/tinotenda-alfaneti/homelabsite/totp"
+	"rsc.io/qr"
+)
+
+const (
+	// challengeCookie holds the token of a sign-in waiting for its code
+	challengeCookie = "login_challenge"
+	// trustedDeviceCookie holds the token of a browser that skips the code step
+	trustedDeviceCookie = "trusted_device"
+	// challengeTTL is how long the user has to enter their code
+	challengeTTL = 5 * time.Minute
+	// maxChallengeAttempts is how many wrong codes end a sign-in
+	maxChallengeAttempts = 5
+	// trustedDeviceTTL is how long "remember this browser" lasts
+	trustedDeviceTTL = 30 * 24 * time.Hour
+	// recoveryCodeCount is how many recovery codes a user gets at a time
+	recoveryCodeCount = 10
+)
+
+// errInvalidCode is returned to the API for a wrong or reused code
+var errInvalidCode = errors.New("invalid code")
+
+// requireSecondFactor decides how a user who got their password right
+// continues: straight into a session, or on to the code step when they have
+// two-factor sign-in on and this browser isn't trusted
+func (app *App) requireSecondFactor(w http.ResponseWriter, r *http.Request, user *models.User) {
+	if !user.TOTPEnabled || app.trustedDevice(r, user) {
+		if err := app.DB.RecordLogin(user.ID); err != nil {
+			log.Printf("Error recording login: %v", err)
+		}
+		app.startSession(w, r, user)
+		return
+	}
+
+	token := middleware.GenerateToken()
+	now := app.now()
+	challenge := &models.LoginChallenge{ID: middleware.HashToken(token), UserID: user.ID, ExpiresAt: now.Add(challengeTTL)}
+	if err := app.DB.CreateLoginChallenge(challenge, now); err != nil {
+		log.Printf("Error creating login challenge: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	setLoginCookie(w, r, challengeCookie, token, challenge.ExpiresAt)
+	app.renderLogin(w, r, "", true)
+}
+
+// HandleLoginCode checks the code of a sign-in from requireSecondFactor: a
+// code from the authenticator app or an unused recovery code
+func (app *App) HandleLoginCode(w http.ResponseWriter, r *http.Request) {
+	var challenge *models.LoginChallenge
+	if cookie, err := r.Cookie(challengeCookie); err == nil {
+		challenge, err = app.DB.GetLoginChallenge(middleware.HashToken(cookie.Value), app.now())
+		if err != nil {
+			log.Printf("Error looking up login challenge: %v", err)
+		}
+	}
+	if challenge == nil {
+		app.renderLogin(w, r, "Your sign-in timed out, please start again", false)
+		return
+	}
+
+	user, err := app.DB.GetUserByID(challenge.UserID)
+	if err != nil {
+		log.Printf("Error looking up user: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if user == nil || user.Disabled || !user.TOTPEnabled {
+		app.endChallenge(w, r, challenge)
+		app.renderLogin(w, r, "Your sign-in timed out, please start again", false)
+		return
+	}
+
+	ok, err := app.checkSecondFactor(user, r.FormValue("code"))
+	if err != nil {
+		log.Printf("Error checking code: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		attempts, err := app.DB.FailLoginChallenge(challenge.ID)
+		if err != nil {
+			log.Printf("Error counting failed code: %v", err)
+		}
+		if attempts >= maxChallengeAttempts {
+			app.endChallenge(w, r, challenge)
+			app.renderLogin(w, r, "Too many wrong codes, please start again", false)
+			return
+		}
+		app.renderLogin(w, r, "Invalid code", true)
+		return
+	}
+
+	app.endChallenge(w, r, challenge)
+	if r.FormValue("remember") != "" {
+		app.trustDevice(w, r, user)
+	}
+	if err := app.DB.RecordLogin(user.ID); err != nil {
+		log.Printf("Error recording login: %v", err)
+	}
+	log.Printf("%s signed in with a second factor", user.Username)
+	app.startSession(w, r, user)
+}
+
+// checkSecondFactor reports whether code is a current authenticator code the
+// user hasn't used yet, or one of their unused recovery codes, using it up
+func (app *App) checkSecondFactor(user *models.User, code string) (bool, error) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if code == "" {
+		return false, nil
+	}
+	if len(code) == totp.Digits && strings.Trim(code, "0123456789") == "" {
+		step, ok := totp.Verify(user.TOTPSecret, code, app.now())
+		if !ok {
+			return false, nil
+		}
+		return app.DB.UseTOTPStep(user.ID, step)
+	}
+	return app.DB.UseRecoveryCode(user.ID, middleware.HashToken(normalizeRecoveryCode(code)), app.now())
+}
+
+func (app *App) endChallenge(w http.ResponseWriter, r *http.Request, challenge *models.LoginChallenge) {
+	if err := app.DB.DeleteLoginChallenge(challenge.ID); err != nil {
+		log.Printf("Error deleting login challenge: %v", err)
+	}
+	setLoginCookie(w, r, challengeCookie, "", time.Unix(0, 0))
+}
+
+// trustedDevice reports whether the browser has a remembered sign-in of user
+func (app *App) trustedDevice(r *http.Request, user *models.User) bool {
+	cookie, err := r.Cookie(trustedDeviceCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	ok, err := app.DB.IsTrustedDevice(middleware.HashToken(cookie.Value), user.ID, app.now())
+	if err != nil {
+		log.Printf("Error looking up trusted device: %v", err)
+	}
+	return ok
+}
+
+// trustDevice lets the browser skip the code step for trustedDeviceTTL
+func (app *App) trustDevice(w http.ResponseWriter, r *http.Request, user *models.User) {
+	token := middleware.GenerateToken()
+	now := app.now()
+	expires := now.Add(trustedDeviceTTL)
+	if err := app.DB.TrustDevice(middleware.HashToken(token), user.ID, now, expires); err != nil {
+		log.Printf("Error trusting device: %v", err)
+		return
+	}
+	setLoginCookie(w, r, trustedDeviceCookie, token, expires)
+}
+
+// setLoginCookie sets one of the cookies only the sign-in pages read
+func setLoginCookie(w http.ResponseWriter, r *http.Request, name, value string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   middleware.SecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/admin/login",
+	})
+}
+
+// HandleAPITwoFactor reports whether the signed-in user has two-factor sign-in
+// on and how many recovery codes they have left
+func (app *App) HandleAPITwoFactor(w http.ResponseWriter, r *http.Request) {
+	user := app.Auth.User(r)
+	left, err := app.DB.CountRecoveryCodes(user.ID)
+	if err != nil {
+		log.Printf("Error counting recovery codes: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeTwoFactorResponse(w, map[string]interface{}{
+		"enabled":             user.TOTPEnabled,
+		"recovery_codes_left": left,
+	})
+}
+
+// HandleAPISetupTwoFactor starts enrolling the signed-in user with a new
+// secret, returned with a QR code of it for their authenticator app
+func (app *App) HandleAPISetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user := app.Auth.User(r)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Printf("Error generating TOTP secret: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := app.DB.StartTOTP(user.ID, secret); err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	uri := totp.URI(app.site().Title, user.Username, secret)
+	code, err := qr.Encode(uri, qr.M)
+	if err != nil {
+		log.Printf("Error encoding QR code: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	code.Scale = 4
+	writeTwoFactorResponse(w, map[string]interface{}{
+		"secret": secret,
+		"uri":    uri,
+		"qr":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(code.PNG()),
+	})
+}
+
+// HandleAPIEnableTwoFactor turns on two-factor sign-in once the user shows a
+// code from {"code"} for the secret from setup, returning their recovery codes
+func (app *App) HandleAPIEnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user := app.Auth.User(r)
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if user.TOTPEnabled {
+		writeTwoFactorError(w, db.ErrTOTPEnabled)
+		return
+	}
+	if user.TOTPSecret == "" {
+		writeTwoFactorError(w, db.ErrTOTPNotStarted)
+		return
+	}
+	step, ok := totp.Verify(user.TOTPSecret, strings.TrimSpace(req.Code), app.now())
+	if !ok {
+		writeTwoFactorError(w, errInvalidCode)
+		return
+	}
+
+	codes, hashes := newRecoveryCodes()
+	if err := app.DB.EnableTOTP(user.ID, step, hashes); err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+	log.Printf("%s turned on two-factor sign-in", user.Username)
+	writeTwoFactorResponse(w, map[string]interface{}{"recovery_codes": codes})
+}
+
+// HandleAPIDisableTwoFactor turns off two-factor sign-in for the signed-in
+// user, who confirms with a current code or recovery code in {"code"}
+func (app *App) HandleAPIDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.confirmSecondFactor(w, r)
+	if !ok {
+		return
+	}
+	if err := app.DB.DisableTOTP(user.ID); err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+	log.Printf("%s turned off two-factor sign-in", user.Username)
+	writeTwoFactorResponse(w, map[string]interface{}{})
+}
+
+// HandleAPIRecoveryCodes replaces the signed-in user's recovery codes, after
+// they confirm with a current code or recovery code in {"code"}
+func (app *App) HandleAPIRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.confirmSecondFactor(w, r)
+	if !ok {
+		return
+	}
+	codes, hashes := newRecoveryCodes()
+	if err := app.DB.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+	log.Printf("%s replaced their recovery codes", user.Username)
+	writeTwoFactorResponse(w, map[string]interface{}{"recovery_codes": codes})
+}
+
+// confirmSecondFactor checks the {"code"} of a request from a user with
+// two-factor sign-in on, writing an error response and returning false if
+// it's wrong
+func (app *App) confirmSecondFactor(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	user := app.Auth.User(r)
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if !user.TOTPEnabled {
+		writeTwoFactorError(w, db.ErrTOTPNotStarted)
+		return nil, false
+	}
+	ok, err := app.checkSecondFactor(user, req.Code)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return nil, false
+	}
+	if !ok {
+		writeTwoFactorError(w, errInvalidCode)
+		return nil, false
+	}
+	return user, true
+}
+
+// newRecoveryCodes returns recoveryCodeCount codes to show the user once, in
+// the form "abcde-fghij", and the hashes to store for them
+func newRecoveryCodes() ([]string, []string) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			log.Printf("Error generating recovery code: %v", err)
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = middleware.HashToken(code)
+	}
+	return codes, hashes
+}
+
+// normalizeRecoveryCode accepts recovery codes in any case, with or without
+// the dash
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(code, "-", ""))
+}
+
+func writeTwoFactorResponse(w http.ResponseWriter, resp map[string]interface{}) {
+	resp["success"] = true
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// writeTwoFactorError maps two-factor errors to HTTP status codes
+func writeTwoFactorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errInvalidCode):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, db.ErrTOTPEnabled), errors.Is(err, db.ErrTOTPNotStarted):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, db.ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Printf("Error updating two-factor sign-in: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
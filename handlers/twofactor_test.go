@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/totp"
+)
+
+// twoFactorAPI calls a two-factor API handler as the signed-in user
+func twoFactorAPI(t *testing.T, handler http.HandlerFunc, session *http.Cookie, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", "/api/admin/2fa", bytes.NewReader(b))
+	req.AddCookie(session)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	var resp map[string]interface{}
+	if rr.Code == http.StatusOK {
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rr, resp
+}
+
+// loginForm posts a step of the login form with the browser's cookies,
+// keeping any cookies the response sets
+func loginForm(handler http.HandlerFunc, cookies map[string]*http.Cookie, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/admin/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	for _, c := range rr.Result().Cookies() {
+		if c.Value == "" {
+			delete(cookies, c.Name)
+		} else {
+			cookies[c.Name] = c
+		}
+	}
+	return rr
+}
+
+func TestTwoFactorSignIn(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+	app.Templates = template.Must(template.New("").Funcs(TemplateFuncs).ParseGlob("../web/templates/*.html"))
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	app.Now = func() time.Time { return now }
+	session := sessionCookie(t, app, "admin")
+
+	// Enroll
+	rr, setup := twoFactorAPI(t, app.HandleAPISetupTwoFactor, session, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Setup: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	secret, _ := setup["secret"].(string)
+	if !strings.HasPrefix(setup["qr"].(string), "data:image/png;base64,") || !strings.HasPrefix(setup["uri"].(string), "otpauth://totp/") {
+		t.Errorf("Expected a QR code and otpauth URI, got %v", setup)
+	}
+	code := func() string {
+		c, err := totp.Code(secret, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	if rr, _ := twoFactorAPI(t, app.HandleAPIEnableTwoFactor, session, map[string]string{"code": "000000"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected a wrong code to get 400, got %d", rr.Code)
+	}
+	rr, enabled := twoFactorAPI(t, app.HandleAPIEnableTwoFactor, session, map[string]string{"code": code()})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Enable: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	recovery, _ := enabled["recovery_codes"].([]interface{})
+	if len(recovery) != recoveryCodeCount {
+		t.Fatalf("Expected %d recovery codes, got %v", recoveryCodeCount, enabled)
+	}
+	if rr, _ := twoFactorAPI(t, app.HandleAPISetupTwoFactor, session, nil); rr.Code != http.StatusConflict {
+		t.Errorf("Expected setup while on to get 409, got %d", rr.Code)
+	}
+
+	password := url.Values{"username": {"admin"}, "password": {"password"}}
+	signedIn := func(rr *httptest.ResponseRecorder) bool {
+		for _, c := range rr.Result().Cookies() {
+			if c.Name == "session_token" && c.Value != "" {
+				return rr.Code == http.StatusFound
+			}
+		}
+		return false
+	}
+
+	// The password alone leads to the code step, not a session
+	cookies := map[string]*http.Cookie{}
+	rr = loginForm(app.HandleLogin, cookies, password)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `action="/admin/login/code"`) || cookies[challengeCookie] == nil {
+		t.Fatalf("Expected the code step, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// The code used to turn it on can't be used again
+	if rr := loginForm(app.HandleLoginCode, cookies, url.Values{"code": {code()}}); !strings.Contains(rr.Body.String(), "Invalid code") {
+		t.Errorf("Expected a replayed code to be refused, got %d", rr.Code)
+	}
+	now = now.Add(totp.Period)
+	rr = loginForm(app.HandleLoginCode, cookies, url.Values{"code": {code()}, "remember": {"1"}})
+	if !signedIn(rr) {
+		t.Fatalf("Expected the next code to sign in, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if cookies[challengeCookie] != nil || cookies[trustedDeviceCookie] == nil {
+		t.Errorf("Expected the challenge cleared and the browser remembered, got %v", cookies)
+	}
+	if rr := loginForm(app.HandleLoginCode, cookies, url.Values{"code": {code()}}); !strings.Contains(rr.Body.String(), "timed out") {
+		t.Error("Expected the finished challenge not to work again")
+	}
+
+	// A remembered browser skips the code step, until it expires
+	if rr := loginForm(app.HandleLogin, cookies, password); !signedIn(rr) {
+		t.Errorf("Expected a remembered browser to sign in with the password, got %d", rr.Code)
+	}
+	now = now.Add(trustedDeviceTTL)
+	if rr := loginForm(app.HandleLogin, cookies, password); signedIn(rr) {
+		t.Error("Expected the remembered browser to expire")
+	}
+	delete(cookies, trustedDeviceCookie)
+
+	// Recovery codes work once, in any case and without the dash
+	useRecovery := func(c string) *httptest.ResponseRecorder {
+		loginForm(app.HandleLogin, cookies, password)
+		return loginForm(app.HandleLoginCode, cookies, url.Values{"code": {c}})
+	}
+	first := recovery[0].(string)
+	if rr := useRecovery(strings.ToUpper(strings.ReplaceAll(first, "-", ""))); !signedIn(rr) {
+		t.Errorf("Expected a recovery code to sign in, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := useRecovery(first); signedIn(rr) {
+		t.Error("Expected a used recovery code to be refused")
+	}
+
+	// Wrong codes end the sign-in after maxChallengeAttempts
+	cookies = map[string]*http.Cookie{}
+	loginForm(app.HandleLogin, cookies, password)
+	for i := 1; i <= maxChallengeAttempts; i++ {
+		rr := loginForm(app.HandleLoginCode, cookies, url.Values{"code": {"000000"}})
+		if i < maxChallengeAttempts && !strings.Contains(rr.Body.String(), "Invalid code") {
+			t.Errorf("Attempt %d: expected to be asked again", i)
+		}
+		if i == maxChallengeAttempts && !strings.Contains(rr.Body.String(), "Too many wrong codes") {
+			t.Errorf("Expected the sign-in to end after %d wrong codes", i)
+		}
+	}
+
+	// Challenges expire
+	loginForm(app.HandleLogin, cookies, password)
+	now = now.Add(challengeTTL)
+	if rr := loginForm(app.HandleLoginCode, cookies, url.Values{"code": {code()}}); !strings.Contains(rr.Body.String(), "timed out") {
+		t.Errorf("Expected an expired challenge to be refused, got %d", rr.Code)
+	}
+
+	// Turning it off takes a current code
+	if rr, _ := twoFactorAPI(t, app.HandleAPIDisableTwoFactor, session, map[string]string{"code": "000000"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected a wrong code to get 400, got %d", rr.Code)
+	}
+	now = now.Add(totp.Period)
+	if rr, _ := twoFactorAPI(t, app.HandleAPIDisableTwoFactor, session, map[string]string{"code": code()}); rr.Code != http.StatusOK {
+		t.Fatalf("Disable: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := loginForm(app.HandleLogin, map[string]*http.Cookie{}, password); !signedIn(rr) {
+		t.Error("Expected the password alone to sign in once two-factor sign-in is off")
+	}
+}
+
+func TestRecoveryCodesAPI(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	app.Now = func() time.Time { return now }
+	session := sessionCookie(t, app, "admin")
+
+	if rr, _ := twoFactorAPI(t, app.HandleAPIRecoveryCodes, session, map[string]string{"code": "000000"}); rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 before two-factor sign-in is on, got %d", rr.Code)
+	}
+
+	_, setup := twoFactorAPI(t, app.HandleAPISetupTwoFactor, session, nil)
+	secret := setup["secret"].(string)
+	code, _ := totp.Code(secret, now)
+	_, enabled := twoFactorAPI(t, app.HandleAPIEnableTwoFactor, session, map[string]string{"code": code})
+	old := enabled["recovery_codes"].([]interface{})
+
+	// A recovery code confirms replacing them, and the old ones stop working
+	rr, replaced := twoFactorAPI(t, app.HandleAPIRecoveryCodes, session, map[string]string{"code": old[0].(string)})
+	if rr.Code != http.StatusOK || len(replaced["recovery_codes"].([]interface{})) != recoveryCodeCount {
+		t.Fatalf("Expected new recovery codes, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr, _ := twoFactorAPI(t, app.HandleAPIRecoveryCodes, session, map[string]string{"code": old[1].(string)}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected an old recovery code to get 400, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/api/admin/2fa", nil)
+	req.AddCookie(session)
+	rr = httptest.NewRecorder()
+	app.HandleAPITwoFactor(rr, req)
+	var status struct {
+		Enabled bool `json:"enabled"`
+		Left    int  `json:"recovery_codes_left"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if !status.Enabled || status.Left != recoveryCodeCount {
+		t.Errorf("Expected on with %d codes left, got %+v", recoveryCodeCount, status)
+	}
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/db"
+	"github.com/tinotenda-alfaneti/homelabsite/middleware"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// inviteTTL is how long invite and password reset links work
+const inviteTTL = 7 * 24 * time.Hour
+
+// minPasswordLength is the shortest password a user can choose
+const minPasswordLength = 10
+
+// HandleAPIUsers lists the admin accounts
+func (app *App) HandleAPIUsers(w http.ResponseWriter, _ *http.Request) {
+	users, err := app.DB.ListUsers()
+	if err != nil {
+		log.Printf("Error listing users: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(users); err != nil {
+		log.Printf("Error encoding users: %v", err)
+	}
+}
+
+// HandleAPIInviteUser creates a user from {"username", "email", "role"} and
+// returns the link they use to choose a password. Nothing is emailed; the
+// admin passes the link on.
+func (app *App) HandleAPIInviteUser(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string      `json:"username"`
+		Email    string      `json:"email"`
+		Role     models.Role `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	token := middleware.GenerateToken()
+	user := &models.User{Username: req.Username, Email: req.Email, Role: req.Role}
+	if err := app.DB.InviteUser(user, middleware.HashToken(token), time.Now().Add(inviteTTL)); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	log.Printf("%s invited %s as %s", app.Auth.Username(r), user.Username, user.Role)
+
+	app.writeInviteResponse(w, r, http.StatusCreated, user, token)
+}
+
+// HandleAPIUpdateUser changes a user's role or disables them from
+// {"role", "disabled"}; fields left out keep their value. Disabling a user
+// signs them out.
+func (app *App) HandleAPIUpdateUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.userFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Role     *models.Role `json:"role"`
+		Disabled *bool        `json:"disabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	role, disabled := user.Role, user.Disabled
+	if req.Role != nil {
+		role = *req.Role
+	}
+	if req.Disabled != nil {
+		disabled = *req.Disabled
+	}
+
+	user, err := app.DB.UpdateUser(user.ID, role, disabled)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	if user.Disabled {
+		app.Auth.DeleteUserSessions(user.ID)
+	}
+	log.Printf("%s set %s to role %s, disabled %t", app.Auth.Username(r), user.Username, user.Role, user.Disabled)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"user":    user,
+	}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// HandleAPIResetUser clears a user's password and two-factor sign-in, signs
+// them out and returns a new link for them to choose a password
+func (app *App) HandleAPIResetUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.userFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	token := middleware.GenerateToken()
+	user, err := app.DB.ResetUser(user.ID, middleware.HashToken(token), time.Now().Add(inviteTTL))
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	app.Auth.DeleteUserSessions(user.ID)
+	if err := app.DB.DisableTOTP(user.ID); err != nil {
+		log.Printf("Error turning off two-factor sign-in: %v", err)
+	}
+	user.TOTPEnabled = false
+	log.Printf("%s reset the password of %s", app.Auth.Username(r), user.Username)
+
+	app.writeInviteResponse(w, r, http.StatusOK, user, token)
+}
+
+// HandleInvitePage shows the form for choosing a password at /admin/invite/{token}
+func (app *App) HandleInvitePage(w http.ResponseWriter, r *http.Request) {
+	user, err := app.DB.GetUserByInvite(middleware.HashToken(mux.Vars(r)["token"]))
+	if err != nil {
+		log.Printf("Error looking up invite: %v", err)
+	}
+	app.renderInvite(w, r, user, "")
+}
+
+// HandleAcceptInvite sets the password of an invited user and signs them in
+func (app *App) HandleAcceptInvite(w http.ResponseWriter, r *http.Request) {
+	tokenHash := middleware.HashToken(mux.Vars(r)["token"])
+	invitee, err := app.DB.GetUserByInvite(tokenHash)
+	if err != nil {
+		log.Printf("Error looking up invite: %v", err)
+	}
+	if invitee == nil {
+		app.renderInvite(w, r, nil, "")
+		return
+	}
+
+	password := r.FormValue("password")
+	switch {
+	case len(password) < minPasswordLength:
+		app.renderInvite(w, r, invitee, "Password must be at least "+strconv.Itoa(minPasswordLength)+" characters")
+		return
+	case password != r.FormValue("confirm"):
+		app.renderInvite(w, r, invitee, "Passwords don't match")
+		return
+	}
+
+	hash, err := middleware.HashPassword(password)
+	if err != nil {
+		log.Printf("Error hashing password: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	user, err := app.DB.AcceptInvite(tokenHash, hash)
+	if errors.Is(err, db.ErrInviteNotFound) {
+		app.renderInvite(w, r, nil, "")
+		return
+	}
+	if err != nil {
+		log.Printf("Error accepting invite: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := app.DB.RecordLogin(user.ID); err != nil {
+		log.Printf("Error recording login: %v", err)
+	}
+	app.startSession(w, r, user)
+}
+
+// renderInvite shows the invite page for invitee, or that the link doesn't
+// work when invitee is nil
+func (app *App) renderInvite(w http.ResponseWriter, r *http.Request, invitee *models.User, message string) {
+	// The token's cookie has to be set before the 404 status is written
+	csrfToken := middleware.CSRFToken(r)
+	if invitee == nil {
+		w.WriteHeader(http.StatusNotFound)
+		message = db.ErrInviteNotFound.Error()
+	}
+	app.Render(w, "invite.html", map[string]interface{}{
+		"Title":     "Choose a Password - Atarnet Homelab",
+		"Invitee":   invitee,
+		"Error":     message,
+		"MinLength": minPasswordLength,
+		"CSRFToken": csrfToken,
+	})
+}
+
+// userFromPath looks up the user with the {id} route variable, writing an
+// error response and returning false if there's none
+func (app *App) userFromPath(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return nil, false
+	}
+	user, err := app.DB.GetUserByID(id)
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if user == nil {
+		http.Error(w, db.ErrUserNotFound.Error(), http.StatusNotFound)
+		return nil, false
+	}
+	return user, true
+}
+
+func (app *App) writeInviteResponse(w http.ResponseWriter, r *http.Request, status int, user *models.User, token string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"user":       user,
+		"invite_url": app.baseURL(r) + "/admin/invite/" + token,
+	}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// writeUserError maps user errors to HTTP status codes
+func writeUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, db.ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, db.ErrUserExists), errors.Is(err, db.ErrLastAdmin):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, db.ErrInvalidUsername), errors.Is(err, db.ErrInvalidRole):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Error updating user: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
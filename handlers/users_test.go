@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// inviteUser invites a user through the API and returns the invite token
+func inviteUser(t *testing.T, app *App, username string, role models.Role) (models.User, string) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"username": username, "role": role})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleAPIInviteUser).ServeHTTP(rr, httptest.NewRequest("POST", "http://example.com/api/admin/users", bytes.NewReader(body)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp struct {
+		User      models.User `json:"user"`
+		InviteURL string      `json:"invite_url"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	token := strings.TrimPrefix(resp.InviteURL, "http://example.com/admin/invite/")
+	if token == resp.InviteURL || token == "" {
+		t.Fatalf("Unexpected invite URL %q", resp.InviteURL)
+	}
+	return resp.User, token
+}
+
+func acceptInvite(app *App, token string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/admin/invite/"+token, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = mux.SetURLVars(req, map[string]string{"token": token})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleAcceptInvite).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestInviteAndAcceptUser(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+	app.Templates = template.Must(template.New("").Funcs(TemplateFuncs).ParseGlob("../web/templates/*.html"))
+
+	user, token := inviteUser(t, app, "jane", models.RoleEditor)
+	if user.Username != "jane" || user.Role != models.RoleEditor || user.InviteExpiresAt == nil {
+		t.Errorf("Unexpected invited user %+v", user)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		form     url.Values
+		wantCode int
+		wantBody string
+	}{
+		{"unknown token", "nope", url.Values{"password": {"long enough pw"}, "confirm": {"long enough pw"}}, http.StatusNotFound, "invalid or has expired"},
+		{"short password", token, url.Values{"password": {"short"}, "confirm": {"short"}}, http.StatusOK, "at least 10 characters"},
+		{"mismatch", token, url.Values{"password": {"long enough pw"}, "confirm": {"different pw!"}}, http.StatusOK, "don&#39;t match"},
+		{"accepted", token, url.Values{"password": {"long enough pw"}, "confirm": {"long enough pw"}}, http.StatusFound, ""},
+		{"used", token, url.Values{"password": {"long enough pw"}, "confirm": {"long enough pw"}}, http.StatusNotFound, "invalid or has expired"},
+	}
+	for _, tt := range tests {
+		rr := acceptInvite(app, tt.token, tt.form)
+		if rr.Code != tt.wantCode || !strings.Contains(rr.Body.String(), tt.wantBody) {
+			t.Errorf("%s: expected %d containing %q, got %d %s", tt.name, tt.wantCode, tt.wantBody, rr.Code, rr.Body.String())
+		}
+		if tt.name == "accepted" && !strings.Contains(rr.Header().Get("Set-Cookie"), "session_token=") {
+			t.Error("Expected accepting the invite to sign the user in")
+		}
+	}
+
+	if got := app.Auth.ValidateCredentials("jane", "long enough pw"); got == nil || got.ID != user.ID {
+		t.Errorf("Expected jane to sign in with the new password, got %+v", got)
+	}
+}
+
+func TestHandleAPIUpdateUser(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	user, token := inviteUser(t, app, "jane", models.RoleEditor)
+	acceptInvite(app, token, url.Values{"password": {"long enough pw"}, "confirm": {"long enough pw"}})
+	cookie := sessionCookie(t, app, "jane")
+	admin, _ := app.DB.GetUserByUsername("admin")
+
+	update := func(id int64, body string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest("PUT", "/", strings.NewReader(body)), map[string]string{"id": strconv.FormatInt(id, 10)})
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(app.HandleAPIUpdateUser).ServeHTTP(rr, req)
+		return rr
+	}
+
+	tests := []struct {
+		name     string
+		id       int64
+		body     string
+		wantCode int
+	}{
+		{"change role", user.ID, `{"role": "moderator"}`, http.StatusOK},
+		{"unknown role", user.ID, `{"role": "owner"}`, http.StatusBadRequest},
+		{"last admin", admin.ID, `{"disabled": true}`, http.StatusConflict},
+		{"missing user", 999, `{"disabled": true}`, http.StatusNotFound},
+		{"disable", user.ID, `{"disabled": true}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rr := update(tt.id, tt.body); rr.Code != tt.wantCode {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.wantCode, rr.Code, rr.Body.String())
+		}
+	}
+
+	saved, _ := app.DB.GetUserByID(user.ID)
+	if saved.Role != models.RoleModerator || !saved.Disabled {
+		t.Errorf("Expected a disabled moderator, got %+v", saved)
+	}
+	req := httptest.NewRequest("GET", "/admin", nil)
+	req.AddCookie(cookie)
+	if app.Auth.IsAuthenticated(req) {
+		t.Error("Expected disabling the user to end their session")
+	}
+}
+
+func TestHandleAPIResetUser(t *testing.T) {
+	app := setupTestApp(t)
+	defer teardownTestApp(app)
+
+	cookie := sessionCookie(t, app, "admin")
+	admin, _ := app.DB.GetUserByUsername("admin")
+	if err := app.DB.StartTOTP(admin.ID, "SECRET"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.DB.EnableTOTP(admin.ID, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest("POST", "http://example.com/", nil), map[string]string{"id": strconv.FormatInt(admin.ID, 10)})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.HandleAPIResetUser).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"invite_url":"http://example.com/admin/invite/`) {
+		t.Fatalf("Expected a new invite link, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	if app.Auth.ValidateCredentials("admin", "password") != nil {
+		t.Error("Expected the old password to stop working")
+	}
+	check := httptest.NewRequest("GET", "/admin", nil)
+	check.AddCookie(cookie)
+	if app.Auth.IsAuthenticated(check) {
+		t.Error("Expected the reset to end the user's sessions")
+	}
+	if u, _ := app.DB.GetUserByID(admin.ID); u.TOTPEnabled {
+		t.Error("Expected the reset to turn off two-factor sign-in, so a lost phone can be recovered")
+	}
+}
@@ -3,24 +3,27 @@ package main
 import (
 	"context"
 	"embed"
+	"errors"
+	"fmt"
 	"html/template"
-	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	"github.com/tinotenda-alfaneti/homelabsite/cache"
 	"github.com/tinotenda-alfaneti/homelabsite/config"
 	"github.com/tinotenda-alfaneti/homelabsite/db"
+	"github.com/tinotenda-alfaneti/homelabsite/frontmatter"
 	"github.com/tinotenda-alfaneti/homelabsite/handlers"
-	"github.com/tinotenda-alfaneti/homelabsite/markdown"
 	"github.com/tinotenda-alfaneti/homelabsite/middleware"
+	"github.com/tinotenda-alfaneti/homelabsite/ogimage"
+	"github.com/tinotenda-alfaneti/homelabsite/prober"
 	"golang.org/x/time/rate"
 )
 
@@ -33,14 +36,36 @@ func main() {
 		log.Printf("No .env file found, using environment variables or defaults")
 	}
 
-	// Get admin credentials from environment (Kubernetes Secret or local .env)
+	// Subcommands run against the database and exit without starting the server
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := runMigrate(getDBPath(), os.Args[2:]); err != nil {
+				log.Fatalf("migrate: %v", err)
+			}
+			return
+		case "sync":
+			if err := runSync(config.GetConfigPath(), getDBPath(), os.Args[2:]); err != nil {
+				log.Fatalf("sync: %v", err)
+			}
+			return
+		case "export":
+			if err := runExport(config.GetConfigPath(), getDBPath(), os.Args[2:]); err != nil {
+				log.Fatalf("export: %v", err)
+			}
+			return
+		default:
+			log.Fatalf("Unknown command %q", os.Args[1])
+		}
+	}
+
+	// Credentials for the first admin account (Kubernetes Secret or local .env)
 	adminUser := config.GetEnv("ADMIN_USER", "admin")
 	adminPass := config.GetEnv("ADMIN_PASS", "changeme")
 
 	// Get config path - smart detection for Kubernetes vs local
 	configPath := config.GetConfigPath()
 
-	log.Printf("Admin credentials - User: %s (set via ADMIN_USER and ADMIN_PASS)", adminUser)
 	log.Printf("Config path: %s", configPath)
 
 	// Load configuration
@@ -50,15 +75,7 @@ func main() {
 	}
 
 	// Get database path - smart detection for Kubernetes vs local
-	dbPath := config.GetEnv("DB_PATH", "")
-	if dbPath == "" {
-		// Use /app/data for Kubernetes PVC, local data/ for development
-		if _, err := os.Stat("/app/data"); err == nil {
-			dbPath = "/app/data/homelab.db"
-		} else {
-			dbPath = "data/homelab.db"
-		}
-	}
+	dbPath := getDBPath()
 
 	log.Printf("Database path: %s", dbPath)
 
@@ -69,33 +86,63 @@ func main() {
 	}
 	defer database.Close()
 
-	// Check if we need to migrate from YAML
-	if shouldMigrate(dbPath) {
-		log.Printf("Performing initial migration from YAML to database")
-		if err := database.MigrateFromYAML(cfg.Posts, cfg.Services); err != nil {
-			log.Fatalf("Failed to migrate from YAML: %v", err)
+	// Seed the first admin account; after that, users are managed from the admin
+	if err := bootstrapAdmin(database, adminUser, adminPass); err != nil {
+		log.Fatalf("Failed to create admin user: %v", err)
+	}
+
+	// Reconcile YAML content with the database
+	syncOpts, enabled, err := syncOptionsForMode(cfg.AppConfig.Data.Sync)
+	if err != nil {
+		log.Fatalf("Invalid data.sync setting: %v", err)
+	}
+	if enabled {
+		posts, err := contentPosts(cfg)
+		var loadErrs frontmatter.LoadErrors
+		if errors.As(err, &loadErrs) {
+			// Keep serving with the valid posts; without the invalid files a
+			// mirror sync would delete them, so fall back to upsert
+			for _, fe := range loadErrs {
+				log.Printf("Skipping invalid post: %v", fe)
+			}
+			syncOpts.Delete = false
+		} else if err != nil {
+			log.Fatalf("Failed to load posts: %v", err)
+		}
+
+		report, err := database.SyncContent(posts, cfg.Services, syncOpts)
+		if err != nil {
+			log.Fatalf("Failed to sync content from YAML: %v", err)
 		}
-		// Create migration marker
-		markerPath := filepath.Join(filepath.Dir(dbPath), ".migrated")
-		if err := os.WriteFile(markerPath, []byte(time.Now().String()), 0600); err != nil {
-			log.Printf("Warning: Failed to create migration marker: %v", err)
+		log.Printf("Content sync: %s", report)
+		for _, name := range report.Services.Conflicts {
+			log.Printf("Skipped service %q from YAML: another service already has that name", name)
 		}
 	}
 
-	// Parse templates
-	funcMap := template.FuncMap{
-		"markdown": markdown.Render,
-		"add": func(a, b int) int {
-			return a + b
-		},
+	// Probe service health and prune caches in the background until shutdown
+	probeCtx, stopProbing := context.WithCancel(context.Background())
+	defer stopProbing()
+	if cfg.AppConfig.Probe.Enabled {
+		opts, err := probeOptions(cfg.AppConfig)
+		if err != nil {
+			log.Fatalf("Invalid probe settings: %v", err)
+		}
+		go prober.New(database, opts).Run(probeCtx)
 	}
-	templates, err := template.New("").Funcs(funcMap).ParseFS(embedFS, "web/templates/*.html")
+
+	// Parse templates
+	templates, err := parseTemplates()
 	if err != nil {
 		log.Fatalf("Failed to load templates: %v", err)
 	}
 
 	// Create auth middleware
-	auth := middleware.NewAuthMiddleware(adminUser, adminPass)
+	sessions, err := sessionStore(cfg.AppConfig.Auth.Sessions, database)
+	if err != nil {
+		log.Fatalf("Invalid auth.sessions setting: %v", err)
+	}
+	auth := middleware.NewAuthMiddleware(database, sessions, database)
 
 	// Create rate limiter - 5 requests per second, burst of 10
 	rateLimiter := middleware.NewRateLimiter(rate.Limit(5), 10)
@@ -103,6 +150,9 @@ func main() {
 	// Create cache
 	cacheLayer := cache.New()
 
+	ogCacheDir := getOGCacheDir(dbPath)
+	log.Printf("Social image cache: %s", ogCacheDir)
+
 	// Create app
 	app := &handlers.App{
 		Config:     cfg,
@@ -111,56 +161,16 @@ func main() {
 		ConfigPath: configPath,
 		DB:         database,
 		Cache:      cacheLayer,
+		OGImages:   ogimage.NewCache(ogCacheDir),
 	}
 
-	// Setup router
-	r := mux.NewRouter()
+	// Remove preview images of old titles and deleted posts now and then
+	go pruneOGImages(probeCtx, app)
 
-	// Static files
-	staticFS, err := fs.Sub(embedFS, "web/static")
+	r, err := newRouter(app, rateLimiter)
 	if err != nil {
-		log.Fatalf("Failed to create static filesystem: %v", err)
-	}
-	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.FS(staticFS))))
-
-	// Page routes
-	r.HandleFunc("/", app.HandleHome).Methods("GET")
-	r.HandleFunc("/services", app.HandleServices).Methods("GET")
-	r.HandleFunc("/blog", app.HandleBlog).Methods("GET")
-	r.HandleFunc("/blog/{id}", app.HandleBlogPost).Methods("GET")
-	r.HandleFunc("/search", app.HandleSearchPage).Methods("GET")
-	r.HandleFunc("/about", app.HandleAbout).Methods("GET")
-	r.HandleFunc("/health", app.HandleHealth).Methods("GET")
-
-	// Auth routes
-	r.HandleFunc("/admin/login", app.HandleLoginPage).Methods("GET")
-	r.HandleFunc("/admin/login", rateLimiter.RateLimit(app.HandleLogin)).Methods("POST")
-	r.HandleFunc("/admin/logout", app.HandleLogout).Methods("GET")
-	r.HandleFunc("/admin", auth.RequireAuth(app.HandleAdmin)).Methods("GET")
-
-	// API routes
-	r.HandleFunc("/api/services", app.HandleAPIServices).Methods("GET")
-	r.HandleFunc("/api/posts", app.HandleAPIPosts).Methods("GET")
-	r.HandleFunc("/api/posts/popular", app.HandleAPIPopularPosts).Methods("GET")
-	r.HandleFunc("/api/posts/{id}", app.HandleAPIGetPost).Methods("GET")
-	r.HandleFunc("/api/posts", auth.RequireAuth(app.HandleAPISavePost)).Methods("POST")
-	r.HandleFunc("/api/posts/{id}", auth.RequireAuth(app.HandleAPIDeletePost)).Methods("DELETE")
-	r.HandleFunc("/api/search", app.HandleSearch).Methods("GET")
-	r.HandleFunc("/api/tags", app.HandleAPITags).Methods("GET")
-
-	// Comment routes
-	r.HandleFunc("/api/posts/{id}/comments", handlers.HandleGetComments(database)).Methods("GET")
-	r.HandleFunc("/api/posts/{id}/comments", rateLimiter.RateLimit(handlers.HandlePostComment(database))).Methods("POST")
-	r.HandleFunc("/api/admin/comments/pending", auth.RequireAuth(handlers.HandleGetPendingComments(database))).Methods("GET")
-	r.HandleFunc("/api/admin/comments/{id}/approve", auth.RequireAuth(handlers.HandleApproveComment(database))).Methods("POST")
-	r.HandleFunc("/api/admin/comments/{id}", auth.RequireAuth(handlers.HandleDeleteComment(database))).Methods("DELETE")
-
-	// RSS Feed
-	r.HandleFunc("/rss", app.HandleRSS).Methods("GET")
-	r.HandleFunc("/feed", app.HandleRSS).Methods("GET")
-
-	// 404 Handler
-	r.NotFoundHandler = http.HandlerFunc(app.Handle404)
+		log.Fatalf("Failed to set up routes: %v", err)
+	}
 
 	// Start server with graceful shutdown
 	port := config.GetEnv("PORT", "8082")
@@ -187,6 +197,7 @@ func main() {
 	// Wait for interrupt signal
 	<-stop
 	log.Println("Shutting down server gracefully...")
+	stopProbing()
 
 	// Create context with timeout for shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
@@ -199,9 +210,78 @@ func main() {
 	log.Println("Server stopped")
 }
 
-// shouldMigrate checks if we need to migrate from YAML to database
-func shouldMigrate(dbPath string) bool {
-	markerPath := filepath.Join(filepath.Dir(dbPath), ".migrated")
-	_, err := os.Stat(markerPath)
-	return os.IsNotExist(err)
+// getDBPath returns the SQLite database path, preferring DB_PATH and then
+// the Kubernetes PVC mount over the local data/ directory
+func getDBPath() string {
+	if dbPath := config.GetEnv("DB_PATH", ""); dbPath != "" {
+		return dbPath
+	}
+
+	// Use /app/data for Kubernetes PVC, local data/ for development
+	if _, err := os.Stat("/app/data"); err == nil {
+		return "/app/data/homelab.db"
+	}
+	return "data/homelab.db"
+}
+
+// sessionStore maps the auth.sessions config value to a session store
+func sessionStore(kind string, database *db.DB) (middleware.SessionStore, error) {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "", "sqlite":
+		return database, nil
+	case "memory":
+		return middleware.NewMemorySessionStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown store %q (want sqlite or memory)", kind)
+	}
+}
+
+// bootstrapAdmin creates an admin from ADMIN_USER and ADMIN_PASS when the
+// database has no users yet
+func bootstrapAdmin(database *db.DB, username, password string) error {
+	hash, err := middleware.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	created, err := database.BootstrapAdmin(username, hash)
+	if err != nil {
+		return err
+	}
+	if created {
+		log.Printf("Created admin user %s from ADMIN_USER and ADMIN_PASS", username)
+		if password == "changeme" {
+			log.Printf("WARNING: the admin password is the default; set ADMIN_PASS or change it from the admin")
+		}
+	}
+	return nil
+}
+
+// getOGCacheDir returns where social preview images are cached: OG_CACHE_DIR,
+// or og-cache next to the database
+func getOGCacheDir(dbPath string) string {
+	return config.GetEnv("OG_CACHE_DIR", filepath.Join(filepath.Dir(dbPath), "og-cache"))
+}
+
+// pruneOGImages prunes the social image cache at startup and then hourly
+// until ctx is done
+func pruneOGImages(ctx context.Context, app *handlers.App) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if removed, err := app.PruneOGImages(); err != nil {
+			log.Printf("Error pruning social images: %v", err)
+		} else if removed > 0 {
+			log.Printf("Pruned %d stale social images", removed)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// parseTemplates parses the embedded page templates
+func parseTemplates() (*template.Template, error) {
+	return template.New("").Funcs(handlers.TemplateFuncs).ParseFS(embedFS, "web/templates/*.html")
 }
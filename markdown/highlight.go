@@ -0,0 +1,201 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// fenceInfo is the parsed info string of a fenced code block, e.g. "go {3-5} linenos"
+type fenceInfo struct {
+	Language    string
+	LineNumbers bool
+	Highlight   [][2]int
+}
+
+// parseFenceInfo splits a fence info string into the language and options.
+// Supported options are a brace-delimited list of lines or ranges to
+// highlight ({3-5}, {1,4,7-9}) and the word "linenos" to number lines.
+// Unknown options are ignored.
+func parseFenceInfo(info string) fenceInfo {
+	var fi fenceInfo
+
+	rest := strings.TrimSpace(info)
+	if rest != "" && rest[0] != '{' {
+		end := strings.IndexAny(rest, " \t{")
+		if end == -1 {
+			end = len(rest)
+		}
+		fi.Language = strings.ToLower(rest[:end])
+		rest = rest[end:]
+	}
+
+	for {
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			break
+		}
+
+		if rest[0] == '{' {
+			end := strings.IndexByte(rest, '}')
+			if end == -1 {
+				break
+			}
+			fi.Highlight = append(fi.Highlight, parseLineRanges(rest[1:end])...)
+			rest = rest[end+1:]
+			continue
+		}
+
+		end := strings.IndexAny(rest, " \t{")
+		if end == -1 {
+			end = len(rest)
+		}
+		if word := rest[:end]; word == "linenos" {
+			fi.LineNumbers = true
+		}
+		rest = rest[end:]
+	}
+
+	return fi
+}
+
+// parseLineRanges parses "1,3-5" into [[1 1] [3 5]], skipping malformed entries
+func parseLineRanges(spec string) [][2]int {
+	var ranges [][2]int
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil || start < 1 {
+			continue
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(strings.TrimSpace(to))
+			if err != nil || end < start {
+				continue
+			}
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges
+}
+
+// highlightStyle is required by the formatter, but with class-based output
+// the colours come from web/static/css/highlight.css instead
+var highlightStyle = styles.Get("github")
+
+// codeBlockRenderer renders fenced code blocks with chroma, using CSS classes
+// rather than inline styles so the light and dark themes can share markup
+type codeBlockRenderer struct{}
+
+func (r *codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+func (r *codeBlockRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*ast.FencedCodeBlock)
+	var info fenceInfo
+	if n.Info != nil {
+		info = parseFenceInfo(string(n.Info.Segment.Value(source)))
+	}
+
+	var code bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+
+	// Fences without a language or options keep the plain CommonMark output
+	if info.Language == "" && !info.LineNumbers && len(info.Highlight) == 0 {
+		_, _ = w.WriteString("<pre><code>")
+		_, _ = w.Write(util.EscapeHTML(code.Bytes()))
+		_, _ = w.WriteString("</code></pre>\n")
+		return ast.WalkSkipChildren, nil
+	}
+
+	if err := highlightCode(w, code.String(), info); err != nil {
+		// Fall back to an escaped, unhighlighted block rather than dropping the code
+		log.Printf("Error highlighting %q code block: %v", info.Language, err)
+		_, _ = w.WriteString(plainCodeBlock(code.String(), info.Language))
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// highlightCode writes code as a <pre class="chroma"> block with class-based token spans
+func highlightCode(w util.BufWriter, code string, info fenceInfo) error {
+	lexer := lexers.Get(info.Language)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, code)
+	if err != nil {
+		return err
+	}
+
+	formatter := chromahtml.New(
+		chromahtml.WithClasses(true),
+		chromahtml.WithLineNumbers(info.LineNumbers),
+		chromahtml.HighlightLines(info.Highlight),
+		chromahtml.WithPreWrapper(codeWrapper{language: info.Language}),
+	)
+
+	var buf bytes.Buffer
+	if err := formatter.Format(&buf, highlightStyle, iterator); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// codeWrapper keeps the language-xxx class on <code> that the plain
+// CommonMark renderer emits, so existing selectors and scripts still match
+type codeWrapper struct {
+	language string
+}
+
+func (c codeWrapper) Start(code bool, styleAttr string) string {
+	if !code {
+		return fmt.Sprintf("<pre%s>", styleAttr)
+	}
+	if c.language == "" {
+		return fmt.Sprintf("<pre%s><code>", styleAttr)
+	}
+	return fmt.Sprintf(`<pre%s><code class="language-%s">`, styleAttr, html.EscapeString(c.language))
+}
+
+func (c codeWrapper) End(code bool) string {
+	if code {
+		return "</code></pre>"
+	}
+	return "</pre>"
+}
+
+func plainCodeBlock(code, language string) string {
+	return codeWrapper{language: language}.Start(true, ` class="chroma"`) +
+		html.EscapeString(code) +
+		codeWrapper{}.End(true) + "\n"
+}
@@ -0,0 +1,92 @@
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFenceInfo(t *testing.T) {
+	tests := []struct {
+		info string
+		want fenceInfo
+	}{
+		{"", fenceInfo{}},
+		{"go", fenceInfo{Language: "go"}},
+		{"YAML", fenceInfo{Language: "yaml"}},
+		{"go {3-5}", fenceInfo{Language: "go", Highlight: [][2]int{{3, 5}}}},
+		{"go{3-5}", fenceInfo{Language: "go", Highlight: [][2]int{{3, 5}}}},
+		{"bash {1,4-6} linenos", fenceInfo{Language: "bash", LineNumbers: true, Highlight: [][2]int{{1, 1}, {4, 6}}}},
+		{"yaml linenos", fenceInfo{Language: "yaml", LineNumbers: true}},
+		{"{2}", fenceInfo{Highlight: [][2]int{{2, 2}}}},
+		{"go {x,0,5-3,7}", fenceInfo{Language: "go", Highlight: [][2]int{{7, 7}}}},
+		{"go {3-5", fenceInfo{Language: "go"}},
+		{"go title=main.go", fenceInfo{Language: "go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.info, func(t *testing.T) {
+			got := parseFenceInfo(tt.info)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFenceInfo(%q) = %+v, want %+v", tt.info, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderHighlightsFencedCode(t *testing.T) {
+	got := renderMarkdown("```go\nfunc main() {}\n```\n")
+
+	for _, want := range []string{
+		`<pre class="chroma"><code class="language-go">`,
+		`<span class="kd">func</span>`,
+		`<span class="nf">main</span>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "style=") {
+		t.Errorf("expected class-based spans, got inline styles:\n%s", got)
+	}
+}
+
+func TestRenderHighlightedLines(t *testing.T) {
+	got := renderMarkdown("```yaml {2-3}\na: 1\nb: 2\nc: 3\nd: 4\n```\n")
+
+	if n := strings.Count(got, `<span class="line hl">`); n != 2 {
+		t.Errorf("expected 2 highlighted lines, got %d:\n%s", n, got)
+	}
+	if n := strings.Count(got, `<span class="line">`); n != 2 {
+		t.Errorf("expected 2 plain lines, got %d:\n%s", n, got)
+	}
+}
+
+func TestRenderLineNumbers(t *testing.T) {
+	got := renderMarkdown("```sh linenos\necho one\necho two\n```\n")
+
+	for _, want := range []string{`<span class="ln">1</span>`, `<span class="ln">2</span>`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRenderUnknownLanguageIsEscaped(t *testing.T) {
+	got := renderMarkdown("```not-a-language\n<script>alert(1)</script>\n```\n")
+
+	if strings.Contains(got, "<script>") {
+		t.Errorf("code was not escaped:\n%s", got)
+	}
+	if !strings.Contains(got, `<code class="language-not-a-language">`) {
+		t.Errorf("expected language class on <code>:\n%s", got)
+	}
+}
+
+func TestRenderLanguageClassIsEscaped(t *testing.T) {
+	got := renderMarkdown("```\"><img\nx\n```\n")
+
+	if strings.Contains(got, "<img") {
+		t.Errorf("info string was not escaped:\n%s", got)
+	}
+}
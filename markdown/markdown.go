@@ -1,130 +1,77 @@
 package markdown
 
 import (
+	"bytes"
 	"html/template"
-	"strings"
-)
-
-func Render(content string) template.HTML {
-	//nolint:gosec // Intentional: rendering user markdown content as HTML
-	return template.HTML(renderMarkdown(content))
-}
-
-func renderMarkdown(content string) string {
-	// Simple markdown rendering - headers, bold, lists, code blocks
-	lines := strings.Split(content, "\n")
-	var html strings.Builder
-	inCodeBlock := false
-	inList := false
-
-	for i, line := range lines {
-		// Code blocks
-		if strings.HasPrefix(line, "```") {
-			if inCodeBlock {
-				html.WriteString("</code></pre>")
-				inCodeBlock = false
-			} else {
-				html.WriteString("<pre><code>")
-				inCodeBlock = true
-			}
-			continue
-		}
-
-		if inCodeBlock {
-			html.WriteString(template.HTMLEscapeString(line) + "\n")
-			continue
-		}
+	"log"
 
-		// Headers
-		if strings.HasPrefix(line, "## ") {
-			if inList {
-				html.WriteString("</ul>")
-				inList = false
-			}
-			html.WriteString("<h2>" + template.HTMLEscapeString(strings.TrimPrefix(line, "## ")) + "</h2>")
-			continue
-		}
-		if strings.HasPrefix(line, "### ") {
-			if inList {
-				html.WriteString("</ul>")
-				inList = false
-			}
-			html.WriteString("<h3>" + template.HTMLEscapeString(strings.TrimPrefix(line, "### ")) + "</h3>")
-			continue
-		}
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
 
-		// Lists
-		if strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* ") {
-			if !inList {
-				html.WriteString("<ul>")
-				inList = true
-			}
-			text := strings.TrimPrefix(strings.TrimPrefix(line, "- "), "* ")
-			text = processBold(text)
-			html.WriteString("<li>" + text + "</li>")
-			continue
-		}
+// md is a CommonMark parser with the GitHub-flavored extensions:
+// tables, task lists, strikethrough and autolinks. Fenced code blocks are
+// syntax highlighted server-side (see highlight.go) and headings get slug IDs
+// with permalink anchors when rendered through RenderDocument (see toc.go).
+//
+// Raw HTML in the source is not passed through (goldmark replaces it with a
+// comment) and unsafe link destinations such as javascript: are dropped, so the
+// output is safe to hand to templates as template.HTML.
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(
+		parser.WithASTTransformers(util.Prioritized(&headingIDTransformer{}, 100)),
+	),
+	goldmark.WithRendererOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&codeBlockRenderer{}, 200),
+			util.Prioritized(&headingRenderer{}, 200),
+		),
+	),
+)
 
-		// Numbered lists
-		if len(line) > 2 && line[0] >= '0' && line[0] <= '9' && line[1] == '.' {
-			if !inList {
-				html.WriteString("<ol>")
-				inList = true
-			}
-			parts := strings.SplitN(line, ". ", 2)
-			if len(parts) == 2 {
-				text := processBold(parts[1])
-				html.WriteString("<li>" + text + "</li>")
-			}
-			continue
-		}
+// Document is rendered markdown together with its table of contents
+type Document struct {
+	HTML template.HTML
+	TOC  []*Heading
+}
 
-		// End list if we hit a non-list line
-		if inList && line != "" && !strings.HasPrefix(line, "- ") && !strings.HasPrefix(line, "* ") {
-			html.WriteString("</ul>")
-			inList = false
-		}
+// Render converts markdown to HTML with heading anchors
+func Render(content string) template.HTML {
+	return RenderDocument(content).HTML
+}
 
-		// Empty lines
-		if strings.TrimSpace(line) == "" {
-			if i > 0 && i < len(lines)-1 {
-				html.WriteString("<br>")
-			}
-			continue
-		}
+// RenderDocument converts markdown to HTML, giving every heading a slug ID
+// that is unique within the document, and returns the nested heading tree
+func RenderDocument(content string) Document {
+	var headings []*Heading
+	pc := parser.NewContext()
+	pc.Set(headingsKey, &headings)
 
-		// Regular paragraphs
-		if !inList {
-			text := processBold(line)
-			html.WriteString("<p>" + text + "</p>")
-		}
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(content), &buf, parser.WithContext(pc)); err != nil {
+		log.Printf("Error rendering markdown: %v", err)
+		//nolint:gosec // Escaped source text
+		return Document{HTML: template.HTML(template.HTMLEscapeString(content))}
 	}
 
-	if inList {
-		html.WriteString("</ul>")
-	}
-	if inCodeBlock {
-		html.WriteString("</code></pre>")
+	return Document{
+		//nolint:gosec // Output is sanitized by goldmark: raw HTML and unsafe URLs are dropped
+		HTML: template.HTML(buf.String()),
+		TOC:  buildTOC(headings),
 	}
-
-	return html.String()
 }
 
-func processBold(text string) string {
-	// First escape the text
-	escaped := template.HTMLEscapeString(text)
-	// Then handle **bold** text
-	for strings.Contains(escaped, "**") {
-		first := strings.Index(escaped, "**")
-		if first == -1 {
-			break
-		}
-		second := strings.Index(escaped[first+2:], "**")
-		if second == -1 {
-			break
-		}
-		second += first + 2
-		escaped = escaped[:first] + "<strong>" + escaped[first+2:second] + "</strong>" + escaped[second+2:]
+// renderMarkdown converts markdown to HTML without heading IDs, matching the
+// CommonMark spec output
+func renderMarkdown(content string) string {
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(content), &buf); err != nil {
+		log.Printf("Error rendering markdown: %v", err)
+		return template.HTMLEscapeString(content)
 	}
-	return escaped
+	return buf.String()
 }
@@ -0,0 +1,157 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+// specExample is a single input/output pair taken from the CommonMark 0.31
+// or GitHub Flavored Markdown 0.29 specifications
+type specExample struct {
+	section string
+	example int
+	input   string
+	want    string
+}
+
+var commonMarkExamples = []specExample{
+	{"Tabs", 1, "\tfoo\tbaz\t\tbim\n", "<pre><code>foo\tbaz\t\tbim\n</code></pre>\n"},
+	{"Backslash escapes", 12, "\\*not emphasized*\n", "<p>*not emphasized*</p>\n"},
+	{"Entity references", 25, "&nbsp; &amp; &copy;\n", "<p>  &amp; ©</p>\n"},
+	{"Thematic breaks", 43, "***\n---\n___\n", "<hr>\n<hr>\n<hr>\n"},
+	{"ATX headings", 62, "# foo\n## foo\n### foo\n#### foo\n##### foo\n###### foo\n",
+		"<h1>foo</h1>\n<h2>foo</h2>\n<h3>foo</h3>\n<h4>foo</h4>\n<h5>foo</h5>\n<h6>foo</h6>\n"},
+	{"ATX headings", 63, "####### foo\n", "<p>####### foo</p>\n"},
+	{"ATX headings", 64, "#5 bolt\n\n#hashtag\n", "<p>#5 bolt</p>\n<p>#hashtag</p>\n"},
+	{"Setext headings", 80, "Foo *bar*\n=========\n\nFoo *bar*\n---------\n",
+		"<h1>Foo <em>bar</em></h1>\n<h2>Foo <em>bar</em></h2>\n"},
+	{"Indented code blocks", 107, "    a simple\n      indented code block\n",
+		"<pre><code>a simple\n  indented code block\n</code></pre>\n"},
+	{"Fenced code blocks", 119, "```\n<\n >\n```\n", "<pre><code>&lt;\n &gt;\n</code></pre>\n"},
+	{"Fenced code blocks", 120, "~~~\n<\n >\n~~~\n", "<pre><code>&lt;\n &gt;\n</code></pre>\n"},
+	{"Link reference definitions", 192, "[foo]: /url \"title\"\n\n[foo]\n", "<p><a href=\"/url\" title=\"title\">foo</a></p>\n"},
+	{"Paragraphs", 219, "aaa\n\nbbb\n", "<p>aaa</p>\n<p>bbb</p>\n"},
+	{"Block quotes", 228, "> # Foo\n> bar\n> baz\n", "<blockquote>\n<h1>Foo</h1>\n<p>bar\nbaz</p>\n</blockquote>\n"},
+	{"Block quotes", 232, "> foo\n---\n", "<blockquote>\n<p>foo</p>\n</blockquote>\n<hr>\n"},
+	{"List items", 253, "1.  A paragraph\n    with two lines.\n\n        indented code\n\n    > A block quote.\n",
+		"<ol>\n<li>\n<p>A paragraph\nwith two lines.</p>\n<pre><code>indented code\n</code></pre>\n<blockquote>\n<p>A block quote.</p>\n</blockquote>\n</li>\n</ol>\n"},
+	{"List items", 265, "123456789. ok\n", "<ol start=\"123456789\">\n<li>ok</li>\n</ol>\n"},
+	{"List items", 294, "- foo\n  - bar\n    - baz\n      - boo\n",
+		"<ul>\n<li>foo\n<ul>\n<li>bar\n<ul>\n<li>baz\n<ul>\n<li>boo</li>\n</ul>\n</li>\n</ul>\n</li>\n</ul>\n</li>\n</ul>\n"},
+	{"List items", 298, "10) foo\n    - bar\n", "<ol start=\"10\">\n<li>foo\n<ul>\n<li>bar</li>\n</ul>\n</li>\n</ol>\n"},
+	{"Lists", 301, "- foo\n- bar\n+ baz\n", "<ul>\n<li>foo</li>\n<li>bar</li>\n</ul>\n<ul>\n<li>baz</li>\n</ul>\n"},
+	{"Lists", 306, "- foo\n\n- bar\n\n\n- baz\n", "<ul>\n<li>\n<p>foo</p>\n</li>\n<li>\n<p>bar</p>\n</li>\n<li>\n<p>baz</p>\n</li>\n</ul>\n"},
+	{"Code spans", 328, "`foo`\n", "<p><code>foo</code></p>\n"},
+	{"Code spans", 329, "`` foo ` bar ``\n", "<p><code>foo ` bar</code></p>\n"},
+	{"Emphasis", 350, "*foo bar*\n", "<p><em>foo bar</em></p>\n"},
+	{"Emphasis", 351, "a * foo bar*\n", "<p>a * foo bar*</p>\n"},
+	{"Emphasis", 359, "_foo bar_\n", "<p><em>foo bar</em></p>\n"},
+	{"Emphasis", 378, "**foo bar**\n", "<p><strong>foo bar</strong></p>\n"},
+	{"Emphasis", 413, "***foo** bar*\n", "<p><em><strong>foo</strong> bar</em></p>\n"},
+	{"Links", 482, "[link](/uri \"title\")\n", "<p><a href=\"/uri\" title=\"title\">link</a></p>\n"},
+	{"Links", 483, "[link](/uri)\n", "<p><a href=\"/uri\">link</a></p>\n"},
+	{"Links", 486, "[link](<>)\n", "<p><a href=\"\">link</a></p>\n"},
+	{"Links", 509, "[link](foo%20b&auml;)\n", "<p><a href=\"foo%20b%C3%A4\">link</a></p>\n"},
+	{"Links", 519, "[link [foo [bar]]](/uri)\n", "<p><a href=\"/uri\">link [foo [bar]]</a></p>\n"},
+	{"Images", 572, "![foo](/url \"title\")\n", "<p><img src=\"/url\" alt=\"foo\" title=\"title\"></p>\n"},
+	{"Images", 573, "![foo *bar*]\n\n[foo *bar*]: train.jpg \"train & tracks\"\n",
+		"<p><img src=\"train.jpg\" alt=\"foo bar\" title=\"train &amp; tracks\"></p>\n"},
+	{"Autolinks", 594, "<http://foo.bar.baz>\n", "<p><a href=\"http://foo.bar.baz\">http://foo.bar.baz</a></p>\n"},
+	{"Autolinks", 602, "<MAILTO:FOO@BAR.BAZ>\n", "<p><a href=\"MAILTO:FOO@BAR.BAZ\">MAILTO:FOO@BAR.BAZ</a></p>\n"},
+	{"Hard line breaks", 633, "foo  \nbaz\n", "<p>foo<br>\nbaz</p>\n"},
+	{"Hard line breaks", 634, "foo\\\nbaz\n", "<p>foo<br>\nbaz</p>\n"},
+	{"Soft line breaks", 648, "foo\nbaz\n", "<p>foo\nbaz</p>\n"},
+}
+
+var gfmExamples = []specExample{
+	{"Tables", 198, "| foo | bar |\n| --- | --- |\n| baz | bim |\n",
+		"<table>\n<thead>\n<tr>\n<th>foo</th>\n<th>bar</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>baz</td>\n<td>bim</td>\n</tr>\n</tbody>\n</table>\n"},
+	{"Tables", 199, "| abc | defghi |\n:-: | -----------:\nbar | baz\n",
+		"<table>\n<thead>\n<tr>\n<th style=\"text-align:center\">abc</th>\n<th style=\"text-align:right\">defghi</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td style=\"text-align:center\">bar</td>\n<td style=\"text-align:right\">baz</td>\n</tr>\n</tbody>\n</table>\n"},
+	{"Tables", 205, "| abc | def |\n| --- | --- |\n",
+		"<table>\n<thead>\n<tr>\n<th>abc</th>\n<th>def</th>\n</tr>\n</thead>\n</table>\n"},
+	{"Task list items", 279, "- [ ] foo\n- [x] bar\n",
+		"<ul>\n<li><input disabled=\"\" type=\"checkbox\"> foo</li>\n<li><input checked=\"\" disabled=\"\" type=\"checkbox\"> bar</li>\n</ul>\n"},
+	{"Strikethrough", 491, "~~Hi~~ Hello, world!\n", "<p><del>Hi</del> Hello, world!</p>\n"},
+	{"Strikethrough", 492, "This ~~has a\n\nnew paragraph~~.\n", "<p>This ~~has a</p>\n<p>new paragraph~~.</p>\n"},
+	{"Autolinks (extension)", 621, "www.commonmark.org\n", "<p><a href=\"http://www.commonmark.org\">www.commonmark.org</a></p>\n"},
+	{"Autolinks (extension)", 625, "Visit www.commonmark.org.\n\nVisit www.commonmark.org/a.b.\n",
+		"<p>Visit <a href=\"http://www.commonmark.org\">www.commonmark.org</a>.</p>\n<p>Visit <a href=\"http://www.commonmark.org/a.b\">www.commonmark.org/a.b</a>.</p>\n"},
+	{"Autolinks (extension)", 631, "http://commonmark.org\n\n(Visit https://encrypted.google.com/search?q=Markup+(business))\n",
+		"<p><a href=\"http://commonmark.org\">http://commonmark.org</a></p>\n<p>(Visit <a href=\"https://encrypted.google.com/search?q=Markup+(business)\">https://encrypted.google.com/search?q=Markup+(business)</a>)</p>\n"},
+	{"Autolinks (extension)", 633, "foo@bar.baz\n", "<p><a href=\"mailto:foo@bar.baz\">foo@bar.baz</a></p>\n"},
+}
+
+func runSpecExamples(t *testing.T, examples []specExample) {
+	t.Helper()
+	for _, ex := range examples {
+		t.Run(ex.section, func(t *testing.T) {
+			got := renderMarkdown(ex.input)
+			if got != ex.want {
+				t.Errorf("example %d (%s)\ninput: %q\n got: %q\nwant: %q", ex.example, ex.section, ex.input, got, ex.want)
+			}
+		})
+	}
+}
+
+func TestCommonMarkSpec(t *testing.T) {
+	runSpecExamples(t, commonMarkExamples)
+}
+
+func TestGFMSpec(t *testing.T) {
+	runSpecExamples(t, gfmExamples)
+}
+
+func TestRenderIsSafe(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		forbidden []string
+	}{
+		{
+			name:      "script block",
+			input:     "<script>alert('xss')</script>",
+			forbidden: []string{"<script"},
+		},
+		{
+			name:      "inline html event handler",
+			input:     "hello <img src=x onerror=alert(1)>",
+			forbidden: []string{"<img", "onerror"},
+		},
+		{
+			name:      "javascript link",
+			input:     "[click](javascript:alert(1))",
+			forbidden: []string{"javascript:"},
+		},
+		{
+			name:      "vbscript image",
+			input:     "![x](vbscript:msgbox)",
+			forbidden: []string{"vbscript:"},
+		},
+		{
+			name:      "html in code span is escaped",
+			input:     "`<b>bold</b>`",
+			forbidden: []string{"<b>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(Render(tt.input))
+			for _, f := range tt.forbidden {
+				if strings.Contains(strings.ToLower(got), f) {
+					t.Errorf("Render(%q) = %q, must not contain %q", tt.input, got, f)
+				}
+			}
+		})
+	}
+}
+
+// TestRenderOrderedList guards against the old renderer closing <ol> with </ul>
+func TestRenderOrderedList(t *testing.T) {
+	got := renderMarkdown("1. one\n2. two\n\nafter\n")
+	want := "<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n<p>after</p>\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
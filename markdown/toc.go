@@ -0,0 +1,166 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Heading is an entry in a document's table of contents.
+// Children holds the headings nested under it, e.g. h3s under an h2.
+type Heading struct {
+	Level    int        `json:"level"`
+	Text     string     `json:"text"`
+	ID       string     `json:"id"`
+	Children []*Heading `json:"children,omitempty"`
+}
+
+// headingsKey stores the headings collected while parsing. Heading IDs and
+// anchors are only added when it is present in the parser context, which
+// keeps renderMarkdown's output identical to the CommonMark spec.
+var headingsKey = parser.NewContextKey()
+
+// headingIDTransformer assigns a unique slug ID to every heading and records
+// it in the parser context for building the table of contents
+type headingIDTransformer struct{}
+
+func (t *headingIDTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	collected, ok := pc.Get(headingsKey).(*[]*Heading)
+	if !ok {
+		return
+	}
+
+	source := reader.Source()
+	used := make(map[string]bool)
+	_ = ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		heading, ok := node.(*ast.Heading)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+
+		title := strings.TrimSpace(nodeText(heading, source))
+		id := uniqueSlug(Slugify(title), used)
+		heading.SetAttributeString("id", []byte(id))
+		*collected = append(*collected, &Heading{Level: heading.Level, Text: title, ID: id})
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// nodeText returns the plain text of an inline subtree, dropping markup
+func nodeText(node ast.Node, source []byte) string {
+	var b strings.Builder
+	for c := node.FirstChild(); c != nil; c = c.NextSibling() {
+		switch n := c.(type) {
+		case *ast.Text:
+			b.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(n.Value)
+		case *ast.CodeSpan:
+			for t := n.FirstChild(); t != nil; t = t.NextSibling() {
+				if seg, ok := t.(*ast.Text); ok {
+					b.Write(seg.Segment.Value(source))
+				}
+			}
+		default:
+			b.WriteString(nodeText(c, source))
+		}
+	}
+	return b.String()
+}
+
+// Slugify turns heading text into a URL fragment the way GitHub does:
+// lowercase, spaces become hyphens and punctuation is dropped
+func Slugify(title string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+// uniqueSlug suffixes slug with -1, -2, ... until it is unused and marks it used
+func uniqueSlug(slug string, used map[string]bool) string {
+	if slug == "" {
+		slug = "section"
+	}
+
+	id := slug
+	for i := 1; used[id]; i++ {
+		id = fmt.Sprintf("%s-%d", slug, i)
+	}
+	used[id] = true
+	return id
+}
+
+// buildTOC nests a flat, document-ordered list of headings by level.
+// A heading becomes a child of the closest preceding heading with a lower level.
+func buildTOC(headings []*Heading) []*Heading {
+	var roots []*Heading
+	var stack []*Heading
+
+	for _, h := range headings {
+		for len(stack) > 0 && stack[len(stack)-1].Level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, h)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, h)
+		}
+		stack = append(stack, h)
+	}
+
+	return roots
+}
+
+// headingRenderer renders headings like goldmark's default renderer and, when
+// the heading has an ID, appends a permalink anchor shown on hover
+type headingRenderer struct{}
+
+func (r *headingRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindHeading, r.renderHeading)
+}
+
+func (r *headingRenderer) renderHeading(w util.BufWriter, _ []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.Heading)
+	tag := fmt.Sprintf("h%d", n.Level)
+
+	if entering {
+		_, _ = w.WriteString("<" + tag)
+		if n.Attributes() != nil {
+			html.RenderAttributes(w, n, html.HeadingAttributeFilter)
+		}
+		_ = w.WriteByte('>')
+		return ast.WalkContinue, nil
+	}
+
+	if id, ok := n.AttributeString("id"); ok {
+		if idBytes, ok := id.([]byte); ok {
+			escaped := util.EscapeHTML(idBytes)
+			_, _ = w.WriteString(`<a class="heading-anchor" href="#`)
+			_, _ = w.Write(escaped)
+			_, _ = w.WriteString(`" aria-label="Permalink to this section">#</a>`)
+		}
+	}
+	_, _ = w.WriteString("</" + tag + ">\n")
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,107 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Getting Started", "getting-started"},
+		{"Why K8s?", "why-k8s"},
+		{"Step 1: Install `kubectl`", "step-1-install-kubectl"},
+		{"noVNC + x11vnc", "novnc--x11vnc"},
+		{"snake_case and-dashes", "snake_case-and-dashes"},
+		{"Überblick", "überblick"},
+		{"!!!", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Slugify(tt.title); got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestRenderDocumentHeadingIDs(t *testing.T) {
+	doc := RenderDocument("## Setup\n\n### Install *the* CLI\n")
+	got := string(doc.HTML)
+
+	for _, want := range []string{
+		`<h2 id="setup">Setup<a class="heading-anchor" href="#setup" aria-label="Permalink to this section">#</a></h2>`,
+		`<h3 id="install-the-cli">Install <em>the</em> CLI<a class="heading-anchor" href="#install-the-cli"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRenderDocumentUniqueIDs(t *testing.T) {
+	doc := RenderDocument("## Usage\n\n## Usage\n\n## Usage-1\n\n## ???\n\n## !!!\n")
+
+	var ids []string
+	for _, h := range doc.TOC {
+		ids = append(ids, h.ID)
+	}
+	want := []string{"usage", "usage-1", "usage-1-1", "section", "section-1"}
+	if strings.Join(ids, " ") != strings.Join(want, " ") {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+}
+
+func TestRenderDocumentTOCTree(t *testing.T) {
+	doc := RenderDocument(`## Overview
+
+### Goals
+
+### Non-goals
+
+## Setup
+
+#### Deep detail
+
+### Install
+
+## Wrap up
+`)
+
+	if len(doc.TOC) != 3 {
+		t.Fatalf("expected 3 top-level headings, got %d", len(doc.TOC))
+	}
+
+	overview, setup, wrap := doc.TOC[0], doc.TOC[1], doc.TOC[2]
+	if overview.Text != "Overview" || len(overview.Children) != 2 {
+		t.Errorf("overview = %+v, want 2 children", overview)
+	}
+	if overview.Children[1].Text != "Non-goals" || overview.Children[1].Level != 3 {
+		t.Errorf("second overview child = %+v", overview.Children[1])
+	}
+	if len(setup.Children) != 2 || setup.Children[0].Level != 4 || setup.Children[1].Text != "Install" {
+		t.Errorf("setup children = %+v", setup.Children)
+	}
+	if wrap.ID != "wrap-up" || len(wrap.Children) != 0 {
+		t.Errorf("wrap up = %+v", wrap)
+	}
+}
+
+func TestRenderDocumentHeadingIsEscaped(t *testing.T) {
+	doc := RenderDocument("## a <script>alert(1)</script> \"quoted\"\n")
+
+	if strings.Contains(string(doc.HTML), "<script>") {
+		t.Errorf("heading was not escaped: %s", doc.HTML)
+	}
+	if doc.TOC[0].ID != "a-alert1-quoted" {
+		t.Errorf("id = %q", doc.TOC[0].ID)
+	}
+}
+
+func TestRenderMarkdownHasNoHeadingIDs(t *testing.T) {
+	got := renderMarkdown("## Plain\n")
+	if got != "<h2>Plain</h2>\n" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -2,87 +2,383 @@ package middleware
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"log"
+	"net"
 	"net/http"
-	"sync"
+	"strings"
 	"time"
 
+	"github.com/tinotenda-alfaneti/homelabsite/models"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// UserStore looks up the accounts that can sign in
+type UserStore interface {
+	GetUserByID(id int64) (*models.User, error)
+	GetUserByUsername(username string) (*models.User, error)
+}
+
+// TokenStore looks up the API tokens that scripts send as
+// "Authorization: Bearer", by the hash of the token
+type TokenStore interface {
+	GetAPITokenByHash(tokenHash string) (*models.APIToken, error)
+	TouchAPIToken(id int64, now time.Time) error
+}
+
+// sessionTTL is how long a session lasts without being used
+const sessionTTL = 24 * time.Hour
+
+// touchInterval is how often a session's last-seen time is written, so
+// browsing the admin doesn't write to the store on every request
+const touchInterval = time.Minute
+
 type AuthMiddleware struct {
-	sessions     map[string]time.Time
-	sessionMu    sync.RWMutex
-	adminUser    string
-	passwordHash string
+	users    UserStore
+	sessions SessionStore
+	tokens   TokenStore
+	// dummyHash is compared against for unknown usernames, so a failed
+	// sign-in takes as long whether or not the user exists
+	dummyHash []byte
 }
 
-func NewAuthMiddleware(adminUser, adminPass string) *AuthMiddleware {
-	// Hash the password on initialization
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(adminPass), bcrypt.DefaultCost)
+// NewAuthMiddleware signs users in with sessions from the store. tokens may be
+// nil, in which case API tokens are refused.
+func NewAuthMiddleware(users UserStore, sessions SessionStore, tokens TokenStore) *AuthMiddleware {
+	dummyHash, err := HashPassword(generateSessionToken())
 	if err != nil {
-		panic("Failed to hash admin password: " + err.Error())
+		panic("Failed to hash password: " + err.Error())
 	}
 
 	am := &AuthMiddleware{
-		sessions:     make(map[string]time.Time),
-		adminUser:    adminUser,
-		passwordHash: string(hashedPassword),
+		users:     users,
+		sessions:  sessions,
+		tokens:    tokens,
+		dummyHash: []byte(dummyHash),
 	}
 	go am.cleanupSessions()
 	return am
 }
 
+// HashPassword returns the bcrypt hash stored for a password
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// GenerateToken returns a random URL-safe token for invite links
+func GenerateToken() string {
+	return generateSessionToken()
+}
+
+// HashToken returns the hex SHA-256 of a token, which is what's stored in
+// place of session and invite tokens
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RequireAuth only lets signed-in users through. Anonymous users are sent to
+// the login page, or get a JSON 401 on API routes.
 func (am *AuthMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return am.requireSession(next)
+}
+
+// RequireRole returns a wrapper that only lets signed-in users with one of
+// the roles through. Admins pass every role check; other users get a 403.
+func (am *AuthMiddleware) RequireRole(roles ...models.Role) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return am.requireSession(next, roles...)
+	}
+}
+
+// RequireScope returns a wrapper for API routes that scripts may call with
+// an API token. A token needs the scope, and its user still needs the role
+// the scope calls for; signed-in users just need the role.
+func (am *AuthMiddleware) RequireScope(scope models.Scope) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := bearerToken(r); !ok {
+				am.requireSession(next, scope.Role())(w, r)
+				return
+			}
+
+			t, user := am.apiToken(r)
+			if t == nil {
+				deny(w, r, http.StatusUnauthorized, "Invalid or expired API token")
+				return
+			}
+			if !t.HasScope(scope) {
+				deny(w, r, http.StatusForbidden, "API token doesn't have the "+string(scope)+" scope")
+				return
+			}
+			if !user.HasRole(scope.Role()) {
+				deny(w, r, http.StatusForbidden, "Forbidden")
+				return
+			}
+
+			am.touchToken(t)
+			next(w, r)
+		}
+	}
+}
+
+// requireSession lets signed-in users with one of the roles through, or any
+// signed-in user when no roles are given. API tokens are refused, as the
+// routes behind it aren't meant for scripts.
+func (am *AuthMiddleware) requireSession(next http.HandlerFunc, roles ...models.Role) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("session_token")
-		if err != nil {
-			http.Redirect(w, r, "/admin/login", http.StatusFound)
+		if _, ok := bearerToken(r); ok {
+			deny(w, r, http.StatusForbidden, "API tokens can't be used here")
+			return
+		}
+		user := am.sessionUser(r)
+		if user == nil {
+			deny(w, r, http.StatusUnauthorized, "Sign in required")
+			return
+		}
+		if len(roles) > 0 && !user.HasRole(roles...) {
+			deny(w, r, http.StatusForbidden, "Forbidden")
 			return
 		}
 
-		am.sessionMu.RLock()
-		expiry, exists := am.sessions[cookie.Value]
-		am.sessionMu.RUnlock()
+		am.extendSession(r)
+		next(w, r)
+	}
+}
 
-		if !exists || time.Now().After(expiry) {
+// deny refuses a request. API routes get a JSON error with the status, so
+// scripts aren't handed the login page; elsewhere anonymous users are sent
+// to sign in.
+func deny(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		if status == http.StatusUnauthorized {
 			http.Redirect(w, r, "/admin/login", http.StatusFound)
-			return
+		} else {
+			http.Error(w, message, status)
 		}
+		return
+	}
 
-		// Extend session
-		am.sessionMu.Lock()
-		am.sessions[cookie.Value] = time.Now().Add(24 * time.Hour)
-		am.sessionMu.Unlock()
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"error":   message,
+	}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
 
-		next(w, r)
+// bearerToken returns the token of the request's "Authorization: Bearer"
+// header, and whether it has one
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", false
 	}
+	return strings.TrimSpace(token), true
 }
 
-func (am *AuthMiddleware) ValidateCredentials(username, password string) bool {
-	if username != am.adminUser {
-		return false
+// apiToken returns the request's unexpired API token and its user, or nils
+// if the token is unknown or its user has been disabled or removed
+func (am *AuthMiddleware) apiToken(r *http.Request) (*models.APIToken, *models.User) {
+	secret, ok := bearerToken(r)
+	if !ok || secret == "" || am.tokens == nil {
+		return nil, nil
+	}
+
+	t, err := am.tokens.GetAPITokenByHash(HashToken(secret))
+	if err != nil {
+		log.Printf("Error looking up API token: %v", err)
+		return nil, nil
 	}
-	err := bcrypt.CompareHashAndPassword([]byte(am.passwordHash), []byte(password))
-	return err == nil
+	if t == nil || !time.Now().Before(t.ExpiresAt) {
+		return nil, nil
+	}
+	user := am.enabledUser(t.UserID)
+	if user == nil {
+		return nil, nil
+	}
+	return t, user
 }
 
-func (am *AuthMiddleware) CreateSession() (string, time.Time) {
-	sessionToken := generateSessionToken()
-	expiry := time.Now().Add(24 * time.Hour)
+// touchToken records that a token was used, at most once every touchInterval
+func (am *AuthMiddleware) touchToken(t *models.APIToken) {
+	now := time.Now()
+	if t.LastUsedAt != nil && now.Sub(*t.LastUsedAt) < touchInterval {
+		return
+	}
+	if err := am.tokens.TouchAPIToken(t.ID, now); err != nil {
+		log.Printf("Error recording API token use: %v", err)
+	}
+}
 
-	am.sessionMu.Lock()
-	am.sessions[sessionToken] = expiry
-	am.sessionMu.Unlock()
+// extendSession records that the request's session was used and pushes
+// back its expiry, at most once every touchInterval
+func (am *AuthMiddleware) extendSession(r *http.Request) {
+	s := am.session(r)
+	now := time.Now()
+	if s == nil || now.Sub(s.LastSeenAt) < touchInterval {
+		return
+	}
+	if err := am.sessions.TouchSession(s.ID, now, now.Add(sessionTTL)); err != nil {
+		log.Printf("Error extending session: %v", err)
+	}
+}
+
+// session returns the request's unexpired session, or nil
+func (am *AuthMiddleware) session(r *http.Request) *models.Session {
+	cookie, err := r.Cookie("session_token")
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
 
-	return sessionToken, expiry
+	s, err := am.sessions.GetSession(HashToken(cookie.Value))
+	if err != nil {
+		log.Printf("Error looking up session: %v", err)
+		return nil
+	}
+	if s == nil || !time.Now().Before(s.ExpiresAt) {
+		return nil
+	}
+	return s
+}
+
+// SessionID returns the ID of the request's session, or "" if it has none
+func (am *AuthMiddleware) SessionID(r *http.Request) string {
+	if s := am.session(r); s != nil {
+		return s.ID
+	}
+	return ""
+}
+
+// User returns the signed-in user of the request, or nil for anonymous
+// requests and users who have since been disabled or removed. Requests with
+// an API token act as the token's user, whatever cookies they carry.
+func (am *AuthMiddleware) User(r *http.Request) *models.User {
+	if _, ok := bearerToken(r); ok {
+		_, user := am.apiToken(r)
+		return user
+	}
+	return am.sessionUser(r)
 }
 
+// sessionUser returns the user signed in with the request's session cookie
+func (am *AuthMiddleware) sessionUser(r *http.Request) *models.User {
+	s := am.session(r)
+	if s == nil {
+		return nil
+	}
+	return am.enabledUser(s.UserID)
+}
+
+// enabledUser looks up a user, returning nil if they are disabled or gone
+func (am *AuthMiddleware) enabledUser(id int64) *models.User {
+	user, err := am.users.GetUserByID(id)
+	if err != nil {
+		log.Printf("Error looking up user: %v", err)
+		return nil
+	}
+	if user == nil || user.Disabled {
+		return nil
+	}
+	return user
+}
+
+// IsAuthenticated reports whether the request carries a valid session,
+// for public pages that show extra content to signed-in users
+func (am *AuthMiddleware) IsAuthenticated(r *http.Request) bool {
+	return am.sessionUser(r) != nil
+}
+
+// Username returns the name of the signed-in user, or "" for anonymous requests
+func (am *AuthMiddleware) Username(r *http.Request) string {
+	if user := am.User(r); user != nil {
+		return user.Username
+	}
+	return ""
+}
+
+// ValidateCredentials returns the user with the username and password, or
+// nil if they don't match an enabled user who has set a password
+func (am *AuthMiddleware) ValidateCredentials(username, password string) *models.User {
+	user, err := am.users.GetUserByUsername(username)
+	if err != nil {
+		log.Printf("Error looking up user: %v", err)
+		return nil
+	}
+	if user == nil || user.PasswordHash == "" {
+		_ = bcrypt.CompareHashAndPassword(am.dummyHash, []byte(password))
+		return nil
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil || user.Disabled {
+		return nil
+	}
+	return user
+}
+
+// CreateSession signs a user in, recording the browser's IP and user agent,
+// and returns the token for the session cookie
+func (am *AuthMiddleware) CreateSession(r *http.Request, userID int64) (string, time.Time, error) {
+	token := generateSessionToken()
+	now := time.Now()
+	ip := getIP(r)
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	s := &models.Session{
+		ID:         HashToken(token),
+		UserID:     userID,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(sessionTTL),
+		IP:         ip,
+		UserAgent:  r.UserAgent(),
+	}
+	if err := am.sessions.CreateSession(s); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, s.ExpiresAt, nil
+}
+
+// DeleteSession signs out the session with the token from a cookie
 func (am *AuthMiddleware) DeleteSession(token string) {
-	am.sessionMu.Lock()
-	delete(am.sessions, token)
-	am.sessionMu.Unlock()
+	am.RevokeSession(HashToken(token))
+}
+
+// RevokeSession signs out a session by its ID
+func (am *AuthMiddleware) RevokeSession(id string) {
+	if err := am.sessions.DeleteSession(id); err != nil {
+		log.Printf("Error deleting session: %v", err)
+	}
+}
+
+// DeleteUserSessions signs a user out everywhere
+func (am *AuthMiddleware) DeleteUserSessions(userID int64) {
+	if err := am.sessions.DeleteUserSessions(userID); err != nil {
+		log.Printf("Error deleting sessions: %v", err)
+	}
+}
+
+// Sessions returns the sessions that haven't expired, most recently seen first
+func (am *AuthMiddleware) Sessions() ([]models.Session, error) {
+	return am.sessions.ListSessions(time.Now())
+}
+
+// GetSession returns an unexpired session by its ID, or nil
+func (am *AuthMiddleware) GetSession(id string) (*models.Session, error) {
+	s, err := am.sessions.GetSession(id)
+	if err != nil || s == nil || !time.Now().Before(s.ExpiresAt) {
+		return nil, err
+	}
+	return s, nil
 }
 
 func (am *AuthMiddleware) cleanupSessions() {
@@ -90,14 +386,9 @@ func (am *AuthMiddleware) cleanupSessions() {
 	defer ticker.Stop()
 
 	for range ticker.C {
-		am.sessionMu.Lock()
-		now := time.Now()
-		for token, expiry := range am.sessions {
-			if now.After(expiry) {
-				delete(am.sessions, token)
-			}
+		if err := am.sessions.DeleteExpiredSessions(time.Now()); err != nil {
+			log.Printf("Error deleting expired sessions: %v", err)
 		}
-		am.sessionMu.Unlock()
 	}
 }
 
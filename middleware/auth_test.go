@@ -1,57 +1,148 @@
 package middleware
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/tinotenda-alfaneti/homelabsite/models"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func TestNewAuthMiddleware(t *testing.T) {
-	am := NewAuthMiddleware("testuser", "testpass")
+// fakeUsers is an in-memory UserStore
+type fakeUsers map[int64]*models.User
 
-	if am.adminUser != "testuser" {
-		t.Errorf("Expected adminUser to be 'testuser', got '%s'", am.adminUser)
+func (f fakeUsers) GetUserByID(id int64) (*models.User, error) {
+	return f[id], nil
+}
+
+func (f fakeUsers) GetUserByUsername(username string) (*models.User, error) {
+	for _, u := range f {
+		if strings.EqualFold(u.Username, username) {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+// fakeTokens is an in-memory TokenStore, keyed by token hash
+type fakeTokens map[string]*models.APIToken
+
+func (f fakeTokens) GetAPITokenByHash(tokenHash string) (*models.APIToken, error) {
+	if t, ok := f[tokenHash]; ok {
+		copied := *t
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (f fakeTokens) TouchAPIToken(id int64, now time.Time) error {
+	for _, t := range f {
+		if t.ID == id {
+			t.LastUsedAt = &now
+		}
+	}
+	return nil
+}
+
+// add stores a token for the user that expires after ttl, and returns the
+// secret to send
+func (f fakeTokens) add(userID int64, ttl time.Duration, scopes ...models.Scope) string {
+	secret := GenerateToken()
+	f[HashToken(secret)] = &models.APIToken{
+		ID:        int64(len(f) + 1),
+		UserID:    userID,
+		Scopes:    scopes,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	return secret
+}
+
+func newTestUsers(t *testing.T) fakeUsers {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
 	}
+	return fakeUsers{
+		1: {ID: 1, Username: "admin", PasswordHash: string(hash), Role: models.RoleAdmin},
+		2: {ID: 2, Username: "editor", PasswordHash: string(hash), Role: models.RoleEditor},
+		3: {ID: 3, Username: "gone", PasswordHash: string(hash), Role: models.RoleEditor, Disabled: true},
+		4: {ID: 4, Username: "invited", Role: models.RoleModerator},
+	}
+}
 
-	// Verify password was hashed
-	err := bcrypt.CompareHashAndPassword([]byte(am.passwordHash), []byte("testpass"))
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("testpass")
 	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte("testpass")); err != nil {
 		t.Errorf("Password hash verification failed: %v", err)
 	}
 }
 
 func TestValidateCredentials(t *testing.T) {
-	am := NewAuthMiddleware("admin", "password123")
+	am := NewAuthMiddleware(newTestUsers(t), NewMemorySessionStore(), nil)
 
 	tests := []struct {
 		name     string
 		username string
 		password string
-		want     bool
+		want     int64
 	}{
-		{"Valid credentials", "admin", "password123", true},
-		{"Invalid username", "wrong", "password123", false},
-		{"Invalid password", "admin", "wrong", false},
-		{"Both invalid", "wrong", "wrong", false},
+		{"Valid credentials", "admin", "password123", 1},
+		{"Username ignores case", "Editor", "password123", 2},
+		{"Invalid username", "wrong", "password123", 0},
+		{"Invalid password", "admin", "wrong", 0},
+		{"Both invalid", "wrong", "wrong", 0},
+		{"Disabled user", "gone", "password123", 0},
+		{"No password set", "invited", "", 0},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := am.ValidateCredentials(tt.username, tt.password)
+			var got int64
+			if user := am.ValidateCredentials(tt.username, tt.password); user != nil {
+				got = user.ID
+			}
 			if got != tt.want {
-				t.Errorf("ValidateCredentials(%s, %s) = %v, want %v", tt.username, tt.password, got, tt.want)
+				t.Errorf("ValidateCredentials(%s, %s) = user %d, want %d", tt.username, tt.password, got, tt.want)
 			}
 		})
 	}
 }
 
+// signIn creates a session for the user and returns its token
+func signIn(t *testing.T, am *AuthMiddleware, userID int64) string {
+	t.Helper()
+	token, _, err := am.CreateSession(httptest.NewRequest("POST", "/admin/login", nil), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func withSession(token string) *http.Request {
+	req := httptest.NewRequest("GET", "/admin", nil)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: token})
+	return req
+}
+
 func TestCreateSession(t *testing.T) {
-	am := NewAuthMiddleware("admin", "password")
+	sessions := NewMemorySessionStore()
+	am := NewAuthMiddleware(newTestUsers(t), sessions, nil)
 
-	token, expiry := am.CreateSession()
+	login := httptest.NewRequest("POST", "/admin/login", nil)
+	login.Header.Set("User-Agent", "test-browser")
+	login.RemoteAddr = "192.0.2.1:51234"
+	token, expiry, err := am.CreateSession(login, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if token == "" {
 		t.Error("Expected non-empty session token")
@@ -61,42 +152,87 @@ func TestCreateSession(t *testing.T) {
 		t.Error("Expected expiry to be in the future")
 	}
 
-	// Check session was stored
-	am.sessionMu.RLock()
-	_, exists := am.sessions[token]
-	am.sessionMu.RUnlock()
+	if user := am.User(withSession(token)); user == nil || user.Username != "admin" {
+		t.Errorf("Expected the session to belong to admin, got %+v", user)
+	}
 
-	if !exists {
-		t.Error("Expected session to be stored")
+	// The store only sees the token's hash
+	if s, _ := sessions.GetSession(token); s != nil {
+		t.Error("Expected sessions to be stored by the hash of their token")
+	}
+	s, _ := sessions.GetSession(HashToken(token))
+	if s == nil || s.UserID != 1 || s.IP != "192.0.2.1" || s.UserAgent != "test-browser" || s.CreatedAt.IsZero() {
+		t.Errorf("Expected the session with the browser's details, got %+v", s)
+	}
+}
+
+func TestExpiredSession(t *testing.T) {
+	sessions := NewMemorySessionStore()
+	am := NewAuthMiddleware(newTestUsers(t), sessions, nil)
+	token := signIn(t, am, 1)
+
+	past := time.Now().Add(-time.Minute)
+	if err := sessions.TouchSession(HashToken(token), past, past); err != nil {
+		t.Fatal(err)
+	}
+	if am.IsAuthenticated(withSession(token)) {
+		t.Error("Expected an expired session to be rejected")
+	}
+}
+
+func TestExtendSession(t *testing.T) {
+	sessions := NewMemorySessionStore()
+	am := NewAuthMiddleware(newTestUsers(t), sessions, nil)
+	token := signIn(t, am, 1)
+	id := HashToken(token)
+
+	// A session seen a while ago is extended by the next request
+	old := time.Now().Add(-time.Hour)
+	if err := sessions.TouchSession(id, old, old.Add(sessionTTL)); err != nil {
+		t.Fatal(err)
+	}
+	am.RequireAuth(func(http.ResponseWriter, *http.Request) {})(httptest.NewRecorder(), withSession(token))
+
+	s, _ := sessions.GetSession(id)
+	if !s.LastSeenAt.After(old) || !s.ExpiresAt.After(old.Add(sessionTTL)) {
+		t.Errorf("Expected the session to be extended, got %+v", s)
 	}
 }
 
 func TestDeleteSession(t *testing.T) {
-	am := NewAuthMiddleware("admin", "password")
-	token, _ := am.CreateSession()
-
-	// Verify session exists
-	am.sessionMu.RLock()
-	_, exists := am.sessions[token]
-	am.sessionMu.RUnlock()
-	if !exists {
+	am := NewAuthMiddleware(newTestUsers(t), NewMemorySessionStore(), nil)
+	token := signIn(t, am, 1)
+
+	if !am.IsAuthenticated(withSession(token)) {
 		t.Fatal("Session should exist before deletion")
 	}
 
-	// Delete session
 	am.DeleteSession(token)
 
-	// Verify session was deleted
-	am.sessionMu.RLock()
-	_, exists = am.sessions[token]
-	am.sessionMu.RUnlock()
-	if exists {
+	if am.IsAuthenticated(withSession(token)) {
 		t.Error("Session should be deleted")
 	}
 }
 
+func TestDeleteUserSessions(t *testing.T) {
+	am := NewAuthMiddleware(newTestUsers(t), NewMemorySessionStore(), nil)
+	first := signIn(t, am, 2)
+	second := signIn(t, am, 2)
+	other := signIn(t, am, 1)
+
+	am.DeleteUserSessions(2)
+
+	if am.IsAuthenticated(withSession(first)) || am.IsAuthenticated(withSession(second)) {
+		t.Error("Expected the user's sessions to be deleted")
+	}
+	if !am.IsAuthenticated(withSession(other)) {
+		t.Error("Expected other users' sessions to be kept")
+	}
+}
+
 func TestRequireAuth(t *testing.T) {
-	am := NewAuthMiddleware("admin", "password")
+	users := newTestUsers(t)
+	am := NewAuthMiddleware(users, NewMemorySessionStore(), nil)
 
 	// Create a test handler
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
@@ -110,33 +246,34 @@ func TestRequireAuth(t *testing.T) {
 
 	tests := []struct {
 		name           string
-		setupSession   bool
+		userID         int64
 		expectedStatus int
 		expectedBody   string
 	}{
 		{
 			name:           "No session cookie",
-			setupSession:   false,
 			expectedStatus: http.StatusFound, // Redirect
 			expectedBody:   "",
 		},
 		{
 			name:           "Valid session",
-			setupSession:   true,
+			userID:         1,
 			expectedStatus: http.StatusOK,
 			expectedBody:   "authenticated",
 		},
+		{
+			name:           "Disabled user",
+			userID:         3,
+			expectedStatus: http.StatusFound,
+			expectedBody:   "",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			req := httptest.NewRequest("GET", "/admin", nil)
-			if tt.setupSession {
-				token, _ := am.CreateSession()
-				req.AddCookie(&http.Cookie{
-					Name:  "session_token",
-					Value: token,
-				})
+			if tt.userID != 0 {
+				req = withSession(signIn(t, am, tt.userID))
 			}
 
 			rr := httptest.NewRecorder()
@@ -152,3 +289,124 @@ func TestRequireAuth(t *testing.T) {
 		})
 	}
 }
+
+func TestRequireRole(t *testing.T) {
+	users := newTestUsers(t)
+	users[5] = &models.User{ID: 5, Username: "mod", Role: models.RoleModerator}
+	am := NewAuthMiddleware(users, NewMemorySessionStore(), nil)
+
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }
+	editor := am.RequireRole(models.RoleEditor)(ok)
+	staff := am.RequireRole(models.RoleEditor, models.RoleModerator)(ok)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		userID  int64
+		want    int
+	}{
+		{"Anonymous", editor, 0, http.StatusUnauthorized},
+		{"Admin passes every role", editor, 1, http.StatusOK},
+		{"Matching role", editor, 2, http.StatusOK},
+		{"Other role", editor, 5, http.StatusForbidden},
+		{"One of several roles", staff, 5, http.StatusOK},
+		{"Disabled user", editor, 3, http.StatusFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/posts", nil)
+			if tt.userID != 0 {
+				req = withSession(signIn(t, am, tt.userID))
+			}
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	users := newTestUsers(t)
+	users[5] = &models.User{ID: 5, Username: "mod", Role: models.RoleModerator}
+	tokens := fakeTokens{}
+	am := NewAuthMiddleware(users, NewMemorySessionStore(), tokens)
+
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }
+	posts := am.RequireScope(models.ScopePostsWrite)(ok)
+	signedIn := am.RequireAuth(ok)
+
+	editorToken := tokens.add(2, time.Hour, models.ScopePostsWrite)
+	commentsToken := tokens.add(1, time.Hour, models.ScopeCommentsModerate)
+	expiredToken := tokens.add(1, -time.Minute, models.ScopePostsWrite)
+	disabledToken := tokens.add(3, time.Hour, models.ScopePostsWrite)
+	// A moderator's token can't do more than the moderator can
+	modToken := tokens.add(5, time.Hour, models.ScopePostsWrite)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		auth    string
+		session int64
+		want    int
+	}{
+		{"Anonymous", posts, "", 0, http.StatusUnauthorized},
+		{"Session with the role", posts, "", 2, http.StatusOK},
+		{"Session without the role", posts, "", 5, http.StatusForbidden},
+		{"Token with the scope", posts, "Bearer " + editorToken, 0, http.StatusOK},
+		{"Scheme ignores case", posts, "bearer " + editorToken, 0, http.StatusOK},
+		{"Token without the scope", posts, "Bearer " + commentsToken, 0, http.StatusForbidden},
+		{"User lost the role", posts, "Bearer " + modToken, 0, http.StatusForbidden},
+		{"Expired token", posts, "Bearer " + expiredToken, 0, http.StatusUnauthorized},
+		{"Disabled user", posts, "Bearer " + disabledToken, 0, http.StatusUnauthorized},
+		{"Unknown token", posts, "Bearer nope", 0, http.StatusUnauthorized},
+		{"Empty token", posts, "Bearer", 0, http.StatusUnauthorized},
+		{"Bad token beside a session", posts, "Bearer nope", 1, http.StatusUnauthorized},
+		{"Token on a session-only route", signedIn, "Bearer " + editorToken, 0, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/posts", nil)
+			if tt.session != 0 {
+				req.AddCookie(&http.Cookie{Name: "session_token", Value: signIn(t, am, tt.session)})
+			}
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Fatalf("Expected status %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+			if tt.want == http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Success bool   `json:"success"`
+				Error   string `json:"error"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Success || body.Error == "" {
+				t.Errorf("Expected a JSON error, got %q", rr.Body.String())
+			}
+			if tt.want == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected a WWW-Authenticate header with the 401")
+			}
+		})
+	}
+
+	req := httptest.NewRequest("POST", "/api/posts", nil)
+	req.Header.Set("Authorization", "Bearer "+editorToken)
+	if user := am.User(req); user == nil || user.Username != "editor" {
+		t.Errorf("Expected the request to act as the token's user, got %+v", user)
+	}
+	if am.IsAuthenticated(req) {
+		t.Error("Expected tokens not to count as a browser session")
+	}
+	if used, _ := tokens.GetAPITokenByHash(HashToken(editorToken)); used.LastUsedAt == nil {
+		t.Error("Expected the token's use to be recorded")
+	}
+}
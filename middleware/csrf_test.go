@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// csrfServer serves a page that embeds the token at GET /, an action behind
+// RequireAuth at POST /action and one scripts may call at POST /api/action,
+// all behind CSRF
+func csrfServer(am *AuthMiddleware) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(CSRFToken(r)))
+	})
+	mux.HandleFunc("/public", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("public"))
+	})
+	mux.HandleFunc("/action", am.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("done"))
+	}))
+	mux.HandleFunc("/api/action", am.RequireScope(models.ScopePostsWrite)(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("done"))
+	}))
+	return CSRF(mux)
+}
+
+// csrfCookie returns the token cookie set by a response, or nil
+func csrfCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == CSRFCookie {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestCSRFToken(t *testing.T) {
+	handler := csrfServer(NewAuthMiddleware(newTestUsers(t), NewMemorySessionStore(), nil))
+
+	t.Run("pages without forms set no cookie", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/public", nil))
+		if c := csrfCookie(w); c != nil {
+			t.Errorf("Expected no cookie, got %v", c)
+		}
+	})
+
+	t.Run("first page sets a hardened cookie", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		c := csrfCookie(w)
+		if c == nil {
+			t.Fatal("Expected a cookie")
+		}
+		if c.Value == "" || c.Value != w.Body.String() {
+			t.Errorf("Expected the cookie to hold the page's token %q, got %q", w.Body.String(), c.Value)
+		}
+		if !c.HttpOnly || c.SameSite != http.SameSiteLaxMode || c.Path != "/" || c.Secure {
+			t.Errorf("Unexpected cookie attributes: %+v", c)
+		}
+	})
+
+	t.Run("existing cookie is reused", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: CSRFCookie, Value: "existing"})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Body.String() != "existing" {
+			t.Errorf("Expected token 'existing', got %q", w.Body.String())
+		}
+		if c := csrfCookie(w); c != nil {
+			t.Errorf("Expected the cookie not to be set again, got %v", c)
+		}
+	})
+
+	t.Run("cookie is secure behind an HTTPS proxy", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if c := csrfCookie(w); c == nil || !c.Secure {
+			t.Errorf("Expected a secure cookie, got %v", c)
+		}
+	})
+}
+
+func TestCSRF(t *testing.T) {
+	am := NewAuthMiddleware(newTestUsers(t), NewMemorySessionStore(), nil)
+	handler := csrfServer(am)
+	session := signIn(t, am, 1)
+	const token = "token-from-cookie"
+
+	tests := []struct {
+		name       string
+		method     string
+		cookie     string
+		header     string
+		field      string
+		origin     string
+		wantStatus int
+	}{
+		{"GET needs no token", "GET", "", "", "", "", http.StatusOK},
+		{"token in header", "POST", token, token, "", "", http.StatusOK},
+		{"token in form field", "POST", token, "", token, "", http.StatusOK},
+		{"same origin", "DELETE", token, token, "", "http://example.com", http.StatusOK},
+		{"forged without token", "POST", token, "", "", "", http.StatusForbidden},
+		{"forged without cookie", "POST", "", token, "", "", http.StatusForbidden},
+		{"forged with wrong token", "PUT", token, "guessed", "", "", http.StatusForbidden},
+		{"forged from another site", "POST", token, token, "", "https://evil.example", http.StatusForbidden},
+		{"forged with bad origin", "POST", token, token, "", "://", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			if tt.field != "" {
+				form.Set(CSRFField, tt.field)
+			}
+			req := httptest.NewRequest(tt.method, "/action", strings.NewReader(form.Encode()))
+			req.AddCookie(&http.Cookie{Name: "session_token", Value: session})
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: CSRFCookie, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				req.Header.Set(CSRFHeader, tt.header)
+			}
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCSRFAPIToken(t *testing.T) {
+	tokens := fakeTokens{}
+	am := NewAuthMiddleware(newTestUsers(t), NewMemorySessionStore(), tokens)
+	handler := csrfServer(am)
+	secret := tokens.add(1, time.Hour, models.ScopePostsWrite)
+
+	// Scripts sending a token have no CSRF cookie to send back
+	req := httptest.NewRequest("POST", "/api/action", nil)
+	req.Header.Set("Authorization", "Bearer "+secret)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected a token request without a CSRF token to pass, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// A session cookie beside a bad token doesn't sign the request in
+	req = httptest.NewRequest("POST", "/api/action", nil)
+	req.Header.Set("Authorization", "Bearer forged")
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: signIn(t, am, 1)})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", w.Code)
+	}
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// SessionStore keeps sign-in sessions, keyed by the hash of their token.
+// Getters return nil for sessions that don't exist, and deleting one that
+// doesn't exist is not an error.
+type SessionStore interface {
+	CreateSession(s *models.Session) error
+	GetSession(id string) (*models.Session, error)
+	// TouchSession records that the session was used and extends it
+	TouchSession(id string, lastSeen, expires time.Time) error
+	DeleteSession(id string) error
+	DeleteUserSessions(userID int64) error
+	// ListSessions returns the sessions that haven't expired by now, most
+	// recently seen first
+	ListSessions(now time.Time) ([]models.Session, error)
+	DeleteExpiredSessions(now time.Time) error
+}
+
+// MemorySessionStore keeps sessions in memory, so they are lost on restart
+// and not shared between replicas
+type MemorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]models.Session
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]models.Session)}
+}
+
+func (m *MemorySessionStore) CreateSession(s *models.Session) error {
+	m.mu.Lock()
+	m.sessions[s.ID] = *s
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemorySessionStore) GetSession(id string) (*models.Session, error) {
+	m.mu.RLock()
+	s, ok := m.sessions[id]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+func (m *MemorySessionStore) TouchSession(id string, lastSeen, expires time.Time) error {
+	m.mu.Lock()
+	if s, ok := m.sessions[id]; ok {
+		s.LastSeenAt = lastSeen
+		s.ExpiresAt = expires
+		m.sessions[id] = s
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemorySessionStore) DeleteSession(id string) error {
+	m.mu.Lock()
+	delete(m.sessions, id)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemorySessionStore) DeleteUserSessions(userID int64) error {
+	m.mu.Lock()
+	for id, s := range m.sessions {
+		if s.UserID == userID {
+			delete(m.sessions, id)
+		}
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemorySessionStore) ListSessions(now time.Time) ([]models.Session, error) {
+	m.mu.RLock()
+	sessions := make([]models.Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		if now.Before(s.ExpiresAt) {
+			sessions = append(sessions, s)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastSeenAt.Equal(sessions[j].LastSeenAt) {
+			return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+		}
+		return sessions[i].ID < sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (m *MemorySessionStore) DeleteExpiredSessions(now time.Time) error {
+	m.mu.Lock()
+	for id, s := range m.sessions {
+		if !now.Before(s.ExpiresAt) {
+			delete(m.sessions, id)
+		}
+	}
+	m.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+func TestMemorySessionStore(t *testing.T) {
+	store := NewMemorySessionStore()
+	now := time.Now()
+
+	for _, s := range []models.Session{
+		{ID: "a", UserID: 1, LastSeenAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)},
+		{ID: "b", UserID: 2, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)},
+		{ID: "c", UserID: 1, LastSeenAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Minute)},
+	} {
+		s := s
+		if err := store.CreateSession(&s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func() []string {
+		sessions, err := store.ListSessions(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, s := range sessions {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+	if got := ids(); len(got) != 2 || got[0] != "b" || got[1] != "a" {
+		t.Errorf("Expected unexpired sessions most recently seen first, got %v", got)
+	}
+
+	if err := store.DeleteExpiredSessions(now); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := store.GetSession("c"); s != nil {
+		t.Error("Expected the expired session to be deleted")
+	}
+
+	if err := store.DeleteUserSessions(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(); len(got) != 1 || got[0] != "b" {
+		t.Errorf("Expected only user 2's session left, got %v", got)
+	}
+	if err := store.DeleteSession("missing"); err != nil {
+		t.Errorf("Expected deleting a missing session to succeed, got %v", err)
+	}
+}
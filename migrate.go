@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/tinotenda-alfaneti/homelabsite/db"
+)
+
+const migrateUsage = `usage: homelabsite migrate <command>
+
+commands:
+  status    list applied and pending migrations
+  up        apply all pending migrations
+  dry-run   run pending migrations in a transaction and roll back
+  down      roll back the most recently applied migration`
+
+// runMigrate implements the "migrate" subcommand
+func runMigrate(dbPath string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected exactly one command\n%s", migrateUsage)
+	}
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	switch args[0] {
+	case "status":
+		return printMigrationStatus(database)
+	case "up":
+		applied, err := database.Migrate()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", len(applied))
+		return nil
+	case "dry-run":
+		pending, err := database.DryRunMigrations()
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			fmt.Println("No pending migrations")
+			return nil
+		}
+		for _, m := range pending {
+			fmt.Printf("would apply %04d_%s\n", m.Version, m.Name)
+		}
+		return nil
+	case "down":
+		m, err := database.RollbackMigration()
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			fmt.Println("No migrations to roll back")
+			return nil
+		}
+		fmt.Printf("Rolled back %04d_%s\n", m.Version, m.Name)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], migrateUsage)
+	}
+}
+
+func printMigrationStatus(database *db.DB) error {
+	applied, err := database.AppliedMigrations()
+	if err != nil {
+		return err
+	}
+	pending, err := database.PendingMigrations()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+	for _, m := range applied {
+		fmt.Fprintf(tw, "%04d\t%s\t%s\n", m.Version, m.Name, m.AppliedAt.Format("2006-01-02 15:04:05"))
+	}
+	for _, m := range pending {
+		fmt.Fprintf(tw, "%04d\t%s\tpending\n", m.Version, m.Name)
+	}
+	return tw.Flush()
+}
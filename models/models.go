@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"html/template"
+	"time"
+)
 
 // AppConfig holds application-level configuration
 type AppConfig struct {
@@ -23,7 +26,48 @@ type AppConfig struct {
 	Data struct {
 		PostsFile    string `yaml:"posts_file"`
 		ServicesFile string `yaml:"services_file"`
+		// PostsDir holds markdown posts with YAML front matter, imported alongside posts_file
+		PostsDir string `yaml:"posts_dir"`
+		// Sync controls the startup YAML import: "upsert" (default), "mirror" or "off"
+		Sync string `yaml:"sync"`
 	} `yaml:"data"`
+	// Probe configures the background service health prober
+	Probe struct {
+		Enabled bool `yaml:"enabled"`
+		// Interval between rounds of checks, e.g. "60s"
+		Interval string `yaml:"interval"`
+		// Timeout for a single check unless the service sets its own
+		Timeout string `yaml:"timeout"`
+		// Concurrency is how many services are checked at once
+		Concurrency int `yaml:"concurrency"`
+		// Retention is how long raw check results are kept, e.g. "48h"; hourly
+		// and daily uptime rolled up from them is kept longer
+		Retention string `yaml:"retention"`
+	} `yaml:"probe"`
+	// Site describes the public site in feeds and absolute links
+	Site SiteConfig `yaml:"site"`
+	Auth struct {
+		// Sessions is where sign-in sessions are kept: "sqlite" (default), which
+		// survives restarts and is shared by replicas on the same database, or "memory"
+		Sessions string `yaml:"sessions"`
+	} `yaml:"auth"`
+}
+
+// SiteConfig is the public identity of the site
+type SiteConfig struct {
+	// BaseURL is the public URL without a trailing slash, e.g. https://atarnet.org.
+	// When empty, links use the host of the request.
+	BaseURL     string `yaml:"base_url"`
+	Title       string `yaml:"title"`
+	Description string `yaml:"description"`
+	Author      struct {
+		Name  string `yaml:"name"`
+		Email string `yaml:"email"`
+	} `yaml:"author"`
+	Robots struct {
+		// Disallow lists paths crawlers should skip on top of /admin and /api
+		Disallow []string `yaml:"disallow"`
+	} `yaml:"robots"`
 }
 
 // ServicesData holds the services content
@@ -44,12 +88,103 @@ type Config struct {
 }
 
 type Service struct {
+	// ID is assigned by the database and stays the same when the service is renamed
+	ID          int64  `yaml:"-" json:"id"`
 	Name        string `yaml:"name" json:"name"`
 	Description string `yaml:"description" json:"description"`
 	URL         string `yaml:"url" json:"url"`
 	Tech        string `yaml:"tech" json:"tech"`
 	Status      string `yaml:"status" json:"status"`
 	Icon        string `yaml:"icon" json:"icon"`
+	// Check overrides how the prober checks the service; nil means an HTTP GET of URL
+	Check *ServiceCheck `yaml:"check,omitempty" json:"check,omitempty"`
+	// Health is the live status from recent checks, filled in when serving
+	Health *ServiceHealth `yaml:"-" json:"health,omitempty"`
+}
+
+// Service check types understood by the prober. Any other type must be
+// registered with the prober as a custom check.
+const (
+	CheckTypeHTTP = "http"
+	CheckTypeTCP  = "tcp"
+	CheckTypeNone = "none"
+)
+
+// ServiceCheck configures the health check for one service
+type ServiceCheck struct {
+	// Type is http (the default), tcp, none, or the name of a custom check
+	Type string `yaml:"type" json:"type,omitempty"`
+	// Target is the URL or host:port to check; defaults to the service URL
+	Target string `yaml:"target" json:"target,omitempty"`
+	// Method is the HTTP method, GET by default
+	Method string `yaml:"method" json:"method,omitempty"`
+	// ExpectStatus lists the HTTP status codes counted as up; default 200-399
+	ExpectStatus []int `yaml:"expect_status" json:"expect_status,omitempty"`
+	// Contains is text the HTTP response body must include
+	Contains string `yaml:"contains" json:"contains,omitempty"`
+	// Timeout overrides the prober timeout, e.g. "5s"
+	Timeout string `yaml:"timeout" json:"timeout,omitempty"`
+}
+
+// CheckResult is the outcome of a single health check
+type CheckResult struct {
+	ServiceID  int64     `json:"service_id"`
+	CheckedAt  time.Time `json:"checked_at"`
+	Up         bool      `json:"up"`
+	LatencyMS  int64     `json:"latency_ms"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Service health states
+const (
+	HealthUp      = "up"
+	HealthDown    = "down"
+	HealthUnknown = "unknown"
+)
+
+// ServiceHealth summarises a service's recent checks
+type ServiceHealth struct {
+	State     string     `json:"state"`
+	LatencyMS int64      `json:"latency_ms"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	// Uptime is the percentage of successful checks in the window
+	Uptime float64 `json:"uptime"`
+	Checks int     `json:"checks"`
+}
+
+// Service visibility statuses, used by the filters on the services page
+const (
+	ServiceStatusPublic      = "public"
+	ServiceStatusInternal    = "internal"
+	ServiceStatusDevelopment = "development"
+)
+
+// ValidServiceStatus reports whether status is one of the service statuses
+func ValidServiceStatus(status string) bool {
+	switch status {
+	case ServiceStatusPublic, ServiceStatusInternal, ServiceStatusDevelopment:
+		return true
+	}
+	return false
+}
+
+// Post lifecycle statuses
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+	PostStatusArchived  = "archived"
+)
+
+// ValidPostStatus reports whether status is one of the post lifecycle statuses
+func ValidPostStatus(status string) bool {
+	switch status {
+	case PostStatusDraft, PostStatusScheduled, PostStatusPublished, PostStatusArchived:
+		return true
+	}
+	return false
 }
 
 type Post struct {
@@ -61,11 +196,259 @@ type Post struct {
 	Content  string    `yaml:"content" json:"content"`
 	Tags     []string  `yaml:"tags" json:"tags"`
 	Views    int       `yaml:"views" json:"views"`
+	// Status is the lifecycle status; empty means published
+	Status string `yaml:"status" json:"status"`
+	// PublishAt delays a published or scheduled post until the given time
+	PublishAt *time.Time `yaml:"publish_at" json:"publish_at,omitempty"`
+	// Series groups multi-part posts, ordered by SeriesOrder
+	Series      string `yaml:"series" json:"series,omitempty"`
+	SeriesOrder int    `yaml:"series_order" json:"series_order,omitempty"`
+	// UpdatedAt is when the post was last saved; set by the database
+	UpdatedAt *time.Time `yaml:"-" json:"updated_at,omitempty"`
+}
+
+// IsPublished reports whether the post is visible on the public site at now
+func (p *Post) IsPublished(now time.Time) bool {
+	switch p.Status {
+	case "", PostStatusPublished, PostStatusScheduled:
+		return p.PublishAt == nil || !p.PublishAt.After(now)
+	}
+	return false
+}
+
+// Tag is a post tag. Names are unique ignoring case.
+type Tag struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// Count is the number of posts with the tag
+	Count int `json:"count"`
+}
+
+// Category is a post category. Names are unique ignoring case.
+type Category struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// Count is the number of posts in the category
+	Count int `json:"count"`
+}
+
+// SearchResult is a post matched by a search
+type SearchResult struct {
+	Post
+	// Score is the BM25 relevance, higher is better; 0 without full-text search
+	Score float64 `json:"score,omitempty"`
+	// Snippet is an HTML-escaped excerpt with the matched terms wrapped in <mark>
+	Snippet template.HTML `json:"snippet,omitempty"`
+}
+
+// PostRevision is an immutable snapshot of a post taken each time it is saved
+type PostRevision struct {
+	PostID    string    `json:"post_id"`
+	Revision  int       `json:"revision"`
+	Author    string    `json:"author"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Post      Post      `json:"post"`
+}
+
+// UptimeBucket is a rolled-up count of checks for one hour or one day
+type UptimeBucket struct {
+	Start  time.Time `json:"start"`
+	Checks int       `json:"checks"`
+	Up     int       `json:"up"`
+	// Uptime is the percentage of successful checks, 0 when there were none
+	Uptime float64 `json:"uptime"`
+}
+
+// Level classifies the bucket for the uptime bars: none, up, degraded or down
+func (b UptimeBucket) Level() string {
+	switch {
+	case b.Checks == 0:
+		return "none"
+	case b.Up == b.Checks:
+		return "up"
+	case b.Uptime >= 95:
+		return "degraded"
+	default:
+		return "down"
+	}
 }
 
+// Incident is a period during which a service's checks were failing.
+// ResolvedAt is nil while it is ongoing.
+type Incident struct {
+	ID          int64          `json:"id"`
+	ServiceID   int64          `json:"service_id"`
+	ServiceName string         `json:"service_name"`
+	StartedAt   time.Time      `json:"started_at"`
+	ResolvedAt  *time.Time     `json:"resolved_at,omitempty"`
+	Cause       string         `json:"cause"`
+	Notes       []IncidentNote `json:"notes"`
+}
+
+// Ongoing reports whether the incident has not been resolved yet
+func (i Incident) Ongoing() bool {
+	return i.ResolvedAt == nil
+}
+
+// IncidentNote is an admin's comment on an incident
+type IncidentNote struct {
+	ID         int64     `json:"id"`
+	IncidentID int64     `json:"incident_id"`
+	Author     string    `json:"author"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ServiceStatus is one service's entry on the status page
+type ServiceStatus struct {
+	ID     int64          `json:"id"`
+	Name   string         `json:"name"`
+	Icon   string         `json:"icon"`
+	Health *ServiceHealth `json:"health"`
+	// Uptime is the percentage of successful checks across Daily
+	Uptime float64        `json:"uptime"`
+	Daily  []UptimeBucket `json:"daily"`
+	Hourly []UptimeBucket `json:"hourly"`
+}
+
+// Role decides which parts of the admin a user can change
+type Role string
+
+const (
+	// RoleAdmin can do everything, including managing users and services
+	RoleAdmin Role = "admin"
+	// RoleEditor writes posts and manages tags and categories
+	RoleEditor Role = "editor"
+	// RoleModerator approves and deletes comments
+	RoleModerator Role = "moderator"
+)
+
+// Valid reports whether r is one of the known roles
+func (r Role) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleEditor, RoleModerator:
+		return true
+	}
+	return false
+}
+
+// User is an admin account. A user without a password hasn't accepted their
+// invite yet, or had their password reset, and can't sign in.
 type User struct {
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
+	ID           int64      `json:"id"`
+	Username     string     `json:"username"`
+	Email        string     `json:"email"`
+	PasswordHash string     `json:"-"`
+	Role         Role       `json:"role"`
+	Disabled     bool       `json:"disabled"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastLoginAt  *time.Time `json:"last_login_at,omitempty"`
+	// InviteExpiresAt is set while an invite or reset link is outstanding
+	InviteExpiresAt *time.Time `json:"invite_expires_at,omitempty"`
+	// TOTPEnabled is set once the user has confirmed an authenticator app,
+	// after which signing in also asks for a code from it
+	TOTPEnabled bool `json:"totp_enabled"`
+	// TOTPSecret is the authenticator app's secret, set from when the user
+	// starts enrolling
+	TOTPSecret string `json:"-"`
+}
+
+// Session is a signed-in browser. ID is the SHA-256 of the token in the
+// session cookie, so stored sessions can be listed and revoked without
+// revealing tokens that could be used to sign in.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int64     `json:"user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+}
+
+// LoginChallenge is a sign-in that passed the password step and is waiting
+// for a two-factor code. ID is the SHA-256 of the token in its cookie.
+type LoginChallenge struct {
+	ID        string
+	UserID    int64
+	ExpiresAt time.Time
+	Attempts  int
+}
+
+// HasRole reports whether the user may act in one of the roles. Admins may
+// act in every role.
+func (u *User) HasRole(roles ...Role) bool {
+	if u.Role == RoleAdmin {
+		return true
+	}
+	for _, role := range roles {
+		if u.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Scope is something an API token may do
+type Scope string
+
+const (
+	// ScopePostsWrite creates, edits and deletes posts, their revisions,
+	// tags and categories
+	ScopePostsWrite Scope = "posts:write"
+	// ScopeCommentsModerate lists pending comments, approves and deletes them
+	ScopeCommentsModerate Scope = "comments:moderate"
+	// ScopeServicesWrite creates, edits and deletes services
+	ScopeServicesWrite Scope = "services:write"
+)
+
+// Scopes lists every scope
+var Scopes = []Scope{ScopePostsWrite, ScopeCommentsModerate, ScopeServicesWrite}
+
+// Valid reports whether s is one of the known scopes
+func (s Scope) Valid() bool {
+	return s.Role() != ""
+}
+
+// Role returns the role a user needs to act in the scope, or "" for an
+// unknown scope
+func (s Scope) Role() Role {
+	switch s {
+	case ScopePostsWrite:
+		return RoleEditor
+	case ScopeCommentsModerate:
+		return RoleModerator
+	case ScopeServicesWrite:
+		return RoleAdmin
+	}
+	return ""
+}
+
+// APIToken is a personal access token for scripts and CI, sent as
+// "Authorization: Bearer <token>". It acts as its user, limited to its scopes.
+// Only the SHA-256 of the token is stored; Prefix is its first characters,
+// to tell tokens apart.
+type APIToken struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Username   string     `json:"username"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []Scope    `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// HasScope reports whether the token was given the scope
+func (t *APIToken) HasScope(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
 }
 
 type Comment struct {
@@ -80,6 +463,19 @@ type Comment struct {
 	Replies     []Comment `json:"replies,omitempty"`
 }
 
+// SEO is the metadata a page gives search engines and link previews
+type SEO struct {
+	Title       string
+	Description string
+	// Canonical is the absolute URL of the page
+	Canonical string
+	// Type is the Open Graph type: website or article
+	Type string
+	// Image is the absolute URL of the page's social preview image
+	Image    string
+	SiteName string
+}
+
 type Breadcrumb struct {
 	Name string `json:"name"`
 	URL  string `json:"url"`
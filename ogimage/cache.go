@@ -0,0 +1,102 @@
+package ogimage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Cache keeps rendered cards as PNG files named by their Key, so a card is
+// drawn once and redrawn only when its content changes
+type Cache struct {
+	// Dir holds the images; when empty nothing is cached
+	Dir string
+}
+
+// NewCache returns a cache writing to dir, which is created on first use
+func NewCache(dir string) *Cache {
+	return &Cache{Dir: dir}
+}
+
+// Get returns the PNG of a card from the cache, rendering and storing it if
+// it isn't there yet
+func (c *Cache) Get(card Card) ([]byte, error) {
+	if c.Dir == "" {
+		return Render(card)
+	}
+
+	path := filepath.Join(c.Dir, card.Key()+".png")
+	img, err := os.ReadFile(path)
+	if err == nil {
+		return img, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	img, err = Render(card)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(c.Dir, path, img); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// Prune removes the cached images of every card but the given ones, such as
+// those of old titles and deleted posts, and returns how many it removed
+func (c *Cache) Prune(keep []Card) (int, error) {
+	if c.Dir == "" {
+		return 0, nil
+	}
+	entries, err := os.ReadDir(c.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	current := make(map[string]bool, len(keep))
+	for _, card := range keep {
+		current[card.Key()+".png"] = true
+	}
+	removed := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		// Files starting with a dot are images still being written
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".png") || current[name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.Dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// writeFile writes through a temporary file and a rename, so concurrent
+// requests for a new card never read a partly written image
+func writeFile(dir, path string, data []byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".og-*.png")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,231 @@
+// Package ogimage draws the social preview images shown when a page is shared:
+// a PNG card with a title, a category and the site's name.
+package ogimage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Size of a card, the 1.91:1 ratio Open Graph and Twitter cards display
+const (
+	Width  = 1200
+	Height = 630
+)
+
+// layoutVersion is part of every card's Key; bump it when the drawing
+// changes so cached images are redrawn
+const layoutVersion = "1"
+
+const (
+	margin = 80
+	// titleTop is the baseline of the first title line
+	titleTop = 250
+	// footerTop is where the rule above the site name is drawn
+	footerTop = 500
+	// Title sizes tried from largest to smallest until it fits maxTitleLines
+	maxTitleSize  = 72
+	minTitleSize  = 48
+	maxTitleLines = 3
+)
+
+// Colours of the site's dark theme
+var (
+	background = color.RGBA{0x0d, 0x11, 0x17, 0xff}
+	panel      = color.RGBA{0x16, 0x1b, 0x22, 0xff}
+	rule       = color.RGBA{0x30, 0x36, 0x3d, 0xff}
+	accent     = color.RGBA{0x3b, 0x9b, 0xff, 0xff}
+	text       = color.RGBA{0xe0, 0xe0, 0xe0, 0xff}
+	secondary  = color.RGBA{0xa0, 0xa0, 0xa0, 0xff}
+)
+
+// Card is what an image shows
+type Card struct {
+	Title    string
+	Category string
+	// Site is the site's name, drawn in the footer
+	Site string
+	// Host is drawn at the right of the footer, e.g. atarnet.org
+	Host string
+}
+
+// Key is a hash of everything that affects how the card is drawn
+func (c Card) Key() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{layoutVersion, c.Title, c.Category, c.Site, c.Host}, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+var (
+	fontsOnce     sync.Once
+	regular, bold *opentype.Font
+	fontsErr      error
+)
+
+func loadFonts() error {
+	fontsOnce.Do(func() {
+		if regular, fontsErr = opentype.Parse(goregular.TTF); fontsErr != nil {
+			return
+		}
+		bold, fontsErr = opentype.Parse(gobold.TTF)
+	})
+	return fontsErr
+}
+
+func face(f *opentype.Font, size float64) (font.Face, error) {
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// Render draws a card as a PNG
+func Render(card Card) ([]byte, error) {
+	if err := loadFonts(); err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	fill(img, image.Rect(0, footerTop, Width, Height), panel)
+	fill(img, image.Rect(0, footerTop, Width, footerTop+2), rule)
+	fill(img, image.Rect(0, 0, 16, Height), accent)
+
+	if card.Category != "" {
+		f, err := face(bold, 30)
+		if err != nil {
+			return nil, err
+		}
+		drawString(img, f, accent, margin, 140, strings.ToUpper(card.Category))
+		f.Close()
+	}
+
+	title, size, err := fitTitle(card.Title)
+	if err != nil {
+		return nil, err
+	}
+	f, err := face(bold, size)
+	if err != nil {
+		return nil, err
+	}
+	lineHeight := int(size * 1.25)
+	for i, line := range title {
+		drawString(img, f, text, margin, titleTop+i*lineHeight, line)
+	}
+	f.Close()
+
+	f, err = face(bold, 36)
+	if err != nil {
+		return nil, err
+	}
+	drawString(img, f, text, margin, footerTop+80, card.Site)
+	f.Close()
+
+	if card.Host != "" {
+		f, err := face(regular, 28)
+		if err != nil {
+			return nil, err
+		}
+		x := Width - margin - font.MeasureString(f, card.Host).Ceil()
+		drawString(img, f, secondary, x, footerTop+78, card.Host)
+		f.Close()
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fitTitle wraps the title at the largest size that fits in maxTitleLines,
+// cutting it short with an ellipsis if even the smallest size doesn't
+func fitTitle(title string) ([]string, float64, error) {
+	for size := float64(maxTitleSize); ; size -= 8 {
+		f, err := face(bold, size)
+		if err != nil {
+			return nil, 0, err
+		}
+		lines := wrap(f, title, Width-2*margin)
+		if len(lines) > maxTitleLines && size <= minTitleSize {
+			lines = lines[:maxTitleLines]
+			lines[maxTitleLines-1] = ellipsize(f, lines[maxTitleLines-1], Width-2*margin)
+		}
+		f.Close()
+		if len(lines) <= maxTitleLines {
+			return lines, size, nil
+		}
+	}
+}
+
+// wrap breaks text into lines no wider than width, splitting words that
+// don't fit on a line of their own
+func wrap(f font.Face, text string, width int) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if font.MeasureString(f, candidate).Ceil() <= width {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		line = word
+		for font.MeasureString(f, line).Ceil() > width {
+			cut := fits(f, line, width)
+			lines = append(lines, line[:cut])
+			line = line[cut:]
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// fits returns the byte length of the longest prefix of s no wider than
+// width, at least one rune so wrapping always makes progress
+func fits(f font.Face, s string, width int) int {
+	end := 0
+	for i, r := range s {
+		next := i + utf8.RuneLen(r)
+		if end > 0 && font.MeasureString(f, s[:next]).Ceil() > width {
+			break
+		}
+		end = next
+	}
+	return end
+}
+
+// ellipsize marks a line as cut short, trimming it to keep the ellipsis in width
+func ellipsize(f font.Face, line string, width int) string {
+	for line != "" && font.MeasureString(f, line+"…").Ceil() > width {
+		_, size := utf8.DecodeLastRuneInString(line)
+		line = strings.TrimRight(line[:len(line)-size], " ")
+	}
+	return line + "…"
+}
+
+func fill(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func drawString(img draw.Image, f font.Face, c color.Color, x, y int, s string) {
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(c), Face: f, Dot: fixed.P(x, y)}
+	d.DrawString(s)
+}
@@ -0,0 +1,112 @@
+package ogimage
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font"
+)
+
+func TestRender(t *testing.T) {
+	img, err := Render(Card{Title: "Running Kubernetes at Home", Category: "DevOps", Site: "Atarnet Homelab", Host: "atarnet.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(img))
+	if err != nil {
+		t.Fatalf("Expected a PNG: %v", err)
+	}
+	if b := decoded.Bounds(); b.Dx() != Width || b.Dy() != Height {
+		t.Errorf("Expected %dx%d, got %v", Width, Height, b)
+	}
+}
+
+func TestFitTitle(t *testing.T) {
+	if err := loadFonts(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		title     string
+		wantSize  float64
+		wantLines int
+		ellipsis  bool
+	}{
+		{"short", "Hello", maxTitleSize, 1, false},
+		{"long", strings.Repeat("Kubernetes homelab ", 4), 64, 3, false},
+		{"too long", strings.Repeat("Kubernetes homelab ", 20), minTitleSize, maxTitleLines, true},
+		{"one long word", strings.Repeat("x", 200), minTitleSize, maxTitleLines, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, size, err := fitTitle(tt.title)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != tt.wantSize || len(lines) != tt.wantLines {
+				t.Errorf("Expected %d lines at %v, got %d at %v: %q", tt.wantLines, tt.wantSize, len(lines), size, lines)
+			}
+			if got := strings.HasSuffix(lines[len(lines)-1], "…"); got != tt.ellipsis {
+				t.Errorf("Expected ellipsis %v, got %q", tt.ellipsis, lines)
+			}
+
+			f, _ := face(bold, size)
+			defer f.Close()
+			for _, line := range lines {
+				if w := font.MeasureString(f, line).Ceil(); w > Width-2*margin {
+					t.Errorf("Line %q is %dpx wide", line, w)
+				}
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "og")
+	c := NewCache(dir)
+	card := Card{Title: "Cached", Site: "Site"}
+
+	first, err := c.Get(card)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, card.Key()+".png")
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Expected the image on disk: %v", err)
+	}
+
+	// A cached image is served as stored, without drawing it again
+	if err := os.WriteFile(path, []byte("cached"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Get(card)
+	if err != nil || string(second) != "cached" {
+		t.Errorf("Expected the cached file, got %d bytes, %v", len(second), err)
+	}
+
+	card.Title = "Changed"
+	third, err := c.Get(card)
+	if err != nil || !bytes.HasPrefix(third, []byte("\x89PNG")) || bytes.Equal(first, third) {
+		t.Errorf("Expected a new image for a changed card, err %v", err)
+	}
+
+	// Pruning keeps only the current cards
+	removed, err := c.Prune([]Card{card})
+	if err != nil || removed != 1 {
+		t.Errorf("Expected the old card to be removed, got %d, %v", removed, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected the old image to be gone")
+	}
+	if _, err := os.Stat(filepath.Join(dir, card.Key()+".png")); err != nil {
+		t.Errorf("Expected the current image to be kept: %v", err)
+	}
+	if removed, err := NewCache(filepath.Join(dir, "missing")).Prune(nil); err != nil || removed != 0 {
+		t.Errorf("Expected pruning a missing directory to do nothing, got %d, %v", removed, err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+	"github.com/tinotenda-alfaneti/homelabsite/prober"
+)
+
+// probeOptions maps the probe config section to prober options.
+// Empty values fall back to the prober defaults.
+func probeOptions(cfg *models.AppConfig) (prober.Options, error) {
+	var opts prober.Options
+	durations := []struct {
+		name  string
+		value string
+		dest  *time.Duration
+	}{
+		{"interval", cfg.Probe.Interval, &opts.Interval},
+		{"timeout", cfg.Probe.Timeout, &opts.Timeout},
+		{"retention", cfg.Probe.Retention, &opts.Retention},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil || v <= 0 {
+			return opts, fmt.Errorf("probe.%s: invalid duration %q", d.name, d.value)
+		}
+		*d.dest = v
+	}
+
+	if cfg.Probe.Concurrency < 0 {
+		return opts, fmt.Errorf("probe.concurrency must not be negative")
+	}
+	opts.Concurrency = cfg.Probe.Concurrency
+	return opts, nil
+}
@@ -0,0 +1,314 @@
+package prober
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// Default options, used for any zero Options field
+const (
+	DefaultInterval    = time.Minute
+	DefaultTimeout     = 10 * time.Second
+	DefaultConcurrency = 4
+	DefaultRetention   = 48 * time.Hour
+)
+
+// maxBodyBytes bounds how much of a response is read for a Contains match
+const maxBodyBytes = 1 << 20
+
+// Store is the persistence the prober needs; *db.DB implements it
+type Store interface {
+	GetAllServices() ([]models.Service, error)
+	RecordCheck(result models.CheckResult) error
+	RollupChecks(until time.Time) error
+	PruneChecks(before time.Time) (int64, error)
+}
+
+// CheckFunc is a custom check. It returns nil when the service is up.
+type CheckFunc func(ctx context.Context, service models.Service) error
+
+// Options configures a Prober
+type Options struct {
+	Interval    time.Duration
+	Timeout     time.Duration
+	Concurrency int
+	// Retention is how long raw check results are kept after being rolled up
+	Retention time.Duration
+	// Client is used for HTTP checks; it should not follow redirects
+	Client *http.Client
+}
+
+// Prober periodically checks every service and records the results
+type Prober struct {
+	store  Store
+	opts   Options
+	now    func() time.Time
+	mu     sync.RWMutex
+	custom map[string]CheckFunc
+}
+
+// New creates a prober, filling in defaults for unset options
+func New(store Store, opts Options) *Prober {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultRetention
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{
+			// A redirect already shows the service is answering
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+
+	return &Prober{
+		store:  store,
+		opts:   opts,
+		now:    time.Now,
+		custom: make(map[string]CheckFunc),
+	}
+}
+
+// Register adds a custom check that services select with check.type
+func (p *Prober) Register(checkType string, fn CheckFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.custom[checkType] = fn
+}
+
+// Run probes every service immediately and then once per interval until ctx
+// is cancelled. After each round, completed hours are rolled up into uptime
+// buckets and raw results older than the retention are pruned.
+func (p *Prober) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.ProbeAll(ctx); err != nil {
+			log.Printf("Error probing services: %v", err)
+		}
+		p.compact()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// compact rolls up finished hours and prunes raw results. The prune cutoff is
+// on an hour boundary at or before the rollup, so only rolled-up rows go.
+func (p *Prober) compact() {
+	now := p.now()
+	if err := p.store.RollupChecks(now.Truncate(time.Hour)); err != nil {
+		log.Printf("Error rolling up service checks: %v", err)
+		return
+	}
+	if _, err := p.store.PruneChecks(now.Add(-p.opts.Retention).Truncate(time.Hour)); err != nil {
+		log.Printf("Error pruning service checks: %v", err)
+	}
+}
+
+// ProbeAll checks every service with a check, at most Concurrency at a time,
+// records the results and returns them in service order
+func (p *Prober) ProbeAll(ctx context.Context) ([]models.CheckResult, error) {
+	services, err := p.store.GetAllServices()
+	if err != nil {
+		return nil, err
+	}
+
+	var probed []models.Service
+	for _, s := range services {
+		if checkType(s) != models.CheckTypeNone {
+			probed = append(probed, s)
+		}
+	}
+
+	results := make([]models.CheckResult, len(probed))
+	sem := make(chan struct{}, p.opts.Concurrency)
+	var wg sync.WaitGroup
+	for i, service := range probed {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, service models.Service) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = p.Check(ctx, service)
+		}(i, service)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		if err := p.store.RecordCheck(result); err != nil {
+			return results, fmt.Errorf("recording check for service %d: %w", result.ServiceID, err)
+		}
+	}
+	return results, nil
+}
+
+// Check runs a single service's check and times it
+func (p *Prober) Check(ctx context.Context, service models.Service) models.CheckResult {
+	timeout := p.opts.Timeout
+	if service.Check != nil && service.Check.Timeout != "" {
+		if d, err := time.ParseDuration(service.Check.Timeout); err == nil && d > 0 {
+			timeout = d
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := models.CheckResult{ServiceID: service.ID, CheckedAt: p.now().UTC()}
+	start := time.Now()
+
+	var err error
+	switch t := checkType(service); t {
+	case models.CheckTypeHTTP:
+		result.StatusCode, err = p.checkHTTP(ctx, service)
+	case models.CheckTypeTCP:
+		err = checkTCP(ctx, service)
+	default:
+		p.mu.RLock()
+		fn, ok := p.custom[t]
+		p.mu.RUnlock()
+		if !ok {
+			err = fmt.Errorf("unknown check type %q", t)
+		} else {
+			err = fn(ctx, service)
+		}
+	}
+
+	result.LatencyMS = time.Since(start).Milliseconds()
+	result.Up = err == nil
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// checkType returns the check to run for a service: its configured type,
+// else http when it has a URL, else none
+func checkType(service models.Service) string {
+	if service.Check != nil && service.Check.Type != "" {
+		return service.Check.Type
+	}
+	if (service.Check != nil && service.Check.Target != "") || service.URL != "" {
+		return models.CheckTypeHTTP
+	}
+	return models.CheckTypeNone
+}
+
+func target(service models.Service) string {
+	if service.Check != nil && service.Check.Target != "" {
+		return service.Check.Target
+	}
+	return service.URL
+}
+
+func (p *Prober) checkHTTP(ctx context.Context, service models.Service) (int, error) {
+	check := service.Check
+	if check == nil {
+		check = &models.ServiceCheck{}
+	}
+	method := check.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target(service), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "homelabsite-prober")
+
+	resp, err := p.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if !statusOK(resp.StatusCode, check.ExpectStatus) {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	if check.Contains != "" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+		if err != nil {
+			return resp.StatusCode, err
+		}
+		if !strings.Contains(string(body), check.Contains) {
+			return resp.StatusCode, fmt.Errorf("response does not contain %q", check.Contains)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func statusOK(code int, expect []int) bool {
+	if len(expect) == 0 {
+		return code >= 200 && code < 400
+	}
+	for _, c := range expect {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func checkTCP(ctx context.Context, service models.Service) error {
+	addr, err := tcpAddress(target(service))
+	if err != nil {
+		return err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// tcpAddress accepts host:port or a URL, using the scheme's default port
+func tcpAddress(target string) (string, error) {
+	if !strings.Contains(target, "://") {
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			return "", fmt.Errorf("tcp target %q: %w", target, err)
+		}
+		return target, nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	if u.Port() != "" {
+		return u.Host, nil
+	}
+	switch u.Scheme {
+	case "http":
+		return net.JoinHostPort(u.Hostname(), "80"), nil
+	case "https":
+		return net.JoinHostPort(u.Hostname(), "443"), nil
+	}
+	return "", fmt.Errorf("tcp target %q has no port", target)
+}
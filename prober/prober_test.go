@@ -0,0 +1,254 @@
+package prober
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// memStore is an in-memory Store
+type memStore struct {
+	mu       sync.Mutex
+	services []models.Service
+	results  []models.CheckResult
+	rolledUp time.Time
+	pruned   time.Time
+}
+
+func (s *memStore) GetAllServices() ([]models.Service, error) {
+	return s.services, nil
+}
+
+func (s *memStore) RecordCheck(result models.CheckResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, result)
+	return nil
+}
+
+func (s *memStore) RollupChecks(until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rolledUp = until
+	return nil
+}
+
+func (s *memStore) PruneChecks(before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruned = before
+	return 0, nil
+}
+
+func TestCheckHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			_, _ = w.Write([]byte("status: healthy"))
+		case "/redirect":
+			http.Redirect(w, r, "/login", http.StatusFound)
+		case "/teapot":
+			w.WriteHeader(http.StatusTeapot)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		service    models.Service
+		wantUp     bool
+		wantStatus int
+		wantErr    string
+	}{
+		{"up", models.Service{URL: srv.URL + "/ok"}, true, 200, ""},
+		{"redirect counts as up", models.Service{URL: srv.URL + "/redirect"}, true, 302, ""},
+		{"not found", models.Service{URL: srv.URL + "/missing"}, false, 404, "unexpected status 404"},
+		{"expected status", models.Service{URL: srv.URL + "/teapot", Check: &models.ServiceCheck{ExpectStatus: []int{418}}}, true, 418, ""},
+		{"body contains", models.Service{URL: srv.URL + "/ok", Check: &models.ServiceCheck{Contains: "healthy"}}, true, 200, ""},
+		{"body missing text", models.Service{URL: srv.URL + "/ok", Check: &models.ServiceCheck{Contains: "ready"}}, false, 200, `does not contain "ready"`},
+		{"target overrides url", models.Service{URL: "https://example.invalid", Check: &models.ServiceCheck{Target: srv.URL + "/ok"}}, true, 200, ""},
+	}
+
+	p := New(&memStore{}, Options{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := p.Check(context.Background(), tt.service)
+			if result.Up != tt.wantUp || result.StatusCode != tt.wantStatus {
+				t.Errorf("got up=%v status=%d (%s), want up=%v status=%d", result.Up, result.StatusCode, result.Error, tt.wantUp, tt.wantStatus)
+			}
+			if !strings.Contains(result.Error, tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", result.Error, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	p := New(&memStore{}, Options{Timeout: time.Minute})
+	service := models.Service{URL: srv.URL, Check: &models.ServiceCheck{Timeout: "50ms"}}
+
+	result := p.Check(context.Background(), service)
+	if result.Up || !strings.Contains(result.Error, "deadline exceeded") {
+		t.Errorf("Expected a timeout, got %+v", result)
+	}
+}
+
+func TestCheckTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+
+	p := New(&memStore{}, Options{Timeout: time.Second})
+	service := models.Service{Check: &models.ServiceCheck{Type: models.CheckTypeTCP, Target: addr}}
+	if result := p.Check(context.Background(), service); !result.Up {
+		t.Errorf("Expected open port to be up, got %+v", result)
+	}
+
+	ln.Close()
+	if result := p.Check(context.Background(), service); result.Up {
+		t.Errorf("Expected closed port to be down, got %+v", result)
+	}
+}
+
+func TestTCPAddress(t *testing.T) {
+	tests := map[string]string{
+		"db.lan:5432":              "db.lan:5432",
+		"https://jenkins.lan":      "jenkins.lan:443",
+		"http://grafana.lan/login": "grafana.lan:80",
+		"https://gitea.lan:3000/x": "gitea.lan:3000",
+	}
+	for in, want := range tests {
+		if got, err := tcpAddress(in); err != nil || got != want {
+			t.Errorf("tcpAddress(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := tcpAddress("no-port"); err == nil {
+		t.Error("Expected an error for a target without a port")
+	}
+}
+
+func TestCustomCheck(t *testing.T) {
+	p := New(&memStore{}, Options{})
+	p.Register("queue-depth", func(ctx context.Context, s models.Service) error {
+		if s.Name == "backed-up" {
+			return errors.New("queue depth 900")
+		}
+		return nil
+	})
+
+	check := &models.ServiceCheck{Type: "queue-depth"}
+	if result := p.Check(context.Background(), models.Service{Name: "fine", Check: check}); !result.Up {
+		t.Errorf("Expected up, got %+v", result)
+	}
+	if result := p.Check(context.Background(), models.Service{Name: "backed-up", Check: check}); result.Up || result.Error != "queue depth 900" {
+		t.Errorf("Expected the custom error, got %+v", result)
+	}
+	if result := p.Check(context.Background(), models.Service{Check: &models.ServiceCheck{Type: "nope"}}); result.Up {
+		t.Errorf("Expected an unregistered type to be down, got %+v", result)
+	}
+}
+
+func TestProbeAll(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	store := &memStore{}
+	for i := int64(1); i <= 6; i++ {
+		store.services = append(store.services, models.Service{ID: i, URL: srv.URL})
+	}
+	// Services without a URL or with checks turned off aren't probed
+	store.services = append(store.services,
+		models.Service{ID: 7},
+		models.Service{ID: 8, URL: srv.URL, Check: &models.ServiceCheck{Type: models.CheckTypeNone}},
+	)
+
+	p := New(store, Options{Concurrency: 2})
+	results, err := p.ProbeAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 6 || len(store.results) != 6 {
+		t.Fatalf("Expected 6 recorded results, got %d and %d", len(results), len(store.results))
+	}
+	for i, r := range results {
+		if r.ServiceID != int64(i+1) || !r.Up {
+			t.Errorf("result %d = %+v", i, r)
+		}
+	}
+	if maxInFlight > 2 {
+		t.Errorf("Expected at most 2 concurrent checks, saw %d", maxInFlight)
+	}
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+
+	store := &memStore{services: []models.Service{{ID: 1, URL: srv.URL}}}
+	p := New(store, Options{Interval: 10 * time.Millisecond, Retention: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.results) < 2 {
+		t.Errorf("Expected several rounds of checks, got %d results", len(store.results))
+	}
+	if store.rolledUp.IsZero() || store.pruned.IsZero() {
+		t.Fatal("Expected checks to be rolled up and pruned")
+	}
+	if store.pruned.After(store.rolledUp) {
+		t.Errorf("Pruned up to %v, past the rollup at %v", store.pruned, store.rolledUp)
+	}
+}
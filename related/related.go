@@ -0,0 +1,171 @@
+// Package related scores how closely posts are related, from the tags they
+// share, their category and the similarity of their text.
+package related
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Weights of each signal in a score. Each signal is between 0 and 1, so a
+// score is at most their sum.
+const (
+	TagWeight      = 3.0
+	CategoryWeight = 1.0
+	TextWeight     = 2.0
+)
+
+// Document is a post as seen by the scorer
+type Document struct {
+	ID       string
+	Category string
+	Tags     []string
+	// Text is the title, summary and body
+	Text string
+}
+
+// Match is a related document and how closely it is related
+type Match struct {
+	ID    string
+	Score float64
+}
+
+// Rank returns, for each document, up to limit of the other documents with
+// a positive score, best first. Ties are broken by ID so results are stable.
+//
+// Shared tags count by their Jaccard similarity and the text by the cosine
+// similarity of TF-IDF vectors, so long posts and heavily tagged posts don't
+// crowd out the rest. Tags and categories compare ignoring case.
+func Rank(docs []Document, limit int) map[string][]Match {
+	vectors := tfidf(docs)
+	tags := make([]map[string]bool, len(docs))
+	for i, d := range docs {
+		tags[i] = make(map[string]bool, len(d.Tags))
+		for _, t := range d.Tags {
+			tags[i][strings.ToLower(t)] = true
+		}
+	}
+
+	ranked := make(map[string][]Match, len(docs))
+	for i, a := range docs {
+		var matches []Match
+		for j, b := range docs {
+			if i == j {
+				continue
+			}
+			score := TagWeight*jaccard(tags[i], tags[j]) + TextWeight*cosine(vectors[i], vectors[j])
+			if a.Category != "" && strings.EqualFold(a.Category, b.Category) {
+				score += CategoryWeight
+			}
+			if score > 0 {
+				matches = append(matches, Match{ID: b.ID, Score: score})
+			}
+		}
+
+		sort.Slice(matches, func(x, y int) bool {
+			if matches[x].Score != matches[y].Score {
+				return matches[x].Score > matches[y].Score
+			}
+			return matches[x].ID < matches[y].ID
+		})
+		if len(matches) > limit {
+			matches = matches[:limit]
+		}
+		ranked[a.ID] = matches
+	}
+	return ranked
+}
+
+// vector is a unit-length TF-IDF vector keyed by term
+type vector map[string]float64
+
+// tfidf weighs each document's terms by how often they appear in it and how
+// rare they are across all documents. Terms in every document carry no weight.
+func tfidf(docs []Document) []vector {
+	counts := make([]map[string]int, len(docs))
+	df := map[string]int{}
+	for i, d := range docs {
+		counts[i] = map[string]int{}
+		for _, term := range terms(d.Text) {
+			if counts[i][term] == 0 {
+				df[term]++
+			}
+			counts[i][term]++
+		}
+	}
+
+	vectors := make([]vector, len(docs))
+	n := float64(len(docs))
+	for i, c := range counts {
+		v := vector{}
+		var norm float64
+		for term, count := range c {
+			w := (1 + math.Log(float64(count))) * math.Log(n/float64(df[term]))
+			if w > 0 {
+				v[term] = w
+				norm += w * w
+			}
+		}
+		norm = math.Sqrt(norm)
+		for term := range v {
+			v[term] /= norm
+		}
+		vectors[i] = v
+	}
+	return vectors
+}
+
+func cosine(a, b vector) float64 {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	var dot float64
+	for term, w := range a {
+		dot += w * b[term]
+	}
+	return dot
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range a {
+		if b[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// terms splits text into lowercase words, leaving out stop words and words
+// shorter than three letters
+func terms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	kept := words[:0]
+	for _, w := range words {
+		if len(w) >= 3 && !stopWords[w] {
+			kept = append(kept, w)
+		}
+	}
+	return kept
+}
+
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`
+		about after all also and any are because been before but can could did does
+		each for from had has have her here his how into its just like more most
+		not now off only other our out over own same she should some such than that
+		the their them then there these they this those through too under until use
+		used using very was way were what when where which while who why will with
+		would you your`) {
+		stopWords[w] = true
+	}
+}
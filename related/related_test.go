@@ -0,0 +1,69 @@
+package related
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRank(t *testing.T) {
+	docs := []Document{
+		{ID: "k3s", Category: "Kubernetes", Tags: []string{"k8s", "homelab"}, Text: "Installing k3s on a raspberry pi cluster"},
+		{ID: "helm", Category: "Kubernetes", Tags: []string{"K8S", "helm"}, Text: "Packaging cluster apps with helm charts"},
+		{ID: "pi", Category: "Hardware", Tags: []string{"homelab"}, Text: "Choosing a raspberry pi and power supply for the rack"},
+		{ID: "cooking", Category: "Food", Text: "Bread recipes"},
+	}
+
+	ranked := Rank(docs, 2)
+
+	ids := func(matches []Match) []string {
+		var out []string
+		for _, m := range matches {
+			out = append(out, m.ID)
+		}
+		return out
+	}
+	if got := ids(ranked["k3s"]); !reflect.DeepEqual(got, []string{"helm", "pi"}) {
+		t.Errorf("k3s related = %v, want [helm pi]", got)
+	}
+	if got := ranked["cooking"]; len(got) != 0 {
+		t.Errorf("Expected nothing related to an unrelated post, got %+v", got)
+	}
+	for id, matches := range ranked {
+		for i := 1; i < len(matches); i++ {
+			if matches[i].Score > matches[i-1].Score {
+				t.Errorf("%s: matches not sorted by score: %+v", id, matches)
+			}
+		}
+		for _, m := range matches {
+			if m.ID == id {
+				t.Errorf("%s is related to itself", id)
+			}
+		}
+	}
+}
+
+func TestTextSimilarityIgnoresCommonWords(t *testing.T) {
+	docs := []Document{
+		{ID: "a", Text: "homelab backup strategy with restic"},
+		{ID: "b", Text: "homelab restic restore drill"},
+		{ID: "c", Text: "homelab dns with pihole"},
+	}
+
+	ranked := Rank(docs, 5)
+
+	// "homelab" is in every post, so only "restic" relates a and b
+	if len(ranked["a"]) != 1 || ranked["a"][0].ID != "b" {
+		t.Errorf("Expected only b related to a, got %+v", ranked["a"])
+	}
+	if len(ranked["c"]) != 0 {
+		t.Errorf("Expected nothing related to c, got %+v", ranked["c"])
+	}
+}
+
+func TestTerms(t *testing.T) {
+	got := terms("The K8s cluster's API-server, and an etcd node!")
+	want := []string{"k8s", "cluster", "api", "server", "etcd", "node"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("terms = %q, want %q", got, want)
+	}
+}
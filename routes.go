@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io/fs"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tinotenda-alfaneti/homelabsite/handlers"
+	"github.com/tinotenda-alfaneti/homelabsite/middleware"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// newRouter sets up the routes of the site, shared by the server and export
+func newRouter(app *handlers.App, rateLimiter *middleware.RateLimiter) (*mux.Router, error) {
+	r := mux.NewRouter()
+	r.Use(middleware.CSRF)
+	auth := app.Auth
+	admin := auth.RequireRole(models.RoleAdmin)
+	// Routes scripts may call with an API token, as well as signed-in users
+	// with the role each scope calls for
+	posts := auth.RequireScope(models.ScopePostsWrite)
+	comments := auth.RequireScope(models.ScopeCommentsModerate)
+	services := auth.RequireScope(models.ScopeServicesWrite)
+
+	// Static files
+	staticFS, err := fs.Sub(embedFS, "web/static")
+	if err != nil {
+		return nil, err
+	}
+	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.FS(staticFS))))
+
+	// Page routes
+	r.HandleFunc("/", app.HandleHome).Methods("GET")
+	r.HandleFunc("/services", app.HandleServices).Methods("GET")
+	r.HandleFunc("/blog", app.HandleBlog).Methods("GET")
+	r.HandleFunc("/blog/{id}", app.HandleBlogPost).Methods("GET")
+	r.HandleFunc("/tags/{tag}", app.HandleTag).Methods("GET")
+	r.HandleFunc("/category/{name}", app.HandleCategory).Methods("GET")
+	r.HandleFunc(`/tags/{tag}/{file:feed\.xml|atom\.xml|feed\.json}`, app.HandleTagFeed).Methods("GET")
+	r.HandleFunc(`/category/{name}/{file:feed\.xml|atom\.xml|feed\.json}`, app.HandleCategoryFeed).Methods("GET")
+	r.HandleFunc("/search", app.HandleSearchPage).Methods("GET")
+	r.HandleFunc("/about", app.HandleAbout).Methods("GET")
+	r.HandleFunc("/status", app.HandleStatus).Methods("GET")
+	r.HandleFunc("/health", app.HandleHealth).Methods("GET")
+
+	// Auth routes
+	r.HandleFunc("/admin/login", app.HandleLoginPage).Methods("GET")
+	r.HandleFunc("/admin/login", rateLimiter.RateLimit(app.HandleLogin)).Methods("POST")
+	r.HandleFunc("/admin/login/code", rateLimiter.RateLimit(app.HandleLoginCode)).Methods("POST")
+	r.HandleFunc("/admin/logout", app.HandleLogout).Methods("POST")
+	r.HandleFunc("/admin", auth.RequireAuth(app.HandleAdmin)).Methods("GET")
+	r.HandleFunc("/admin/invite/{token}", app.HandleInvitePage).Methods("GET")
+	r.HandleFunc("/admin/invite/{token}", rateLimiter.RateLimit(app.HandleAcceptInvite)).Methods("POST")
+
+	// API routes
+	r.HandleFunc("/api/services", app.HandleAPIServices).Methods("GET")
+	r.HandleFunc("/api/services", services(app.HandleAPICreateService)).Methods("POST")
+	r.HandleFunc("/api/services/{id:[0-9]+}", services(app.HandleAPIUpdateService)).Methods("PUT")
+	r.HandleFunc("/api/services/{id:[0-9]+}", services(app.HandleAPIDeleteService)).Methods("DELETE")
+	r.HandleFunc("/api/posts", app.HandleAPIPosts).Methods("GET")
+	r.HandleFunc("/api/posts/popular", app.HandleAPIPopularPosts).Methods("GET")
+	r.HandleFunc("/api/posts/{id}", app.HandleAPIGetPost).Methods("GET")
+	r.HandleFunc("/api/posts", posts(app.HandleAPISavePost)).Methods("POST")
+	r.HandleFunc("/api/posts/{id}", posts(app.HandleAPIDeletePost)).Methods("DELETE")
+	r.HandleFunc("/api/admin/posts/{id}/revisions", posts(app.HandleAPIListRevisions)).Methods("GET")
+	r.HandleFunc("/api/admin/posts/{id}/revisions/diff", posts(app.HandleAPIDiffRevisions)).Methods("GET")
+	r.HandleFunc("/api/admin/posts/{id}/revisions/{rev:[0-9]+}", posts(app.HandleAPIGetRevision)).Methods("GET")
+	r.HandleFunc("/api/admin/posts/{id}/revisions/{rev:[0-9]+}/restore", posts(app.HandleAPIRestoreRevision)).Methods("POST")
+	r.HandleFunc("/api/search", app.HandleSearch).Methods("GET")
+	r.HandleFunc("/api/tags", app.HandleAPITags).Methods("GET")
+	r.HandleFunc("/api/admin/tags", posts(app.HandleAPIAdminTags)).Methods("GET")
+	r.HandleFunc("/api/admin/tags/{id:[0-9]+}", posts(app.HandleAPIRenameTag)).Methods("PUT")
+	r.HandleFunc("/api/admin/tags/{id:[0-9]+}/merge", posts(app.HandleAPIMergeTag)).Methods("POST")
+	r.HandleFunc("/api/admin/categories", posts(app.HandleAPIAdminCategories)).Methods("GET")
+	r.HandleFunc("/api/admin/categories/{id:[0-9]+}", posts(app.HandleAPIRenameCategory)).Methods("PUT")
+	r.HandleFunc("/api/admin/categories/{id:[0-9]+}/merge", posts(app.HandleAPIMergeCategory)).Methods("POST")
+	r.HandleFunc("/api/admin/users", admin(app.HandleAPIUsers)).Methods("GET")
+	r.HandleFunc("/api/admin/users", admin(app.HandleAPIInviteUser)).Methods("POST")
+	r.HandleFunc("/api/admin/users/{id:[0-9]+}", admin(app.HandleAPIUpdateUser)).Methods("PUT")
+	r.HandleFunc("/api/admin/users/{id:[0-9]+}/reset", admin(app.HandleAPIResetUser)).Methods("POST")
+	r.HandleFunc("/api/admin/sessions", admin(app.HandleAPISessions)).Methods("GET")
+	r.HandleFunc("/api/admin/sessions/{id:[0-9a-f]+}", admin(app.HandleAPIRevokeSession)).Methods("DELETE")
+	r.HandleFunc("/api/admin/2fa", auth.RequireAuth(app.HandleAPITwoFactor)).Methods("GET")
+	r.HandleFunc("/api/admin/2fa/setup", auth.RequireAuth(app.HandleAPISetupTwoFactor)).Methods("POST")
+	r.HandleFunc("/api/admin/2fa/enable", auth.RequireAuth(app.HandleAPIEnableTwoFactor)).Methods("POST")
+	r.HandleFunc("/api/admin/2fa/disable", auth.RequireAuth(app.HandleAPIDisableTwoFactor)).Methods("POST")
+	r.HandleFunc("/api/admin/2fa/recovery-codes", auth.RequireAuth(app.HandleAPIRecoveryCodes)).Methods("POST")
+	r.HandleFunc("/api/admin/tokens", auth.RequireAuth(app.HandleAPITokens)).Methods("GET")
+	r.HandleFunc("/api/admin/tokens", auth.RequireAuth(app.HandleAPICreateToken)).Methods("POST")
+	r.HandleFunc("/api/admin/tokens/{id:[0-9]+}", auth.RequireAuth(app.HandleAPIDeleteToken)).Methods("DELETE")
+	r.HandleFunc("/api/status", app.HandleAPIStatus).Methods("GET")
+	r.HandleFunc("/api/admin/incidents/{id:[0-9]+}/notes", admin(app.HandleAPIAddIncidentNote)).Methods("POST")
+
+	// Comment routes
+	r.HandleFunc("/api/posts/{id}/comments", handlers.HandleGetComments(app.DB)).Methods("GET")
+	r.HandleFunc("/api/posts/{id}/comments", rateLimiter.RateLimit(handlers.HandlePostComment(app.DB))).Methods("POST")
+	r.HandleFunc("/api/admin/comments/pending", comments(handlers.HandleGetPendingComments(app.DB))).Methods("GET")
+	r.HandleFunc("/api/admin/comments/{id}/approve", comments(handlers.HandleApproveComment(app.DB))).Methods("POST")
+	r.HandleFunc("/api/admin/comments/{id}", comments(handlers.HandleDeleteComment(app.DB))).Methods("DELETE")
+
+	// RSS Feed
+	r.HandleFunc("/rss", app.HandleRSS).Methods("GET")
+	r.HandleFunc("/feed", app.HandleRSS).Methods("GET")
+	r.HandleFunc("/atom", app.HandleAtom).Methods("GET")
+	r.HandleFunc("/feed.json", app.HandleJSONFeed).Methods("GET")
+
+	// Social preview images
+	r.HandleFunc("/og.png", app.HandleSiteOGImage).Methods("GET")
+	r.HandleFunc("/og/{id}.png", app.HandleOGImage).Methods("GET")
+
+	// Crawlers
+	r.HandleFunc("/sitemap.xml", app.HandleSitemap).Methods("GET")
+	r.HandleFunc("/sitemap-{n:[0-9]+}.xml", app.HandleSitemapPart).Methods("GET")
+	r.HandleFunc("/robots.txt", app.HandleRobots).Methods("GET")
+
+	// 404 Handler
+	r.NotFoundHandler = http.HandlerFunc(app.Handle404)
+
+	return r, nil
+}
@@ -0,0 +1,278 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Query is a parsed search. Words and phrases must all match, excluded ones
+// must not. Every tag must be present; a post in any of the categories matches.
+type Query struct {
+	Terms   []string
+	Phrases []string
+	// ExcludeTerms holds -word and -"phrase" entries
+	ExcludeTerms []string
+	Tags         []string
+	ExcludeTags  []string
+	Categories   []string
+	// ExcludeCategories holds -category: filters
+	ExcludeCategories []string
+	// After is inclusive and Before exclusive, both at the start of the given day, month or year
+	After  *time.Time
+	Before *time.Time
+}
+
+// HasText reports whether the query has words or phrases to match
+func (q *Query) HasText() bool {
+	return len(q.Terms) > 0 || len(q.Phrases) > 0
+}
+
+// IsEmpty reports whether the query matches every post
+func (q *Query) IsEmpty() bool {
+	return !q.HasText() && len(q.ExcludeTerms) == 0 &&
+		len(q.Tags) == 0 && len(q.ExcludeTags) == 0 &&
+		len(q.Categories) == 0 && len(q.ExcludeCategories) == 0 &&
+		q.After == nil && q.Before == nil
+}
+
+// SyntaxError describes why a query couldn't be parsed.
+// Pos is the 0-based byte offset of the offending token in the input.
+type SyntaxError struct {
+	Message string `json:"message"`
+	Pos     int    `json:"position"`
+	Token   string `json:"token,omitempty"`
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s (at position %d)", e.Message, e.Pos+1)
+}
+
+// Filters understood by Parse
+const (
+	filterTag      = "tag"
+	filterCategory = "category"
+	filterAfter    = "after"
+	filterBefore   = "before"
+)
+
+// Parse reads a search such as
+//
+//	tag:k8s category:"Cloud Native" after:2025-01 -draft "exact phrase"
+//
+// Words and "quoted phrases" are matched against the post text. A leading
+// "-" excludes a word, phrase, tag or category. after: and before: take a
+// date as YYYY, YYYY-MM or YYYY-MM-DD.
+func Parse(input string) (*Query, error) {
+	q := &Query{}
+	p := parser{input: input}
+
+	for {
+		p.skipSpace()
+		if p.done() {
+			return q, nil
+		}
+		start := p.pos
+
+		negate := false
+		if p.peek() == '-' {
+			negate = true
+			p.pos++
+			if p.done() || unicode.IsSpace(p.peek()) {
+				return nil, &SyntaxError{Message: `"-" must be followed by a word, phrase or filter`, Pos: start, Token: "-"}
+			}
+		}
+
+		if p.peek() == '"' {
+			phrase, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			if phrase == "" {
+				continue
+			}
+			if negate {
+				q.ExcludeTerms = append(q.ExcludeTerms, phrase)
+			} else {
+				q.Phrases = append(q.Phrases, phrase)
+			}
+			continue
+		}
+
+		word := p.word()
+		key, _, isFilter := strings.Cut(word, ":")
+		if !isFilter || !isFilterName(key) {
+			// Other "word:" prefixes are searched as text, as in URLs or
+			// "error:timeout", unless they look like a misspelled filter
+			if filter, ok := misspelledFilter(key); ok && isFilter && len(word) > len(key)+1 {
+				return nil, &SyntaxError{
+					Message: fmt.Sprintf("unknown filter %q; did you mean %q?", key+":", filter+":"),
+					Pos:     start,
+					Token:   p.input[start:p.pos],
+				}
+			}
+			if negate {
+				q.ExcludeTerms = append(q.ExcludeTerms, word)
+			} else {
+				q.Terms = append(q.Terms, word)
+			}
+			continue
+		}
+
+		// The value follows the colon and may be quoted
+		p.pos = start + len(key) + 1
+		if negate {
+			p.pos++
+		}
+		var value string
+		if !p.done() && p.peek() == '"' {
+			var err error
+			if value, err = p.quoted(); err != nil {
+				return nil, err
+			}
+		} else {
+			value = p.word()
+		}
+		token := p.input[start:p.pos]
+		if value == "" {
+			return nil, &SyntaxError{Message: fmt.Sprintf("%s: needs a value", key), Pos: start, Token: token}
+		}
+
+		switch key {
+		case filterTag:
+			if negate {
+				q.ExcludeTags = append(q.ExcludeTags, value)
+			} else {
+				q.Tags = append(q.Tags, value)
+			}
+		case filterCategory:
+			if negate {
+				q.ExcludeCategories = append(q.ExcludeCategories, value)
+			} else {
+				q.Categories = append(q.Categories, value)
+			}
+		case filterAfter, filterBefore:
+			if negate {
+				return nil, &SyntaxError{Message: fmt.Sprintf("%s: can't be negated", key), Pos: start, Token: token}
+			}
+			t, err := parseDate(value)
+			if err != nil {
+				return nil, &SyntaxError{
+					Message: fmt.Sprintf("invalid date %q for %s:; use YYYY, YYYY-MM or YYYY-MM-DD", value, key),
+					Pos:     start,
+					Token:   token,
+				}
+			}
+			if key == filterAfter {
+				q.After = &t
+			} else {
+				q.Before = &t
+			}
+		}
+	}
+}
+
+// parser walks the input one token at a time
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+// peek returns the rune at the current position, decoding multi-byte UTF-8
+// so continuation bytes aren't mistaken for spaces
+func (p *parser) peek() rune {
+	r, _ := utf8.DecodeRuneInString(p.input[p.pos:])
+	return r
+}
+
+// advance moves past the rune at the current position
+func (p *parser) advance() {
+	_, size := utf8.DecodeRuneInString(p.input[p.pos:])
+	p.pos += size
+}
+
+func (p *parser) skipSpace() {
+	for !p.done() && unicode.IsSpace(p.peek()) {
+		p.advance()
+	}
+}
+
+// word reads up to the next space
+func (p *parser) word() string {
+	start := p.pos
+	for !p.done() && !unicode.IsSpace(p.peek()) {
+		p.advance()
+	}
+	return p.input[start:p.pos]
+}
+
+// quoted reads a double-quoted string starting at the opening quote
+func (p *parser) quoted() (string, error) {
+	start := p.pos
+	end := strings.IndexByte(p.input[start+1:], '"')
+	if end == -1 {
+		return "", &SyntaxError{Message: "missing closing quote", Pos: start, Token: p.input[start:]}
+	}
+	p.pos = start + 1 + end + 1
+	return strings.TrimSpace(p.input[start+1 : start+1+end]), nil
+}
+
+func isFilterName(key string) bool {
+	switch key {
+	case filterTag, filterCategory, filterAfter, filterBefore:
+		return true
+	}
+	return false
+}
+
+// misspelledFilter returns the filter name key is a typo of: one edit away
+// for the short names, two for before and category
+func misspelledFilter(key string) (string, bool) {
+	key = strings.ToLower(key)
+	for _, filter := range []string{filterTag, filterCategory, filterAfter, filterBefore} {
+		if key != filter && editDistance(key, filter) <= max(1, len(filter)/3) {
+			return filter, true
+		}
+	}
+	return "", false
+}
+
+// editDistance is the Levenshtein distance between a and b, in runes
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		cur := make([]int, len(br)+1)
+		cur[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(br)]
+}
+
+// parseDate reads YYYY, YYYY-MM or YYYY-MM-DD as the start of that period in UTC
+func parseDate(value string) (time.Time, error) {
+	var err error
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
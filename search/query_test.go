@@ -0,0 +1,104 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func date(s string) *time.Time {
+	t, err := parseDate(s)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Query
+	}{
+		{"empty", "  ", Query{}},
+		{"words", "helm  charts", Query{Terms: []string{"helm", "charts"}}},
+		{
+			"full example",
+			`tag:k8s category:"Cloud Native" after:2025-01 -draft "exact phrase"`,
+			Query{
+				Tags:         []string{"k8s"},
+				Categories:   []string{"Cloud Native"},
+				After:        date("2025-01"),
+				ExcludeTerms: []string{"draft"},
+				Phrases:      []string{"exact phrase"},
+			},
+		},
+		{
+			"negated filters and phrases",
+			`-tag:old -category:Misc -"work in progress" before:2024`,
+			Query{
+				ExcludeTags:       []string{"old"},
+				ExcludeCategories: []string{"Misc"},
+				ExcludeTerms:      []string{"work in progress"},
+				Before:            date("2024"),
+			},
+		},
+		{"dates", "after:2025-02-03 before:2025-03", Query{After: date("2025-02-03"), Before: date("2025-03")}},
+		{"colon in a word", "error: timeout k8s:v1", Query{Terms: []string{"error:", "timeout", "k8s:v1"}}},
+		{"unknown prefixes are text", "https://atarnet.org error:timeout -todo:later", Query{Terms: []string{"https://atarnet.org", "error:timeout"}, ExcludeTerms: []string{"todo:later"}}},
+		{"empty phrase", `"" go`, Query{Terms: []string{"go"}}},
+		{"non-ASCII words", "voilà café Ņice\u00a0naïve -ŝkip", Query{Terms: []string{"voilà", "café", "Ņice", "naïve"}, ExcludeTerms: []string{"ŝkip"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.input, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		token string
+	}{
+		{`go "unclosed`, 3, `"unclosed`},
+		{`tag:`, 0, `tag:`},
+		{`go after:yesterday`, 3, `after:yesterday`},
+		{`-before:2025`, 0, `-before:2025`},
+		{`catgory:k8s`, 0, `catgory:k8s`},
+		{`go tags:k8s`, 3, `tags:k8s`},
+		{`aftr:2025`, 0, `aftr:2025`},
+		{`k8s - go`, 4, `-`},
+		{`category:"Cloud`, 9, `"Cloud`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			syntaxErr, ok := err.(*SyntaxError)
+			if !ok {
+				t.Fatalf("Parse(%q) error = %v, want a *SyntaxError", tt.input, err)
+			}
+			if syntaxErr.Pos != tt.pos || syntaxErr.Token != tt.token {
+				t.Errorf("Parse(%q) error at %d %q, want %d %q", tt.input, syntaxErr.Pos, syntaxErr.Token, tt.pos, tt.token)
+			}
+		})
+	}
+}
+
+func TestQueryIsEmpty(t *testing.T) {
+	if q, _ := Parse(""); !q.IsEmpty() {
+		t.Error("Expected an empty query")
+	}
+	if q, _ := Parse("-draft"); q.IsEmpty() || q.HasText() {
+		t.Errorf("Expected a non-empty query without text, got %+v", q)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/tinotenda-alfaneti/homelabsite/config"
+	"github.com/tinotenda-alfaneti/homelabsite/db"
+	"github.com/tinotenda-alfaneti/homelabsite/frontmatter"
+	"github.com/tinotenda-alfaneti/homelabsite/models"
+)
+
+// defaultPostsDir is used when data.posts_dir is not set
+const defaultPostsDir = "content/posts"
+
+// contentPosts returns the posts from posts.yaml followed by the markdown
+// posts in data.posts_dir. Invalid markdown files are left out and reported
+// in the returned error, which is a frontmatter.LoadErrors in that case.
+func contentPosts(cfg *models.Config) ([]models.Post, error) {
+	dir := cfg.AppConfig.Data.PostsDir
+	if dir == "" {
+		dir = defaultPostsDir
+	}
+
+	mdPosts, err := frontmatter.LoadPosts(dir)
+	var loadErrs frontmatter.LoadErrors
+	if err != nil && !errors.As(err, &loadErrs) {
+		return nil, err
+	}
+
+	posts := make([]models.Post, 0, len(cfg.Posts)+len(mdPosts))
+	posts = append(posts, cfg.Posts...)
+	posts = append(posts, mdPosts...)
+	return posts, err
+}
+
+// syncOptionsForMode maps the data.sync config value to sync options.
+// The boolean result is false when syncing is turned off.
+func syncOptionsForMode(mode string) (db.SyncOptions, bool, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", "upsert":
+		return db.SyncOptions{}, true, nil
+	case "mirror":
+		return db.SyncOptions{Delete: true}, true, nil
+	case "off":
+		return db.SyncOptions{}, false, nil
+	default:
+		return db.SyncOptions{}, false, fmt.Errorf("unknown mode %q (want upsert, mirror or off)", mode)
+	}
+}
+
+// runSync implements the "sync" subcommand, reconciling the YAML content
+// with the database on demand and printing what changed
+func runSync(configPath, dbPath string, args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	deleteRows := fs.Bool("delete", false, "delete rows that were removed from the YAML")
+	dryRun := fs.Bool("dry-run", false, "report changes without writing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+
+	// Refuse to sync a partial set of posts, since -delete would drop the invalid ones
+	posts, err := contentPosts(cfg)
+	if err != nil {
+		return err
+	}
+
+	database, err := db.New(dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	report, err := database.SyncContent(posts, cfg.Services, db.SyncOptions{
+		Delete: *deleteRows,
+		DryRun: *dryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(report)
+	printSyncChanges("post", report.Posts)
+	printSyncChanges("service", report.Services)
+	return nil
+}
+
+func printSyncChanges(kind string, changes db.SyncChanges) {
+	for _, key := range changes.Inserted {
+		fmt.Printf("  + %s %s\n", kind, key)
+	}
+	for _, key := range changes.Updated {
+		fmt.Printf("  ~ %s %s\n", kind, key)
+	}
+	for _, key := range changes.Deleted {
+		fmt.Printf("  - %s %s\n", kind, key)
+	}
+	for _, key := range changes.Adopted {
+		fmt.Printf("  = %s %s (kept as is)\n", kind, key)
+	}
+	for _, key := range changes.Conflicts {
+		fmt.Printf("  ! %s %s (name taken, skipped)\n", kind, key)
+	}
+}
@@ -0,0 +1,122 @@
+package textdiff
+
+import "strings"
+
+// Op is the kind of change a diff line represents
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// Line is one line of a line-level diff. OldLine and NewLine are the 1-based
+// line numbers in each text, or 0 when the line doesn't appear there.
+type Line struct {
+	Op      Op     `json:"op"`
+	Text    string `json:"text"`
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+}
+
+// Lines returns a minimal line-level diff turning a into b, based on the
+// longest common subsequence of their lines
+func Lines(a, b string) []Line {
+	oldLines := splitLines(a)
+	newLines := splitLines(b)
+
+	// Common prefix and suffix are cheap to match and keep the LCS table small
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+
+	diff := make([]Line, 0, len(oldLines)+len(newLines))
+	for i := 0; i < prefix; i++ {
+		diff = append(diff, Line{Op: Equal, Text: oldLines[i], OldLine: i + 1, NewLine: i + 1})
+	}
+
+	diff = append(diff, lcsDiff(
+		oldLines[prefix:len(oldLines)-suffix],
+		newLines[prefix:len(newLines)-suffix],
+		prefix, prefix,
+	)...)
+
+	for i := suffix; i > 0; i-- {
+		oldIdx := len(oldLines) - i
+		newIdx := len(newLines) - i
+		diff = append(diff, Line{Op: Equal, Text: oldLines[oldIdx], OldLine: oldIdx + 1, NewLine: newIdx + 1})
+	}
+
+	return diff
+}
+
+// lcsDiff diffs two slices of lines; oldOffset and newOffset are the number
+// of lines before them, for numbering
+func lcsDiff(a, b []string, oldOffset, newOffset int) []Line {
+	n, m := len(a), len(b)
+
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []Line
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			diff = append(diff, Line{Op: Equal, Text: a[i], OldLine: oldOffset + i + 1, NewLine: newOffset + j + 1})
+			i++
+			j++
+		case j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, Line{Op: Delete, Text: a[i], OldLine: oldOffset + i + 1})
+			i++
+		default:
+			diff = append(diff, Line{Op: Insert, Text: b[j], NewLine: newOffset + j + 1})
+			j++
+		}
+	}
+	return diff
+}
+
+// Stats counts the inserted and deleted lines in a diff
+func Stats(diff []Line) (inserted, deleted int) {
+	for _, l := range diff {
+		switch l.Op {
+		case Insert:
+			inserted++
+		case Delete:
+			deleted++
+		}
+	}
+	return inserted, deleted
+}
+
+// splitLines splits text into lines, ignoring a single trailing newline
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
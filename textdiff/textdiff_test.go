@@ -0,0 +1,75 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+// render formats a diff like a unified diff body: " ", "+" or "-" then the text
+func render(diff []Line) string {
+	var b strings.Builder
+	for _, l := range diff {
+		switch l.Op {
+		case Equal:
+			b.WriteString(" ")
+		case Insert:
+			b.WriteString("+")
+		case Delete:
+			b.WriteString("-")
+		}
+		b.WriteString(l.Text)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"identical", "a\nb\n", "a\nb\n", " a\n b\n"},
+		{"both empty", "", "", ""},
+		{"from empty", "", "a\nb", "+a\n+b\n"},
+		{"to empty", "a\nb\n", "", "-a\n-b\n"},
+		{"append", "a\nb", "a\nb\nc", " a\n b\n+c\n"},
+		{"change middle", "a\nb\nc", "a\nB\nc", " a\n-b\n+B\n c\n"},
+		{"move", "a\nb\nc\nd", "b\nc\na\nd", "-a\n b\n c\n+a\n d\n"},
+		{"crlf", "a\r\nb\r\n", "a\nb\n", " a\n b\n"},
+		{"trailing newline ignored", "a", "a\n", " a\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := render(Lines(tt.a, tt.b)); got != tt.want {
+				t.Errorf("Lines(%q, %q) =\n%s\nwant\n%s", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinesNumbering(t *testing.T) {
+	diff := Lines("keep\nold\nkeep2\n", "keep\nnew\nextra\nkeep2\n")
+
+	want := []Line{
+		{Op: Equal, Text: "keep", OldLine: 1, NewLine: 1},
+		{Op: Delete, Text: "old", OldLine: 2},
+		{Op: Insert, Text: "new", NewLine: 2},
+		{Op: Insert, Text: "extra", NewLine: 3},
+		{Op: Equal, Text: "keep2", OldLine: 3, NewLine: 4},
+	}
+	if len(diff) != len(want) {
+		t.Fatalf("got %+v, want %+v", diff, want)
+	}
+	for i := range want {
+		if diff[i] != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, diff[i], want[i])
+		}
+	}
+
+	inserted, deleted := Stats(diff)
+	if inserted != 2 || deleted != 1 {
+		t.Errorf("Stats = +%d -%d, want +2 -1", inserted, deleted)
+	}
+}
@@ -0,0 +1,123 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key from the RFC 6238 test vectors, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestHOTPRFCVectors(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1, eight digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	key := []byte("12345678901234567890")
+	for _, tt := range tests {
+		got := hotp(key, uint64(Step(time.Unix(tt.unix, 0))), 8)
+		if got != tt.want {
+			t.Errorf("At %d: expected %s, got %s", tt.unix, tt.want, got)
+		}
+	}
+}
+
+func TestCode(t *testing.T) {
+	got, err := Code(rfcSecret, time.Unix(1111111109, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "081804" {
+		t.Errorf("Expected the last six digits of the RFC vector, got %s", got)
+	}
+
+	lower, err := Code(strings.ToLower("GEZD GNBV GY3T QOJQ GEZD GNBV GY3T QOJQ"), time.Unix(1111111109, 0))
+	if err != nil || lower != got {
+		t.Errorf("Expected spaced lowercase secrets to work, got %q, %v", lower, err)
+	}
+
+	if _, err := Code("not base32!", time.Now()); err != ErrInvalidSecret {
+		t.Errorf("Expected ErrInvalidSecret, got %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	at := func(offset time.Duration) string {
+		code, err := Code(rfcSecret, now.Add(offset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantOK   bool
+		wantStep int64
+	}{
+		{"current", at(0), true, Step(now)},
+		{"previous step", at(-Period), true, Step(now) - 1},
+		{"next step", at(Period), true, Step(now) + 1},
+		{"two steps old", at(-2 * Period), false, 0},
+		{"wrong", "000000", false, 0},
+		{"too short", at(0)[:5], false, 0},
+		{"empty", "", false, 0},
+	}
+	for _, tt := range tests {
+		step, ok := Verify(rfcSecret, tt.code, now)
+		if ok != tt.wantOK || step != tt.wantStep {
+			t.Errorf("%s: expected (%d, %t), got (%d, %t)", tt.name, tt.wantStep, tt.wantOK, step, ok)
+		}
+	}
+
+	if _, ok := Verify("", "000000", now); ok {
+		t.Error("Expected an empty secret never to verify")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GenerateSecret()
+	if a == b {
+		t.Error("Expected different secrets")
+	}
+	if len(a) != 32 {
+		t.Errorf("Expected 32 base32 characters for 160 bits, got %d", len(a))
+	}
+	if _, err := Code(a, time.Now()); err != nil {
+		t.Errorf("Generated secret doesn't decode: %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Atarnet Homelab", "jane", rfcSecret)
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Atarnet Homelab:jane" {
+		t.Errorf("Unexpected URI %s", uri)
+	}
+	if strings.Contains(uri, "+") {
+		t.Errorf("Expected spaces as %%20, got %s", uri)
+	}
+	q := u.Query()
+	if q.Get("secret") != rfcSecret || q.Get("issuer") != "Atarnet Homelab" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("Unexpected query %v", q)
+	}
+}